@@ -0,0 +1,882 @@
+package sdkv1_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/alternatortest"
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+
+	helper "github.com/scylladb/alternator-client-golang/sdkv1"
+)
+
+func TestServedBy(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("dc1", "rack1", 2))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts()[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+
+	const tableName = "served_by"
+	_, err = ddb.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		},
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		},
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	target := cluster.Node(1)
+	target.InjectFault(alternatortest.Times(1, alternatortest.InternalServerError()))
+	defer target.ClearFaults()
+
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ID": {S: aws.String("123")},
+		},
+	}
+
+	t.Run("Request", func(t *testing.T) {
+		req, _ := ddb.GetItemRequest(input)
+		req.SetContext(helper.WithNode(context.Background(), target.URL()))
+		if err := req.Send(); err != nil {
+			t.Fatalf("failed to read record: %v", err)
+		}
+		info, ok := helper.ServedBy(req)
+		if !ok {
+			t.Fatalf("no routing info in request")
+		}
+		if info.Node != target.URL() {
+			t.Errorf("expected request to be served by %s, got %s", target.URL().Host, info.Node.Host)
+		}
+		if info.Attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", info.Attempts)
+		}
+	})
+
+	t.Run("WithServedBy", func(t *testing.T) {
+		var info helper.RoutingInfo
+		_, err := ddb.GetItemWithContext(context.Background(), input, helper.WithServedBy(&info))
+		if err != nil {
+			t.Fatalf("failed to read record: %v", err)
+		}
+		if cluster.NodeByHost(info.Node.Hostname()) == nil {
+			t.Errorf("request served by unknown node %q", info.Node.Host)
+		}
+		if info.Attempts != 1 {
+			t.Errorf("expected 1 attempt, got %d", info.Attempts)
+		}
+	})
+}
+
+func TestDynamoDBStreams(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 2))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts()[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithOptimizeHeaders(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	streams, err := h.NewDynamoDBStreams()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB Streams client: %v", err)
+	}
+
+	const tableName = "streams_table"
+	_, err = ddb.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		},
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		},
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		StreamSpecification: &dynamodb.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: aws.String(dynamodb.StreamViewTypeNewAndOldImages),
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	var info helper.RoutingInfo
+	out, err := streams.ListStreamsWithContext(
+		context.Background(),
+		&dynamodbstreams.ListStreamsInput{TableName: aws.String(tableName)},
+		helper.WithServedBy(&info),
+	)
+	if err != nil {
+		t.Fatalf("failed to list streams: %v", err)
+	}
+	if len(out.Streams) != 1 || aws.StringValue(out.Streams[0].TableName) != tableName {
+		t.Fatalf("expected single stream of %s, got %v", tableName, out.Streams)
+	}
+	if cluster.NodeByHost(info.Node.Hostname()) == nil {
+		t.Errorf("expected request to be routed to one of the nodes, got %v", info.Node)
+	}
+}
+
+func TestAWSSession(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 3))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts()[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	if err = h.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+	}
+
+	sess, err := h.AWSSession()
+	if err != nil {
+		t.Fatalf("failed to create aws session: %v", err)
+	}
+	calls := 0
+	sess.Handlers.Send.PushFront(func(*request.Request) {
+		calls++
+	})
+	ddb := dynamodb.New(sess)
+
+	const requests = 6
+	for range requests {
+		_, err = ddb.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+		var aErr awserr.Error
+		if !errors.As(err, &aErr) || aErr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+			t.Fatalf("expected ResourceNotFoundException, got %v", err)
+		}
+	}
+
+	if calls != requests {
+		t.Errorf("expected custom handler to be called %d times, got %d", requests, calls)
+	}
+	for _, node := range cluster.Nodes() {
+		if node.Requests() == 0 {
+			t.Errorf("node %s has not received any requests", node.Host())
+		}
+	}
+}
+
+func TestCredentialsProvider(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+
+	var keys []string
+	// Node discovery signs its requests too, only requests of the client are recorded
+	recordKey := func(_ http.ResponseWriter, r *http.Request) bool {
+		if _, cred, ok := strings.Cut(r.Header.Get("Authorization"), "Credential="); ok {
+			key, _, _ := strings.Cut(cred, "/")
+			keys = append(keys, key)
+		}
+		return false
+	}
+	cluster.Node(0).InjectFault(alternatortest.OnOperation("DescribeTable", recordKey))
+
+	rotations := 0
+	provider := shared.NewCallbackCredentialsProvider(func(context.Context) (shared.Credentials, error) {
+		rotations++
+		return shared.Credentials{
+			AccessKeyID:     fmt.Sprintf("key%d", rotations),
+			SecretAccessKey: "secret",
+			Expires:         time.Now().Add(100 * time.Millisecond),
+		}, nil
+	}, 0)
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentialsProvider(provider),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	describe := func() {
+		t.Helper()
+		_, err := ddb.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+		var aErr awserr.Error
+		if !errors.As(err, &aErr) || aErr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+			t.Fatalf("expected ResourceNotFoundException, got %v", err)
+		}
+	}
+
+	describe()
+	describe()
+	time.Sleep(150 * time.Millisecond)
+	describe()
+
+	if want := []string{"key1", "key1", "key2"}; !slices.Equal(keys, want) {
+		t.Fatalf("expected requests to be signed with %v, got %v", want, keys)
+	}
+}
+
+func TestOptimizeHeadersSigning(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+	cluster.Node(0).InjectFault(alternatortest.RequireSigV4("key", "secret"))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("key", "secret"),
+		helper.WithOptimizeHeaders(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	for range 3 {
+		_, err = ddb.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+		var aErr awserr.Error
+		if !errors.As(err, &aErr) || aErr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+			t.Fatalf("expected headers to be removed before request is signed, got %v", err)
+		}
+	}
+}
+
+func TestOptimizeHeadersWithCredentialsProvider(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+	cluster.Node(0).InjectFault(alternatortest.RequireSigV4("key", "secret"))
+
+	provider := shared.NewCallbackCredentialsProvider(func(context.Context) (shared.Credentials, error) {
+		return shared.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+	}, 0)
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentialsProvider(provider),
+		helper.WithOptimizeHeaders(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	_, err = ddb.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+	var aErr awserr.Error
+	if !errors.As(err, &aErr) || aErr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+		t.Fatalf("expected signed request to reach the table lookup, got %v", err)
+	}
+}
+
+func TestOptimizeHeadersWithSessionToken(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+	cluster.Node(0).InjectFault(alternatortest.RequireSigV4("key", "secret"))
+
+	provider := shared.NewCallbackCredentialsProvider(func(context.Context) (shared.Credentials, error) {
+		return shared.Credentials{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token"}, nil
+	}, 0)
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentialsProvider(provider),
+		helper.WithOptimizeHeaders(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	// Signed X-Amz-Security-Token header has to reach the node
+	_, err = ddb.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+	var aErr awserr.Error
+	if !errors.As(err, &aErr) || aErr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+		t.Fatalf("expected signed request to reach the table lookup, got %v", err)
+	}
+}
+
+func TestRequestCompressionSigning(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+	var compressed atomic.Int64
+	cluster.Node(0).InjectFault(
+		func(_ http.ResponseWriter, r *http.Request) bool {
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				compressed.Add(1)
+			}
+			return false
+		},
+		alternatortest.RequireSigV4("key", "secret"),
+	)
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("key", "secret"),
+		helper.WithOptimizeHeaders(true),
+		helper.WithRequestCompression(true),
+		helper.WithRequestCompressionMinSize(1),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	const tableName = "compressed_table"
+	_, err = ddb.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		},
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		},
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	_, err = ddb.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"ID":    {S: aws.String("123")},
+			"Value": {S: aws.String(strings.Repeat("value", 100))},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create record: %v", err)
+	}
+	result, err := ddb.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ID": {S: aws.String("123")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to read record: %v", err)
+	}
+	if result.Item == nil {
+		t.Fatalf("no item found")
+	}
+	if got := compressed.Load(); got != 3 {
+		t.Errorf("expected 3 compressed requests, got %d", got)
+	}
+}
+
+func TestHTTPTransportPatchedOnce(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	var depth int
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			VerifyConnection: func(tls.ConnectionState) error {
+				depth = verifyConnectionDepth()
+				return nil
+			},
+		},
+	}
+	h, err := helper.NewHelper([]string{"127.0.0.1"}, helper.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	var ddb *dynamodb.DynamoDB
+	for range 5 {
+		if ddb, err = h.NewDynamoDB(); err != nil {
+			t.Fatalf("failed to create DynamoDB client: %v", err)
+		}
+	}
+	patched, ok := shared.UnwrapHTTPTransport(ddb.Config.HTTPClient.Transport)
+	if !ok {
+		t.Fatal("expected DynamoDB client to use http.Transport")
+	}
+	if patched == transport {
+		t.Fatal("expected transport of the caller to be cloned")
+	}
+	if err = patched.TLSClientConfig.VerifyConnection(tls.ConnectionState{}); err != nil || depth != 1 {
+		t.Errorf("expected VerifyConnection to be wrapped once, got depth %d, error %v", depth, err)
+	}
+	if err = transport.TLSClientConfig.VerifyConnection(tls.ConnectionState{}); err != nil || depth != 0 {
+		t.Errorf("expected transport of the caller to be left intact, got depth %d, error %v", depth, err)
+	}
+	if _, err = h.Update(helper.WithCredentials("whatever", "secret")).NewDynamoDB(); err != nil {
+		t.Errorf("failed to create DynamoDB client of updated helper: %v", err)
+	}
+}
+
+// verifyConnectionDepth returns number of VerifyConnection wrappers installed by the helper on the call stack
+func verifyConnectionDepth() int {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	depth := 0
+	for {
+		frame, more := frames.Next()
+		if strings.Contains(frame.Function, "shared.PatchBasicHTTPTransport") {
+			depth++
+		}
+		if !more {
+			return depth
+		}
+	}
+}
+
+func TestAWSConfigHandlers(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+	cluster.Node(0).InjectFault(alternatortest.RequireSigV4("key", "secret"))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("key", "secret"),
+		helper.WithOptimizeHeaders(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	cfg, err := h.AWSConfig()
+	if err != nil {
+		t.Fatalf("failed to create aws config: %v", err)
+	}
+	sess, err := session.NewSession(&cfg)
+	if err != nil {
+		t.Fatalf("failed to create aws session: %v", err)
+	}
+	h.AddHandlers(&sess.Handlers)
+	ddb := dynamodb.New(sess)
+
+	var info helper.RoutingInfo
+	_, err = ddb.DescribeTableWithContext(
+		context.Background(),
+		&dynamodb.DescribeTableInput{TableName: aws.String("missing")},
+		helper.WithServedBy(&info),
+	)
+	var aErr awserr.Error
+	if !errors.As(err, &aErr) || aErr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+		t.Fatalf("expected headers to be removed before request is signed, got %v", err)
+	}
+	if info.Node != cluster.Node(0).URL() {
+		t.Errorf("expected routing info to be collected, got %+v", info)
+	}
+}
+
+func TestClose(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 2))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithWarmUpConnections(2),
+		helper.WithDrainRequestsOnClose(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	describe := func() error {
+		_, err := ddb.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+		return err
+	}
+
+	for _, node := range cluster.Nodes() {
+		node.InjectFault(alternatortest.OnOperation("DescribeTable", alternatortest.Latency(200*time.Millisecond)))
+	}
+	inFlight := make(chan error, 1)
+	go func() {
+		inFlight <- describe()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = h.Close(ctx); err != nil {
+		t.Fatalf("Close unexpectedly failed: %v", err)
+	}
+	if err = h.Close(ctx); err != nil {
+		t.Fatalf("repeated Close unexpectedly failed: %v", err)
+	}
+
+	select {
+	case err = <-inFlight:
+		var awsErr awserr.Error
+		if !errors.As(err, &awsErr) || awsErr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+			t.Fatalf("expected request in flight to complete, got %v", err)
+		}
+	default:
+		t.Fatalf("Close returned before request in flight has completed")
+	}
+
+	var awsErr awserr.Error
+	if err = describe(); !errors.As(err, &awsErr) || !errors.Is(awsErr.OrigErr(), shared.ErrClosed) {
+		t.Fatalf("expected ErrClosed for request sent after Close, got %v", err)
+	}
+}
+
+func TestWaitForReady(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 3))
+	cluster.Node(0).SetDown(true)
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithRoutingScope(rt.NewRackScope("datacenter1", "rack1", nil)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report, err := h.WaitForReady(ctx, helper.ReadyOptions{CheckRackAndDatacenter: true, MinNodes: 2})
+	if err != nil {
+		t.Fatalf("WaitForReady() unexpectedly returned an error: %v", err)
+	}
+	if !report.PrimaryScope || len(report.Nodes) != 2 {
+		t.Errorf("expected 2 nodes in the primary scope, got %v in %v", report.Nodes, report.Scope)
+	}
+	if len(report.FailedSeeds) != 1 || report.FailedSeeds[0].Seed.Hostname() != cluster.Node(0).Host() {
+		t.Errorf("expected seed %s to fail, got %v", cluster.Node(0).Host(), report.FailedSeeds)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err = h.WaitForReady(ctx, helper.ReadyOptions{MinNodes: 3}); err == nil {
+		t.Fatalf("expected WaitForReady() to fail when scope has not enough nodes")
+	}
+}
+
+func TestTopologyRegistry(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+		{Datacenter: "dc1", Rack: "rack1"},
+		{Datacenter: "dc1", Rack: "rack2"},
+	})
+	hosts := cluster.Hosts()
+
+	registry, err := helper.NewTopologyRegistry(
+		hosts[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithRoutingScope(rt.NewDCScope("dc1", nil)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create topology registry: %v", err)
+	}
+	defer registry.Stop()
+
+	rack1 := helper.NewHelperFromRegistry(registry, helper.WithRoutingScope(rt.NewRackScope("dc1", "rack1", nil)))
+	rack2 := rack1.Update(helper.WithRoutingScope(rt.NewRackScope("dc1", "rack2", nil)), helper.WithPort(9999))
+	// Lists of scopes are discovered in background, helpers wait for them before routing
+	for _, h := range []*helper.Helper{rack1, rack2} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = h.WaitForReady(ctx, helper.ReadyOptions{MinNodes: 1})
+		cancel()
+		if err != nil {
+			t.Fatalf("WaitForReady() unexpectedly returned an error: %v", err)
+		}
+	}
+	for range 3 {
+		if node := rack1.NextNode(); node.Hostname() != hosts[0] {
+			t.Fatalf("expected helper to route to %s, got %s", hosts[0], node.String())
+		}
+		if node := rack2.NextNode(); node.Hostname() != hosts[1] || node.Port() != "9999" {
+			t.Fatalf("expected updated helper to route to %s:9999, got %s", hosts[1], node.String())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = rack1.Close(ctx); err != nil {
+		t.Fatalf("Close() unexpectedly returned an error: %v", err)
+	}
+	if state := registry.State(); state == helper.StateClosed {
+		t.Fatalf("expected helper created from registry to leave it running, got %s", state)
+	}
+}
+
+func TestTableRouting(t *testing.T) {
+	testTableRouting(t)
+}
+
+func TestTableRoutingWithRequestCompression(t *testing.T) {
+	// Body is compressed before it reaches the transport, table is still taken from it
+	testTableRouting(t, helper.WithRequestCompression(true), helper.WithRequestCompressionMinSize(1))
+}
+
+func testTableRouting(t *testing.T, opts ...helper.Option) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+		{Datacenter: "dc1", Rack: "rack1"},
+		{Datacenter: "dc1", Rack: "rack2"},
+	})
+	first := cluster.Node(0).URL()
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		append([]helper.Option{
+			helper.WithPort(cluster.Port()),
+			helper.WithRoutingScope(rt.NewRackScope("dc1", "rack1", nil)),
+			helper.WithTableRoute("hot_*", rt.NewRackScope("dc1", "rack2", nil), nil),
+			helper.WithTableRoute("logs", nil, func([]url.URL) url.URL { return first }),
+			helper.WithCredentials("whatever", "secret"),
+		}, opts...)...,
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+
+	tcases := []struct {
+		name     string
+		call     func(opt request.Option) error
+		expected *alternatortest.Node
+	}{
+		{
+			name: "Pattern",
+			call: func(opt request.Option) error {
+				_, err := ddb.DescribeTableWithContext(
+					context.Background(),
+					&dynamodb.DescribeTableInput{TableName: aws.String("hot_items")},
+					opt,
+				)
+				return err
+			},
+			expected: cluster.Node(1),
+		},
+		{
+			name: "BalancingPolicy",
+			call: func(opt request.Option) error {
+				_, err := ddb.DescribeTableWithContext(
+					context.Background(),
+					&dynamodb.DescribeTableInput{TableName: aws.String("logs")},
+					opt,
+				)
+				return err
+			},
+			expected: cluster.Node(0),
+		},
+		{
+			name: "BatchOperation",
+			call: func(opt request.Option) error {
+				_, err := ddb.BatchGetItemWithContext(context.Background(), &dynamodb.BatchGetItemInput{
+					RequestItems: map[string]*dynamodb.KeysAndAttributes{
+						"hot_batch": {Keys: []map[string]*dynamodb.AttributeValue{
+							{"ID": {S: aws.String("1")}},
+						}},
+					},
+				}, opt)
+				return err
+			},
+			expected: cluster.Node(1),
+		},
+	}
+	// List of nodes of the scope of a route is discovered in background after its first request,
+	// until then requests are sent to nodes of the main list, that has only the other rack
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = h.WaitForReady(ctx, helper.ReadyOptions{MinNodes: 1}); err != nil {
+		t.Fatalf("WaitForReady() unexpectedly returned an error: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		var info helper.RoutingInfo
+		_ = tcases[0].call(helper.WithServedBy(&info))
+		if info.Node.Host == tcases[0].expected.URL().Host {
+			break
+		}
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			for range 4 {
+				var info helper.RoutingInfo
+				_ = tc.call(helper.WithServedBy(&info))
+				if info.Node.Host != tc.expected.URL().Host {
+					t.Fatalf("expected request to be served by %s, got %s", tc.expected.URL().Host, info.Node.Host)
+				}
+			}
+		})
+	}
+}
+
+func TestTableRoutingKeepsRequestIntact(t *testing.T) {
+	// Custom CA bundle can't be applied to the http client of the helper
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("dc1", "rack1", 1))
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithTableRoute("hot_*", rt.NewDCScope("dc1", nil), nil),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	cfg, err := h.AWSConfig()
+	if err != nil {
+		t.Fatalf("failed to create AWS config: %v", err)
+	}
+	for _, operation := range []string{"DescribeTable", "ListTables"} {
+		t.Run(operation, func(t *testing.T) {
+			const body = `{"TableName":"hot_items"}`
+			req, err := http.NewRequest(
+				http.MethodPost,
+				"http://dynamodb.fake.alterntor.cluster.node/",
+				strings.NewReader(body),
+			)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+operation)
+			original, originalURL := *req, *req.URL
+
+			resp, err := cfg.HTTPClient.Transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("request unexpectedly failed: %v", err)
+			}
+			_ = resp.Body.Close()
+			if req.Body != original.Body || req.URL != original.URL || *req.URL != originalURL ||
+				req.Host != original.Host || req.Context() != original.Context() {
+				t.Errorf("expected request passed to RoundTrip to be left intact")
+			}
+		})
+	}
+}
+
+// staticNodes is a nodes list provider that implements only `AlternatorNodesSource`
+type staticNodes []url.URL
+
+func (s staticNodes) NextNode() url.URL                                      { return s[0] }
+func (s staticNodes) GetNodes() []url.URL                                    { return s }
+func (s staticNodes) UpdateLiveNodes() error                                 { return nil }
+func (s staticNodes) CheckIfRackAndDatacenterSetCorrectly() error            { return nil }
+func (s staticNodes) CheckIfRackDatacenterFeatureIsSupported() (bool, error) { return false, nil }
+func (s staticNodes) Start()                                                 {}
+func (s staticNodes) Stop()                                                  {}
+
+func TestNodesSourceInterfaces(t *testing.T) {
+	var source helper.AlternatorNodesSource = staticNodes{{Scheme: "http", Host: "127.0.0.1:8000"}}
+	if _, ok := source.(helper.ManagedNodesSource); ok {
+		t.Fatalf("expected nodes provider with original methods only not to implement ManagedNodesSource")
+	}
+
+	var live any = &shared.AlternatorLiveNodes{}
+	if _, ok := live.(helper.AlternatorNodesSource); !ok {
+		t.Fatalf("expected AlternatorLiveNodes to implement AlternatorNodesSource")
+	}
+	if _, ok := live.(helper.ManagedNodesSource); !ok {
+		t.Fatalf("expected AlternatorLiveNodes to implement ManagedNodesSource")
+	}
+}
@@ -0,0 +1,50 @@
+package sdkv1
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+const credentialsSource = "AlternatorCredentialsProvider"
+
+// credentialsProvider adapts `shared.CredentialsProvider` to `credentials.Provider`,
+//
+//	`credentials.Credentials` caches retrieved credentials until `IsExpired` returns true
+type credentialsProvider struct {
+	credentials.Expiry
+	provider  shared.CredentialsProvider
+	logger    logx.Logger
+	canExpire bool
+}
+
+func (p *credentialsProvider) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithContext(context.Background())
+}
+
+func (p *credentialsProvider) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	creds, err := p.provider.Retrieve(ctx, p.logger)
+	if err != nil {
+		return credentials.Value{ProviderName: credentialsSource}, err
+	}
+	p.canExpire = !creds.Expires.IsZero()
+	p.SetExpiration(creds.Expires, 0)
+	return credentials.Value{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		ProviderName:    credentialsSource,
+	}, nil
+}
+
+func (p *credentialsProvider) IsExpired() bool {
+	return p.canExpire && p.Expiry.IsExpired()
+}
+
+// newCredentials returns credentials that are retrieved from `provider` and cached until they expire
+func newCredentials(provider shared.CredentialsProvider, logger logx.Logger) *credentials.Credentials {
+	return credentials.NewCredentials(&credentialsProvider{provider: provider, logger: logger})
+}
@@ -30,21 +30,75 @@ This package depends on the shared submodule, which contains reusable configurat
 package sdkv1
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
 
 	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/rt"
 )
 
 // Option is option for the `NewHelper`
 type Option = shared.Option
 
+// HTTP2Mode controls whether http clients use HTTP/2 to talk to Alternator nodes
+type HTTP2Mode = shared.HTTP2Mode
+
+// CertificateExpiry describes client or server certificate seen by http clients
+type CertificateExpiry = shared.CertificateExpiry
+
+// ReadyOptions configures `Helper.WaitForReady`
+type ReadyOptions = shared.ReadyOptions
+
+// ReadyReport is a result of `Helper.WaitForReady`
+type ReadyReport = shared.ReadyReport
+
+// SeedError describes a seed node that failed to return list of nodes
+type SeedError = shared.SeedError
+
+// LifecycleState a state of background node discovery
+type LifecycleState = shared.LifecycleState
+
+// NodeInfo is a discovered node along with its datacenter and rack, if they are known
+type NodeInfo = shared.NodeInfo
+
+// BalancingPolicy picks a node to send request to out of nodes of a routing scope
+type BalancingPolicy = shared.BalancingPolicy
+
+// TableRoute describes how requests to tables matching the pattern are routed
+type TableRoute = shared.TableRoute
+
+const (
+	// HTTP2Default leaves protocol negotiation up to `http.Transport` defaults
+	HTTP2Default = shared.HTTP2Default
+	// HTTP2Off makes http clients use HTTP/1.1 only
+	HTTP2Off = shared.HTTP2Off
+	// HTTP2Attempt makes http clients negotiate HTTP/2 over TLS and fall back to HTTP/1.1
+	HTTP2Attempt = shared.HTTP2Attempt
+	// HTTP2Required makes http clients use HTTP/2 only, including h2c for plain HTTP
+	HTTP2Required = shared.HTTP2Required
+
+	// StateNew background node discovery is not started yet
+	StateNew = shared.StateNew
+	// StateRunning background node discovery is running
+	StateRunning = shared.StateRunning
+	// StateStopped background node discovery is stopped by `Stop`, it can be started again by `Start`
+	StateStopped = shared.StateStopped
+	// StateClosed helper is closed by `Close`
+	StateClosed = shared.StateClosed
+)
+
 var (
 	// WithScheme changes schema (http/https) for both dynamodb and alternator requests
 	WithScheme = shared.WithScheme
@@ -79,6 +133,10 @@ var (
 	// WithCredentials provides credentials to DynamoDB client, which could be used by Alternator as well
 	WithCredentials = shared.WithCredentials
 
+	// WithCredentialsProvider provides DynamoDB client with credentials retrieved from the provider,
+	// they are cached until they expire, so that rotated credentials take effect without restart
+	WithCredentialsProvider = shared.WithCredentialsProvider
+
 	// WithClientCertificateFile provides client certificates http clients for both DynamoDB and Alternator requests
 	// from files
 	WithClientCertificateFile = shared.WithClientCertificateFile
@@ -87,6 +145,18 @@ var (
 	// in a form of `tls.Certificate`
 	WithClientCertificate = shared.WithClientCertificate
 
+	// WithClientCertificatePEM provides client certificates http clients for both DynamoDB and Alternator requests
+	// in PEM format from a callback
+	WithClientCertificatePEM = shared.WithClientCertificatePEM
+
+	// WithClientCertificatePKCS12File provides client certificates http clients for both DynamoDB and Alternator
+	// requests from a password protected PKCS#12 file
+	WithClientCertificatePKCS12File = shared.WithClientCertificatePKCS12File
+
+	// WithClientCertificateDir provides client certificates http clients for both DynamoDB and Alternator requests
+	// from tls.crt and tls.key files in a directory, e.g. a mounted Kubernetes secret
+	WithClientCertificateDir = shared.WithClientCertificateDir
+
 	// WithClientCertificateSource provides client certificates http clients for both DynamoDB and Alternator requests
 	// in a form of custom implementation of `CertSource` interface
 	WithClientCertificateSource = shared.WithClientCertificateSource
@@ -94,6 +164,63 @@ var (
 	// WithIgnoreServerCertificateError makes both http clients ignore tls error when value is true
 	WithIgnoreServerCertificateError = shared.WithIgnoreServerCertificateError
 
+	// WithRootCAFile makes http clients verify server certificates against root CAs from a PEM file,
+	// file is read again when it changes
+	WithRootCAFile = shared.WithRootCAFile
+
+	// WithRootCAs makes http clients verify server certificates against provided root CAs
+	WithRootCAs = shared.WithRootCAs
+
+	// WithRootCASource makes http clients verify server certificates against root CAs
+	// from a custom implementation of `RootCASource` interface
+	WithRootCASource = shared.WithRootCASource
+
+	// WithTLSServerName makes http clients verify server certificates against provided name instead of node address
+	WithTLSServerName = shared.WithTLSServerName
+
+	// WithSANVerifier overrides a strategy to check that server certificate belongs to the node
+	WithSANVerifier = shared.WithSANVerifier
+
+	// WithCertificatePins pins server certificates by base64 encoded SHA-256 hashes of their SubjectPublicKeyInfo
+	WithCertificatePins = shared.WithCertificatePins
+
+	// WithVerifyPeerCertificate adds custom server certificate verification
+	WithVerifyPeerCertificate = shared.WithVerifyPeerCertificate
+
+	// WithCertificateExpiryMonitor overrides monitor of client and server certificates expiration time,
+	// nil disables monitoring
+	WithCertificateExpiryMonitor = shared.WithCertificateExpiryMonitor
+
+	// WithTLSPolicy restricts TLS versions, cipher suites and curves for both http clients to a preset
+	WithTLSPolicy = shared.WithTLSPolicy
+
+	// WithTLSMinVersion overrides minimal TLS version of the policy
+	WithTLSMinVersion = shared.WithTLSMinVersion
+
+	// WithTLSMaxVersion overrides maximal TLS version of the policy
+	WithTLSMaxVersion = shared.WithTLSMaxVersion
+
+	// WithTLSCipherSuites overrides TLS 1.2 cipher suites of the policy
+	WithTLSCipherSuites = shared.WithTLSCipherSuites
+
+	// WithTLSCurvePreferences overrides key exchange curves of the policy
+	WithTLSCurvePreferences = shared.WithTLSCurvePreferences
+
+	// WithRequestCompression makes DynamoDB client gzip compress request bodies
+	WithRequestCompression = shared.WithRequestCompression
+
+	// WithRequestCompressionMinSize sets minimal size of request body to be compressed
+	WithRequestCompressionMinSize = shared.WithRequestCompressionMinSize
+
+	// WithResponseDecompression makes DynamoDB client request gzip encoded responses and decompress them
+	WithResponseDecompression = shared.WithResponseDecompression
+
+	// WithOptimizeHeaders makes DynamoDB client remove headers not used by Alternator reducing outgoing traffic
+	WithOptimizeHeaders = shared.WithOptimizeHeaders
+
+	// WithCustomOptimizeHeaders makes DynamoDB client remove headers not used by Alternator reducing outgoing traffic
+	WithCustomOptimizeHeaders = shared.WithCustomOptimizeHeaders
+
 	// WithKeyLogWriter makes both (DynamoDB and Alternator) clients to write TLS master key into a file
 	// It helps to debug issues by looking at decoded HTTPS traffic between Alternator and client
 	WithKeyLogWriter = shared.WithKeyLogWriter
@@ -110,9 +237,59 @@ var (
 	// WithIdleHTTPConnectionTimeout controls timeout for idle http connections held by http.Transport
 	WithIdleHTTPConnectionTimeout = shared.WithIdleHTTPConnectionTimeout
 
-	// WithHTTPTransport sets custom transport for http client
+	// WithMaxIdleHTTPConnectionsPerHost controls maximum number of idle http connections held by http.Transport
+	// to every node
+	WithMaxIdleHTTPConnectionsPerHost = shared.WithMaxIdleHTTPConnectionsPerHost
+
+	// WithMaxHTTPConnectionsPerHost limits total number of http connections to every node, 0 means no limit
+	WithMaxHTTPConnectionsPerHost = shared.WithMaxHTTPConnectionsPerHost
+
+	// WithDialTimeout controls how long http clients wait for a connect to a node to complete
+	WithDialTimeout = shared.WithDialTimeout
+
+	// WithDialKeepAlive controls interval between TCP keep-alive probes
+	WithDialKeepAlive = shared.WithDialKeepAlive
+
+	// WithTLSHandshakeTimeout controls how long http clients wait for a TLS handshake to complete
+	WithTLSHandshakeTimeout = shared.WithTLSHandshakeTimeout
+
+	// WithResponseHeaderTimeout controls how long http clients wait for response headers
+	WithResponseHeaderTimeout = shared.WithResponseHeaderTimeout
+
+	// WithHTTP2 controls whether both (DynamoDB and Alternator) http clients use HTTP/2
+	WithHTTP2 = shared.WithHTTP2
+
+	// WithHTTPTransport sets custom transport for both (DynamoDB and Alternator) http clients
 	// For testing purposes only, don't use it on production
 	WithHTTPTransport = shared.WithHTTPTransport
+
+	// WithWarmUpConnections makes DynamoDB client open given number of keep-alive connections to every node
+	// on startup and whenever new node is discovered
+	WithWarmUpConnections = shared.WithWarmUpConnections
+
+	// WithDrainRequestsOnClose makes Close wait for requests in flight to finish before closing connections
+	WithDrainRequestsOnClose = shared.WithDrainRequestsOnClose
+
+	// WithNode returns a context that makes requests performed with it go to the given node
+	WithNode = shared.WithNode
+
+	// WithScope returns a context that makes requests performed with it go to nodes of the given routing scope
+	WithScope = shared.WithScope
+
+	// NewTopologyRegistry creates node discovery to be shared by several helpers created by `NewHelperFromRegistry`
+	NewTopologyRegistry = shared.NewTopologyRegistry
+
+	// WithTableRoute routes requests to tables matching the pattern to nodes of the scope picked by the policy
+	WithTableRoute = shared.WithTableRoute
+
+	// WithBalancingPolicy returns a context that makes requests performed with it go to the node picked by the policy
+	WithBalancingPolicy = shared.WithBalancingPolicy
+
+	// RoundRobin returns a balancing policy that picks nodes in turn
+	RoundRobin = shared.RoundRobin
+
+	// Random returns a balancing policy that picks a random node
+	Random = shared.Random
 )
 
 // AlternatorNodesSource an interface for nodes list provider
@@ -126,7 +303,32 @@ type AlternatorNodesSource interface {
 	Stop()
 }
 
-var _ AlternatorNodesSource = &shared.AlternatorLiveNodes{}
+// ManagedNodesSource an interface for nodes list provider that routes requests by their context,
+// reports readiness and new nodes and can be closed, nodes provider that doesn't implement it
+// gets no per-request routing, connection warm-up of new nodes and readiness checks beyond one update
+type ManagedNodesSource interface {
+	NextNodeForContext(ctx context.Context) url.URL
+	WaitForReady(ctx context.Context, opts shared.ReadyOptions) (shared.ReadyReport, error)
+	OnNewNodes(fn func([]url.URL))
+	Close(ctx context.Context) error
+	State() shared.LifecycleState
+}
+
+// TopologySource an interface for nodes list provider that knows locations of nodes and shares its discovery
+// with views of other routing scopes, helpers created by `Update` share discovery only if provider implements it
+type TopologySource interface {
+	Topology() []shared.NodeInfo
+	View(scope rt.Scope, scheme string, port int) *shared.NodesView
+}
+
+var (
+	_ AlternatorNodesSource = &shared.AlternatorLiveNodes{}
+	_ AlternatorNodesSource = &shared.NodesView{}
+	_ ManagedNodesSource    = &shared.AlternatorLiveNodes{}
+	_ ManagedNodesSource    = &shared.NodesView{}
+	_ TopologySource        = &shared.AlternatorLiveNodes{}
+	_ TopologySource        = &shared.NodesView{}
+)
 
 // Helper manages the integration between the AWS SDK and ScyllaDB's Alternator.
 // It handles dynamic node discovery, rack/datacenter-aware routing, and creates
@@ -141,8 +343,14 @@ var _ AlternatorNodesSource = &shared.AlternatorLiveNodes{}
 // It internally relies on the shared.AlternatorLiveNodes component for tracking
 // and routing to healthy nodes.
 type Helper struct {
-	nodes AlternatorNodesSource
-	cfg   shared.Config
+	nodes      AlternatorNodesSource
+	cfg        shared.Config
+	tracker    *shared.RequestTracker
+	transports *shared.TransportPatcher
+	// warmedTransports is a set of `*http.Transport` connection warm-up has been started for
+	warmedTransports sync.Map
+	// ownsNodes is false for helpers created by `NewHelperFromRegistry`, they don't stop or close shared discovery
+	ownsNodes bool
 }
 
 // NewHelper creates a new Helper instance configured with the provided initial Alternator nodes, in a form of ip or dns name (without port)
@@ -152,18 +360,44 @@ func NewHelper(initialNodes []string, options ...Option) (*Helper, error) {
 	for _, opt := range options {
 		opt(cfg)
 	}
+	if cfg.CredentialsProvider != nil {
+		// Node discovery and clients of the helper share retrieved credentials
+		cfg.CredentialsProvider = shared.NewCachedCredentialsProvider(cfg.CredentialsProvider)
+	}
 
 	nodes, err := shared.NewAlternatorLiveNodes(initialNodes, cfg.ToALNOptions()...)
 	if err != nil {
 		return nil, err
 	}
 
+	tracker := shared.NewRequestTracker()
 	return &Helper{
-		nodes: nodes,
-		cfg:   *cfg,
+		nodes:      nodes,
+		cfg:        *cfg,
+		tracker:    tracker,
+		transports: newTransportPatcher(*cfg, tracker),
+		ownsNodes:  true,
 	}, nil
 }
 
+// NewHelperFromRegistry creates a new Helper that uses node discovery of the registry created by `NewTopologyRegistry`,
+// instead of running its own. Helpers of the same registry share lists of nodes, a list per routing scope.
+// Options that configure node discovery, like TLS settings of discovery requests, are taken from the registry,
+// while routing scope, scheme and port are taken from options. Helper does not stop or close the registry.
+func NewHelperFromRegistry(registry *shared.AlternatorLiveNodes, options ...Option) *Helper {
+	cfg := shared.NewDefaultConfig()
+	for _, opt := range options {
+		opt(cfg)
+	}
+	tracker := shared.NewRequestTracker()
+	return &Helper{
+		nodes:      registry.View(cfg.RoutingScope, cfg.Scheme, cfg.Port),
+		cfg:        *cfg,
+		tracker:    tracker,
+		transports: newTransportPatcher(*cfg, tracker),
+	}
+}
+
 // NextNode returns the next available Alternator node URL
 func (lb *Helper) NextNode() url.URL {
 	return lb.nodes.NextNode()
@@ -175,6 +409,15 @@ func (lb *Helper) GetNodes() []url.URL {
 	return lb.nodes.GetNodes()
 }
 
+// nextNodeForContext returns node the request with ctx is sent to, nodes provider that doesn't implement
+// `ManagedNodesSource` ignores routing set in ctx
+func (lb *Helper) nextNodeForContext(ctx context.Context) url.URL {
+	if source, ok := lb.nodes.(ManagedNodesSource); ok {
+		return source.NextNodeForContext(ctx)
+	}
+	return lb.nodes.NextNode()
+}
+
 // UpdateLiveNodes forces an immediate refresh of the live Alternator nodes list.
 func (lb *Helper) UpdateLiveNodes() error {
 	return lb.nodes.UpdateLiveNodes()
@@ -192,19 +435,97 @@ func (lb *Helper) CheckIfRackDatacenterFeatureIsSupported() (bool, error) {
 	return lb.nodes.CheckIfRackDatacenterFeatureIsSupported()
 }
 
+// WaitForReady reads list of nodes from every seed node and runs checks requested by opts,
+// retrying until they pass or ctx is done. Use it on startup to fail fast when cluster or scope is unreachable
+func (lb *Helper) WaitForReady(ctx context.Context, opts ReadyOptions) (ReadyReport, error) {
+	if source, ok := lb.nodes.(ManagedNodesSource); ok {
+		return source.WaitForReady(ctx, opts)
+	}
+	if err := lb.nodes.UpdateLiveNodes(); err != nil {
+		return ReadyReport{Attempts: 1}, err
+	}
+	report := ReadyReport{Nodes: lb.nodes.GetNodes(), Attempts: 1}
+	if len(report.Nodes) < opts.MinNodes {
+		return report, fmt.Errorf("found %d live nodes, at least %d required", len(report.Nodes), opts.MinNodes)
+	}
+	if opts.CheckRackAndDatacenter {
+		return report, lb.nodes.CheckIfRackAndDatacenterSetCorrectly()
+	}
+	return report, nil
+}
+
 // Start begins background routines used for periodic node discovery and updates.
-// It is not required to start if automatically on first API call
+// It is not required to start it, it is started automatically on first API call, but it is required to restart
+// it after `Stop`
 func (lb *Helper) Start() {
 	lb.nodes.Start()
 }
 
-// Stop stops background routines used for periodic node discovery and updates.
+// Stop stops background routines used for periodic node discovery and updates, they can be started again by `Start`
 func (lb *Helper) Stop() {
-	lb.nodes.Stop()
+	if lb.ownsNodes {
+		lb.nodes.Stop()
+	}
+}
+
+// State returns lifecycle state of background node discovery
+func (lb *Helper) State() LifecycleState {
+	if source, ok := lb.nodes.(ManagedNodesSource); ok {
+		return source.State()
+	}
+	return shared.StateRunning
+}
+
+// Close shuts helper down: http clients created by it reject new requests with `shared.ErrClosed`,
+// requests in flight are waited for if `WithDrainRequestsOnClose` is set, node discovery is stopped,
+// background routines are waited for and idle connections are closed.
+// Helpers created by `Update` share the state, closing one of them closes all.
+// Helpers created by `NewHelperFromRegistry` leave node discovery of the registry running.
+// It is safe to call it multiple times and concurrently, it returns `ctx.Err()` if ctx is done before shutdown ends.
+func (lb *Helper) Close(ctx context.Context) error {
+	err := lb.tracker.Close(ctx, lb.cfg.DrainRequestsOnClose)
+	if lb.ownsNodes {
+		if source, ok := lb.nodes.(ManagedNodesSource); ok {
+			err = errors.Join(err, source.Close(ctx))
+		} else {
+			lb.nodes.Stop()
+		}
+	}
+	return err
 }
 
-// awsConfig produces a conf for the AWS SDK that will integrate the alternator loadbalancing with the AWS SDK.
-func (lb *Helper) awsConfig() (aws.Config, error) {
+// Topology returns discovered nodes along with their datacenter and rack, where they are known,
+// nodes provider that doesn't implement `TopologySource` knows no locations
+func (lb *Helper) Topology() []NodeInfo {
+	if source, ok := lb.nodes.(TopologySource); ok {
+		return source.Topology()
+	}
+	nodes := lb.nodes.GetNodes()
+	out := make([]NodeInfo, len(nodes))
+	for i, node := range nodes {
+		out[i] = NodeInfo{URL: node}
+	}
+	return out
+}
+
+// CertificateExpiries returns expiration time of client and server certificates seen in TLS handshakes so far,
+// ordered by expiration time
+func (lb *Helper) CertificateExpiries() []CertificateExpiry {
+	return lb.cfg.CertificateExpiryMonitor.Expiries()
+}
+
+// AWSConfig produces a conf for the AWS SDK that will integrate the alternator loadbalancing with the AWS SDK.
+// Every call creates a new `HTTPClient` on top of the transport shared by all clients of the helper,
+// it sends every request to the next Alternator node, so the config can be used to create both DynamoDB
+// and DynamoDB Streams clients.
+//
+// Returned config can be customized before it is used, e.g. by setting `Retryer` or `Logger`,
+// `HTTPClient` can be replaced with a client that wraps it, replacing it with unrelated one disables load balancing.
+//
+// Config carries no request handlers, session or clients created from it directly should get them via `AddHandlers`,
+// otherwise routing info is not collected, headers are not removed by `WithOptimizeHeaders`
+// and request bodies are not compressed by `WithRequestCompression`.
+func (lb *Helper) AWSConfig() (aws.Config, error) {
 	cfg := aws.Config{
 		Endpoint: aws.String(
 			fmt.Sprintf("%s://%s:%d", lb.cfg.Scheme, "dynamodb.fake.alterntor.cluster.node", lb.cfg.Port),
@@ -216,65 +537,201 @@ func (lb *Helper) awsConfig() (aws.Config, error) {
 	}
 
 	cfg.HTTPClient = &http.Client{
-		Transport: shared.DefaultHTTPTransport(),
+		Transport: lb.transports.BaseTransport(),
 	}
 
-	err := shared.PatchHTTPClient(lb.cfg, cfg.HTTPClient)
+	err := lb.transports.Patch(cfg.HTTPClient)
 	if err != nil {
 		return cfg, err
 	}
 
-	if lb.cfg.AccessKeyID != "" && lb.cfg.SecretAccessKey != "" {
-		// The third credential below, the session token, is only used for
-		// temporary credentials, and is not supported by Alternator anyway.
-		cfg.Credentials = credentials.NewStaticCredentials(lb.cfg.AccessKeyID, lb.cfg.SecretAccessKey, "")
+	if provider := lb.cfg.GetCredentialsProvider(); provider != nil {
+		cfg.Credentials = newCredentials(provider, lb.cfg.Logger)
 	}
 
-	cfg.HTTPClient.Transport = lb.wrapHTTPTransport(cfg.HTTPClient.Transport)
+	lb.startConnectionWarmer(cfg.HTTPClient.Transport)
+	cfg.HTTPClient.Transport = lb.wrapHTTPTransport(lb.tracker.Track(cfg.HTTPClient.Transport))
 	return cfg, nil
 }
 
-func (lb *Helper) newAWSSession() (*session.Session, error) {
-	cfg, err := lb.awsConfig()
+// AWSSession produces a session for the AWS SDK built from `AWSConfig`, its handlers collect routing info
+// of requests and, if configured, remove headers not used by Alternator and compress request bodies.
+//
+// Custom handlers can be added to `Handlers` of the returned session, clients created from it will run them.
+func (lb *Helper) AWSSession() (*session.Session, error) {
+	cfg, err := lb.AWSConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	return session.NewSessionWithOptions(session.Options{
+	sess, err := session.NewSessionWithOptions(session.Options{
 		Config: cfg,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	lb.AddHandlers(&sess.Handlers)
+	return sess, nil
 }
 
-// Update takes config of current helper, updates its config and creates a new helper with updated config
+// AddHandlers adds handlers `AWSSession` installs to the provided ones, for sessions and clients created
+// from `AWSConfig`: they collect routing info of requests and, if configured, remove headers not used by Alternator
+// and compress request bodies
+func (lb *Helper) AddHandlers(handlers *request.Handlers) {
+	handlers.Build.PushFrontNamed(routingInfoHandler)
+
+	if lb.cfg.OptimizeHeaders != nil {
+		// Headers are removed before request is signed, so that they never end up in SignedHeaders
+		handlers.Sign.PushFrontNamed(lb.optimizeHeadersHandler())
+	}
+
+	if lb.cfg.RequestCompression {
+		// Body is compressed before it is signed, so that signature covers compressed body and Content-Encoding
+		handlers.Sign.PushFrontNamed(lb.requestCompressionHandler())
+	}
+}
+
+func (lb *Helper) optimizeHeadersHandler() request.NamedHandler {
+	allowedHeaders := lb.cfg.AllowedHeaders()
+	removedHeaders := shared.NewRemovedHeadersLogger(lb.cfg.Logger)
+	return request.NamedHandler{
+		Name: "alternator.OptimizeHeadersHandler",
+		Fn: func(r *request.Request) {
+			shared.StripAndLogHeaders(r.HTTPRequest.Header, removedHeaders, allowedHeaders...)
+		},
+	}
+}
+
+func (lb *Helper) requestCompressionHandler() request.NamedHandler {
+	minSize := lb.cfg.RequestCompressionMinSize
+	return request.NamedHandler{
+		Name: "alternator.RequestCompressionHandler",
+		Fn: func(r *request.Request) {
+			// Request is signed again on retries, while its body is already compressed
+			if r.Body == nil || r.HTTPRequest.Header.Get("Content-Encoding") != "" {
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				r.Error = awserr.New(request.ErrCodeSerialization, "failed to read request body", err)
+				return
+			}
+			body, compressed, err := shared.GzipBody(body, minSize)
+			if err != nil {
+				r.Error = awserr.New(request.ErrCodeSerialization, "failed to compress request body", err)
+				return
+			}
+			r.SetBufferBody(body)
+			if compressed {
+				r.HTTPRequest.Header.Set("Content-Encoding", "gzip")
+				r.HTTPRequest.Header.Del("Content-Length")
+			}
+		},
+	}
+}
+
+// newTransportPatcher creates patcher of transports of the helper,
+//
+//	transports of the caller it patches in place are released once the tracker is closed
+func newTransportPatcher(cfg shared.Config, tracker *shared.RequestTracker) *shared.TransportPatcher {
+	patcher := shared.NewTransportPatcher(cfg)
+	tracker.OnClose(patcher.Close)
+	return patcher
+}
+
+// startConnectionWarmer starts warming up connections of the transport, once per underlying `http.Transport`,
+//
+//	so that clients sharing transport don't register more listeners and don't repeat warm-ups
+func (lb *Helper) startConnectionWarmer(transport http.RoundTripper) {
+	if lb.cfg.WarmUpConnections <= 0 {
+		return
+	}
+	base, ok := shared.UnwrapHTTPTransport(transport)
+	if !ok {
+		return
+	}
+	if _, loaded := lb.warmedTransports.LoadOrStore(base, struct{}{}); loaded {
+		return
+	}
+	warmer := shared.NewConnectionWarmer(
+		base,
+		lb.cfg.WarmUpConnections,
+		lb.cfg.MaxIdleHTTPConnections,
+		lb.cfg.Logger,
+	)
+	lb.tracker.OnClose(warmer.Close)
+	if source, ok := lb.nodes.(ManagedNodesSource); ok {
+		source.OnNewNodes(warmer.WarmUp)
+	}
+	warmer.WarmUp(lb.nodes.GetNodes())
+}
+
+// Update takes config of current helper, updates its config and creates a new helper with updated config.
+// New helper shares node discovery with the current one, using a view for its routing scope, scheme and port,
+// if nodes provider implements `TopologySource`
 func (lb *Helper) Update(opts ...Option) *Helper {
 	cfg := lb.cfg
 	for _, opt := range opts {
 		opt(&cfg)
 	}
+	nodes := lb.nodes
+	if source, ok := lb.nodes.(TopologySource); ok {
+		nodes = source.View(cfg.RoutingScope, cfg.Scheme, cfg.Port)
+	}
 	return &Helper{
-		nodes: lb.nodes,
-		cfg:   cfg,
+		nodes:      nodes,
+		cfg:        cfg,
+		tracker:    lb.tracker,
+		transports: newTransportPatcher(cfg, lb.tracker),
+		ownsNodes:  lb.ownsNodes,
 	}
 }
 
 // NewDynamoDB creates a new DynamoDB client preconfigured to route requests to Alternator nodes
 func (lb *Helper) NewDynamoDB() (*dynamodb.DynamoDB, error) {
-	sess, err := lb.newAWSSession()
+	sess, err := lb.AWSSession()
 	if err != nil {
 		return nil, err
 	}
 	return dynamodb.New(sess), nil
 }
 
+// NewDynamoDBStreams creates a new DynamoDB Streams client preconfigured to route requests to Alternator nodes
+func (lb *Helper) NewDynamoDBStreams() (*dynamodbstreams.DynamoDBStreams, error) {
+	sess, err := lb.AWSSession()
+	if err != nil {
+		return nil, err
+	}
+	return dynamodbstreams.New(sess), nil
+}
+
 type roundTripper struct {
 	originalTransport http.RoundTripper
 	lb                *Helper
 }
 
 func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	node := rt.lb.NextNode()
-	req.URL = &node
-	return rt.originalTransport.RoundTrip(req)
+	routed, err := rt.lb.routeTable(req)
+	if err != nil {
+		return nil, err
+	}
+	if routed == req {
+		// Request of the caller is left intact, as `http.RoundTripper` requires
+		routed = req.Clone(req.Context())
+	}
+	node := rt.lb.nextNodeForContext(routed.Context())
+	if routed.Host == "" {
+		// Request is signed for the host of the endpoint, keep it so that the signature stays valid
+		routed.Host = routed.URL.Host
+	}
+	routed.URL = &node
+	return rt.originalTransport.RoundTrip(routed)
+}
+
+// Unwrap returns original transport
+func (rt *roundTripper) Unwrap() http.RoundTripper {
+	return rt.originalTransport
 }
 
 func (lb *Helper) wrapHTTPTransport(original http.RoundTripper) http.RoundTripper {
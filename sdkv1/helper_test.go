@@ -8,11 +8,13 @@ import (
 	"errors"
 	"net/url"
 	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 
 	helper "github.com/scylladb/alternator-client-golang/sdkv1"
@@ -149,6 +151,50 @@ func TestDynamoDBOperations(t *testing.T) {
 			helper.WithIgnoreServerCertificateError(true),
 		)
 	})
+	t.Run("OptimizeHeaders", func(t *testing.T) {
+		testDynamoDBOperations(t, helper.WithPort(httpPort), helper.WithOptimizeHeaders(true))
+	})
+}
+
+func TestOptimizeHeaders(t *testing.T) {
+	h, err := helper.NewHelper(
+		knownNodes,
+		helper.WithPort(httpPort),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithOptimizeHeaders(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+
+	var signedHeaders string
+	ddb.Handlers.Send.PushFront(func(r *request.Request) {
+		_, signedHeaders, _ = strings.Cut(r.HTTPRequest.Header.Get("Authorization"), "SignedHeaders=")
+		signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+	})
+
+	_, err = ddb.DeleteTable(&dynamodb.DeleteTableInput{
+		TableName: aws.String("table-that-does-not-exist"),
+	})
+	if err != nil && !errors.As(err, notFoundErr) {
+		t.Fatalf("unexpected operation error: %v", err)
+	}
+
+	if signedHeaders == "" {
+		t.Fatalf("request was not signed")
+	}
+	allowedHeaders := []string{"host", "x-amz-target", "content-length", "accept-encoding", "authorization", "x-amz-date"}
+	for _, header := range strings.Split(signedHeaders, ";") {
+		if !slices.Contains(allowedHeaders, header) {
+			t.Errorf("header %q should have been removed before signing", header)
+		}
+	}
 }
 
 type KeyWriter struct {
@@ -0,0 +1,43 @@
+package sdkv1
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+// RoutingInfo describes how a request was routed: node that served it, number of attempts and routing scope tier
+type RoutingInfo = shared.RoutingInfo
+
+// routingInfoHandler makes every request collect routing info of its attempts
+var routingInfoHandler = request.NamedHandler{
+	Name: "alternator.RoutingInfoHandler",
+	Fn: func(r *request.Request) {
+		if shared.RoutingRecorderFromContext(r.Context()) != nil {
+			return
+		}
+		ctx, _ := shared.WithRoutingRecorder(r.Context())
+		r.SetContext(ctx)
+	},
+}
+
+// ServedBy returns routing info of the sent request
+func ServedBy(r *request.Request) (RoutingInfo, bool) {
+	return shared.RoutingRecorderFromContext(r.Context()).Info()
+}
+
+// WithServedBy returns `request.Option` that stores routing info of the request into `info` once it is complete,
+//
+//	for operations performed via `*WithContext` methods:
+//
+//	var info sdkv1.RoutingInfo
+//	out, err := ddb.GetItemWithContext(ctx, input, sdkv1.WithServedBy(&info))
+func WithServedBy(info *RoutingInfo) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			if served, ok := ServedBy(r); ok {
+				*info = served
+			}
+		})
+	}
+}
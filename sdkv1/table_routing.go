@@ -0,0 +1,116 @@
+package sdkv1
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// tableOperations is a set of operations which request body can name a table, bodies of other requests are not read
+var tableOperations = map[string]struct{}{
+	"BatchGetItem":                        {},
+	"BatchWriteItem":                      {},
+	"CreateBackup":                        {},
+	"CreateTable":                         {},
+	"DeleteItem":                          {},
+	"DeleteTable":                         {},
+	"DescribeContinuousBackups":           {},
+	"DescribeContributorInsights":         {},
+	"DescribeKinesisStreamingDestination": {},
+	"DescribeTable":                       {},
+	"DescribeTableReplicaAutoScaling":     {},
+	"DescribeTimeToLive":                  {},
+	"DisableKinesisStreamingDestination":  {},
+	"EnableKinesisStreamingDestination":   {},
+	"GetItem":                             {},
+	"ListBackups":                         {},
+	"ListStreams":                         {},
+	"PutItem":                             {},
+	"Query":                               {},
+	"Scan":                                {},
+	"UpdateContinuousBackups":             {},
+	"UpdateContributorInsights":           {},
+	"UpdateItem":                          {},
+	"UpdateKinesisStreamingDestination":   {},
+	"UpdateTable":                         {},
+	"UpdateTableReplicaAutoScaling":       {},
+	"UpdateTimeToLive":                    {},
+}
+
+// tableOfRequest is a part of DynamoDB request body that names table it targets
+type tableOfRequest struct {
+	TableName    string                     `json:"TableName"`
+	RequestItems map[string]json.RawMessage `json:"RequestItems"`
+}
+
+// routeTable applies route of the table request targets to context of a copy of the request,
+//
+//	see `shared.WithTableRoute`, table is taken from a copy of the JSON body returned by `GetBody`,
+//	so that the request is left intact, bodies of operations that can't name a table are not read.
+//	Body of the request is closed, as `http.RoundTripper` does, when the copy is returned
+func (lb *Helper) routeTable(req *http.Request) (*http.Request, error) {
+	if len(lb.cfg.TableRoutes) == 0 || req.GetBody == nil {
+		return req, nil
+	}
+	_, operation, _ := strings.Cut(req.Header.Get("X-Amz-Target"), ".")
+	if _, ok := tableOperations[operation]; !ok {
+		return req, nil
+	}
+	table, err := tableOfBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := req.Context()
+	if table != "" {
+		ctx = lb.cfg.TableRoutes.Apply(ctx, table)
+	}
+	// Body of the original request may be consumed or closed by `GetBody`, the way aws-sdk-go does it,
+	// so the copy is sent with a body of its own
+	routed := req.Clone(ctx)
+	if routed.Body, err = req.GetBody(); err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return routed, nil
+}
+
+// tableOfBody returns table named by a copy of the request body, body compressed by the helper is decompressed,
+//
+//	empty string is returned if body is not a JSON that names a single table
+func tableOfBody(req *http.Request) (string, error) {
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = body.Close() }()
+
+	reader := io.Reader(body)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return "", nil
+		}
+		defer func() { _ = gz.Close() }()
+		reader = gz
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", nil
+	}
+
+	var parsed tableOfRequest
+	if err = json.Unmarshal(data, &parsed); err != nil {
+		return "", nil
+	}
+	if parsed.TableName == "" && len(parsed.RequestItems) == 1 {
+		for name := range parsed.RequestItems {
+			return name, nil
+		}
+	}
+	return parsed.TableName, nil
+}
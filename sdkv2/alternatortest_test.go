@@ -0,0 +1,1177 @@
+package sdkv2_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/alternatortest"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+
+	helper "github.com/scylladb/alternator-client-golang/sdkv2"
+)
+
+func TestFakeCluster(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 3))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts()[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	if err = h.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+	}
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+
+	ctx := context.Background()
+	const tableName = "test_table"
+	_, err = ddb.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("Seq"), KeyType: types.KeyTypeRange},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("Seq"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	for _, seq := range []string{"1", "2", "10"} {
+		_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(tableName),
+			Item: map[string]types.AttributeValue{
+				"ID":  &types.AttributeValueMemberS{Value: "123"},
+				"Seq": &types.AttributeValueMemberN{Value: seq},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create record: %v", err)
+		}
+	}
+
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"ID":  &types.AttributeValueMemberS{Value: "123"},
+			"Seq": &types.AttributeValueMemberN{Value: "2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to read record: %v", err)
+	}
+	if result.Item == nil {
+		t.Fatalf("no item found")
+	}
+
+	query, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String("ID = :id AND #seq > :seq"),
+		ExpressionAttributeNames: map[string]string{
+			"#seq": "Seq",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":  &types.AttributeValueMemberS{Value: "123"},
+			":seq": &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to query records: %v", err)
+	}
+	if query.Count != 2 {
+		t.Fatalf("expected 2 records, got %d", query.Count)
+	}
+
+	scan, err := ddb.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String(tableName)})
+	if err != nil {
+		t.Fatalf("failed to scan records: %v", err)
+	}
+	if scan.Count != 3 {
+		t.Fatalf("expected 3 records, got %d", scan.Count)
+	}
+
+	_, err = ddb.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		t.Fatalf("failed to delete table: %v", err)
+	}
+
+	_, err = ddb.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(tableName)})
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected ResourceNotFoundException, got %v", err)
+	}
+
+	for _, node := range cluster.Nodes() {
+		if node.Requests() == 0 {
+			t.Errorf("node %s has not received any requests", node.Host())
+		}
+	}
+}
+
+func TestPerRequestRouting(t *testing.T) {
+	cluster := alternatortest.StartCluster(t, append(
+		alternatortest.Topology("dc1", "rack1", 2),
+		alternatortest.Topology("dc2", "rack1", 2)...,
+	))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts()[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithRoutingScope(rt.NewDCScope("dc1", nil)),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	if err = h.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+	}
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+
+	describe := func(ctx context.Context) {
+		t.Helper()
+		_, err := ddb.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			t.Fatalf("expected ResourceNotFoundException, got %v", err)
+		}
+	}
+
+	requests := func() []int64 {
+		var out []int64
+		for _, node := range cluster.Nodes() {
+			out = append(out, node.Requests())
+		}
+		return out
+	}
+
+	t.Run("WithScope", func(t *testing.T) {
+		before := requests()
+		ctx := helper.WithScope(context.Background(), rt.NewDCScope("dc2", nil))
+		for range 4 {
+			describe(ctx)
+		}
+		after := requests()
+		// list of dc2 nodes is discovered through dc1 nodes, so only dc2 nodes are checked
+		for i, node := range cluster.Nodes() {
+			if node.Datacenter == "dc2" && after[i] == before[i] {
+				t.Errorf("node %s from dc2 has not received any requests", node.Host())
+			}
+		}
+	})
+
+	t.Run("WithNode", func(t *testing.T) {
+		target := cluster.Node(3)
+		before := target.Requests()
+		ctx := helper.WithNode(context.Background(), target.URL())
+		for range 3 {
+			describe(ctx)
+		}
+		if got := target.Requests() - before; got != 3 {
+			t.Errorf("expected node %s to receive 3 requests, got %d", target.Host(), got)
+		}
+	})
+}
+
+func TestServedBy(t *testing.T) {
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("dc1", "rack1", 2))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts()[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithRoutingScope(rt.NewRackScope("dc1", "rack2", rt.NewDCScope("dc1", nil))),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	if err = h.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+	}
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+
+	const tableName = "served_by"
+	_, err = ddb.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	t.Run("Output", func(t *testing.T) {
+		target := cluster.Node(1)
+		target.InjectFault(alternatortest.Times(1, alternatortest.InternalServerError()))
+		defer target.ClearFaults()
+
+		out, err := ddb.GetItem(helper.WithNode(context.Background(), target.URL()), &dynamodb.GetItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]types.AttributeValue{
+				"ID": &types.AttributeValueMemberS{Value: "123"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to read record: %v", err)
+		}
+		info, ok := helper.ServedBy(out.ResultMetadata)
+		if !ok {
+			t.Fatalf("no routing info in result metadata")
+		}
+		if info.Node != target.URL() {
+			t.Errorf("expected request to be served by %s, got %s", target.URL().Host, info.Node.Host)
+		}
+		if info.Attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", info.Attempts)
+		}
+		if info.Scope != nil {
+			t.Errorf("expected no scope for pinned node, got %s", info.Scope)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := ddb.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			t.Fatalf("expected ResourceNotFoundException, got %v", err)
+		}
+		info, ok := helper.ServedByError(err)
+		if !ok {
+			t.Fatalf("no routing info in error")
+		}
+		if cluster.NodeByHost(info.Node.Hostname()) == nil {
+			t.Errorf("request served by unknown node %s", info.Node.Host)
+		}
+		if info.Attempts != 1 {
+			t.Errorf("expected 1 attempt, got %d", info.Attempts)
+		}
+		// rack2 has no nodes, so nodes were discovered in fallback scope
+		if info.Scope == nil || info.Scope.String() != rt.NewDCScope("dc1", nil).String() {
+			t.Errorf("expected nodes from datacenter scope, got %v", info.Scope)
+		}
+	})
+}
+
+func TestDynamoDBStreams(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 2))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts()[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithOptimizeHeaders(true),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	streams, err := h.NewDynamoDBStreams()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB Streams client: %v", err)
+	}
+
+	ctx := context.Background()
+	const tableName = "streams_table"
+	_, err = ddb.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+		StreamSpecification: &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewTypeNewAndOldImages,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	out, err := streams.ListStreams(ctx, &dynamodbstreams.ListStreamsInput{TableName: aws.String(tableName)})
+	if err != nil {
+		t.Fatalf("failed to list streams: %v", err)
+	}
+	if len(out.Streams) != 1 || aws.ToString(out.Streams[0].TableName) != tableName {
+		t.Fatalf("expected single stream of %s, got %v", tableName, out.Streams)
+	}
+
+	info, ok := helper.ServedBy(out.ResultMetadata)
+	if !ok || cluster.NodeByHost(info.Node.Hostname()) == nil {
+		t.Errorf("expected request to be routed to one of the nodes, got %v", info.Node)
+	}
+}
+
+func TestAWSConfig(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 3))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts()[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	if err = h.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+	}
+
+	cfg, err := h.AWSConfig()
+	if err != nil {
+		t.Fatalf("failed to create aws config: %v", err)
+	}
+	var calls atomic.Int64
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc(
+			"CountCalls",
+			func(
+				ctx context.Context,
+				in middleware.InitializeInput,
+				next middleware.InitializeHandler,
+			) (middleware.InitializeOutput, middleware.Metadata, error) {
+				calls.Add(1)
+				return next.HandleInitialize(ctx, in)
+			},
+		), middleware.After)
+	})
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	const requests = 6
+	for range requests {
+		_, err := ddb.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{
+			TableName: aws.String("missing"),
+		})
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			t.Fatalf("expected ResourceNotFoundException, got %v", err)
+		}
+	}
+
+	if calls.Load() != requests {
+		t.Errorf("expected custom middleware to be called %d times, got %d", requests, calls.Load())
+	}
+	for _, node := range cluster.Nodes() {
+		if node.Requests() == 0 {
+			t.Errorf("node %s has not received any requests", node.Host())
+		}
+	}
+}
+
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewDynamoDBFromConfig(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 2))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts()[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	t.Cleanup(h.Stop)
+
+	t.Run("KeepsBaseSettings", func(t *testing.T) {
+		t.Parallel()
+		var retryers atomic.Int64
+		base := aws.Config{
+			Region:     "eu-west-1",
+			HTTPClient: awshttp.NewBuildableClient(),
+			Retryer: func() aws.Retryer {
+				retryers.Add(1)
+				return retry.NewStandard()
+			},
+		}
+		ddb, err := h.NewDynamoDBFromConfig(base, func(o *dynamodb.Options) {
+			o.RetryMaxAttempts = 2
+		})
+		if err != nil {
+			t.Fatalf("failed to create DynamoDB client: %v", err)
+		}
+		if ddb.Options().Region != "eu-west-1" {
+			t.Errorf("expected region of base config to be kept, got %q", ddb.Options().Region)
+		}
+		if retryers.Load() == 0 {
+			t.Errorf("expected retryer of base config to be used")
+		}
+
+		_, err = ddb.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{
+			TableName: aws.String("missing"),
+		})
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			t.Fatalf("expected ResourceNotFoundException, got %v", err)
+		}
+		if _, ok := helper.ServedByError(err); !ok {
+			t.Errorf("expected request to be routed by the helper")
+		}
+	})
+
+	t.Run("ClonesTransportOnce", func(t *testing.T) {
+		t.Parallel()
+		userTransport := &http.Transport{}
+		for name, client := range map[string]aws.HTTPClient{
+			"HTTPClient":      &http.Client{Transport: userTransport},
+			"BuildableClient": awshttp.NewBuildableClient(),
+		} {
+			var first *http.Transport
+			for range 3 {
+				ddb, err := h.NewDynamoDBFromConfig(aws.Config{HTTPClient: client})
+				if err != nil {
+					t.Fatalf("failed to create DynamoDB client: %v", err)
+				}
+				transport, ok := shared.UnwrapHTTPTransport(ddb.Options().HTTPClient.(*http.Client).Transport)
+				if !ok {
+					t.Fatalf("%s: expected client transport to wrap *http.Transport", name)
+				}
+				if transport == userTransport {
+					t.Fatalf("%s: expected transport of the caller to be cloned", name)
+				}
+				if first == nil {
+					first = transport
+				} else if transport != first {
+					t.Fatalf("%s: expected transport of the caller to be cloned once", name)
+				}
+			}
+		}
+	})
+
+	conflicts := map[string]struct {
+		base   aws.Config
+		optFns []func(*dynamodb.Options)
+	}{
+		"HTTPClient": {
+			base: aws.Config{HTTPClient: doerFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("not expected to be called")
+			})},
+		},
+		"BaseEndpoint": {
+			base: aws.Config{BaseEndpoint: aws.String("http://127.0.0.1:8000")},
+		},
+		"OptionsBaseEndpoint": {
+			optFns: []func(*dynamodb.Options){func(o *dynamodb.Options) {
+				o.BaseEndpoint = aws.String("http://127.0.0.1:8000")
+			}},
+		},
+	}
+	for name, tc := range conflicts {
+		t.Run("Conflict"+name, func(t *testing.T) {
+			t.Parallel()
+			_, err := h.NewDynamoDBFromConfig(tc.base, tc.optFns...)
+			if !errors.Is(err, helper.ErrConfigConflict) {
+				t.Fatalf("expected ErrConfigConflict, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCredentialsProvider(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+
+	var keys []string
+	// Node discovery signs its requests too, only requests of the client are recorded
+	recordKey := func(_ http.ResponseWriter, r *http.Request) bool {
+		if _, cred, ok := strings.Cut(r.Header.Get("Authorization"), "Credential="); ok {
+			key, _, _ := strings.Cut(cred, "/")
+			keys = append(keys, key)
+		}
+		return false
+	}
+	cluster.Node(0).InjectFault(alternatortest.OnOperation("DescribeTable", recordKey))
+
+	var rotations atomic.Int64
+	provider := shared.NewCallbackCredentialsProvider(func(context.Context) (shared.Credentials, error) {
+		n := rotations.Add(1)
+		return shared.Credentials{
+			AccessKeyID:     fmt.Sprintf("key%d", n),
+			SecretAccessKey: "secret",
+			Expires:         time.Now().Add(100 * time.Millisecond),
+		}, nil
+	}, 0)
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentialsProvider(provider),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	describe := func() {
+		t.Helper()
+		_, err := ddb.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{
+			TableName: aws.String("missing"),
+		})
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			t.Fatalf("expected ResourceNotFoundException, got %v", err)
+		}
+	}
+
+	describe()
+	describe()
+	time.Sleep(150 * time.Millisecond)
+	describe()
+
+	if want := []string{"key1", "key1", "key2"}; !slices.Equal(keys, want) {
+		t.Fatalf("expected requests to be signed with %v, got %v", want, keys)
+	}
+}
+
+// removedHeadersRecorder records sets of headers removed by the helper
+type removedHeadersRecorder struct {
+	logx.Noop
+	lock    sync.Mutex
+	removed []string
+}
+
+func (r *removedHeadersRecorder) Debug(_ string, attrs ...logx.Attr) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, attr := range attrs {
+		if headers, ok := attr.Value.([]string); ok {
+			r.removed = append(r.removed, strings.Join(headers, ","))
+		}
+	}
+}
+
+func (r *removedHeadersRecorder) Enabled(logx.Level) bool {
+	return true
+}
+
+func TestOptimizeHeadersSigning(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+	cluster.Node(0).InjectFault(alternatortest.RequireSigV4("key", "secret"))
+
+	logger := &removedHeadersRecorder{}
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("key", "secret"),
+		helper.WithOptimizeHeaders(true),
+		helper.WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	for range 3 {
+		_, err = ddb.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			t.Fatalf("expected headers to be removed before request is signed, got %v", err)
+		}
+	}
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+	if len(logger.removed) == 0 {
+		t.Fatal("expected removed headers to be logged")
+	}
+	for i, removed := range logger.removed {
+		if slices.Contains(logger.removed[:i], removed) {
+			t.Fatalf("expected every set of removed headers to be logged once, got %v", logger.removed)
+		}
+	}
+}
+
+func TestOptimizeHeadersWithCredentialsProvider(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+	cluster.Node(0).InjectFault(alternatortest.RequireSigV4("key", "secret"))
+
+	provider := shared.NewCallbackCredentialsProvider(func(context.Context) (shared.Credentials, error) {
+		return shared.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+	}, 0)
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentialsProvider(provider),
+		helper.WithOptimizeHeaders(true),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	_, err = ddb.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected signed request to reach the table lookup, got %v", err)
+	}
+}
+
+func TestOptimizeHeadersWithSessionToken(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+	cluster.Node(0).InjectFault(alternatortest.RequireSigV4("key", "secret"))
+
+	provider := shared.NewCallbackCredentialsProvider(func(context.Context) (shared.Credentials, error) {
+		return shared.Credentials{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token"}, nil
+	}, 0)
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentialsProvider(provider),
+		helper.WithOptimizeHeaders(true),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	// Signed X-Amz-Security-Token header has to reach the node
+	_, err = ddb.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected signed request to reach the table lookup, got %v", err)
+	}
+}
+
+func TestRequestCompressionSigning(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 1))
+	var compressed atomic.Int64
+	cluster.Node(0).InjectFault(
+		func(_ http.ResponseWriter, r *http.Request) bool {
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				compressed.Add(1)
+			}
+			return false
+		},
+		alternatortest.RequireSigV4("key", "secret"),
+	)
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("key", "secret"),
+		helper.WithOptimizeHeaders(true),
+		helper.WithRequestCompression(true),
+		helper.WithRequestCompressionMinSize(1),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	ctx := context.Background()
+	const tableName = "compressed_table"
+	_, err = ddb.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"ID":    &types.AttributeValueMemberS{Value: "123"},
+			"Value": &types.AttributeValueMemberS{Value: strings.Repeat("value", 100)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create record: %v", err)
+	}
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: "123"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to read record: %v", err)
+	}
+	if result.Item == nil {
+		t.Fatalf("no item found")
+	}
+	if got := compressed.Load(); got != 3 {
+		t.Errorf("expected 3 compressed requests, got %d", got)
+	}
+}
+
+func TestResponseDecompressionAcceptsGzip(t *testing.T) {
+	t.Parallel()
+
+	// Client asks for identity encoding by default, compression transport keeps Accept-Encoding that is set
+	for _, enabled := range []bool{false, true} {
+		h, err := helper.NewHelper(
+			[]string{"127.0.0.1"},
+			helper.WithResponseDecompression(enabled),
+			helper.WithLogger(logx.Noop{}),
+		)
+		if err != nil {
+			t.Fatalf("failed to create alternator helper: %v", err)
+		}
+		ddb, err := h.NewDynamoDB()
+		if err != nil {
+			t.Fatalf("failed to create DynamoDB client: %v", err)
+		}
+		if got := ddb.Options().EnableAcceptEncodingGzip; got != enabled {
+			t.Errorf("expected EnableAcceptEncodingGzip to be %t, got %t", enabled, got)
+		}
+		ddb, err = h.NewDynamoDBFromConfig(aws.Config{})
+		if err != nil {
+			t.Fatalf("failed to create DynamoDB client from config: %v", err)
+		}
+		if got := ddb.Options().EnableAcceptEncodingGzip; got != enabled {
+			t.Errorf("expected EnableAcceptEncodingGzip of client from config to be %t, got %t", enabled, got)
+		}
+		h.Stop()
+	}
+}
+
+func TestHTTPTransportPatchedOnce(t *testing.T) {
+	t.Parallel()
+
+	var depth int
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			VerifyConnection: func(tls.ConnectionState) error {
+				depth = verifyConnectionDepth()
+				return nil
+			},
+		},
+	}
+	h, err := helper.NewHelper(
+		[]string{"127.0.0.1"},
+		helper.WithHTTPTransport(transport),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	var ddb *dynamodb.Client
+	for range 5 {
+		if ddb, err = h.NewDynamoDB(); err != nil {
+			t.Fatalf("failed to create DynamoDB client: %v", err)
+		}
+	}
+	patched, ok := shared.UnwrapHTTPTransport(ddb.Options().HTTPClient.(*http.Client).Transport)
+	if !ok {
+		t.Fatal("expected DynamoDB client to use http.Transport")
+	}
+	if patched == transport {
+		t.Fatal("expected transport of the caller to be cloned")
+	}
+	if err = patched.TLSClientConfig.VerifyConnection(tls.ConnectionState{}); err != nil || depth != 1 {
+		t.Errorf("expected VerifyConnection to be wrapped once, got depth %d, error %v", depth, err)
+	}
+	if err = transport.TLSClientConfig.VerifyConnection(tls.ConnectionState{}); err != nil || depth != 0 {
+		t.Errorf("expected transport of the caller to be left intact, got depth %d, error %v", depth, err)
+	}
+	if _, err = h.Update(helper.WithCredentials("whatever", "secret")).NewDynamoDB(); err != nil {
+		t.Errorf("failed to create DynamoDB client of updated helper: %v", err)
+	}
+}
+
+// verifyConnectionDepth returns number of VerifyConnection wrappers installed by the helper on the call stack
+func verifyConnectionDepth() int {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	depth := 0
+	for {
+		frame, more := frames.Next()
+		if strings.Contains(frame.Function, "shared.PatchBasicHTTPTransport") {
+			depth++
+		}
+		if !more {
+			return depth
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 2))
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithWarmUpConnections(2),
+		helper.WithDrainRequestsOnClose(true),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+	describe := func() error {
+		_, err := ddb.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{
+			TableName: aws.String("missing"),
+		})
+		return err
+	}
+
+	for _, node := range cluster.Nodes() {
+		node.InjectFault(alternatortest.OnOperation("DescribeTable", alternatortest.Latency(200*time.Millisecond)))
+	}
+	inFlight := make(chan error, 1)
+	go func() {
+		inFlight <- describe()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	closed := make(chan error, 2)
+	for range 2 {
+		go func() {
+			closed <- h.Close(ctx)
+		}()
+	}
+	for range 2 {
+		if err = <-closed; err != nil {
+			t.Fatalf("Close unexpectedly failed: %v", err)
+		}
+	}
+
+	select {
+	case err = <-inFlight:
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			t.Fatalf("expected request in flight to complete, got %v", err)
+		}
+	default:
+		t.Fatalf("Close returned before request in flight has completed")
+	}
+
+	if err = describe(); !errors.Is(err, shared.ErrClosed) {
+		t.Fatalf("expected ErrClosed for request sent after Close, got %v", err)
+	}
+}
+
+func TestWaitForReady(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, alternatortest.Topology("datacenter1", "rack1", 3))
+	cluster.Node(0).SetDown(true)
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithRoutingScope(rt.NewRackScope("datacenter1", "rack1", nil)),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report, err := h.WaitForReady(ctx, helper.ReadyOptions{CheckRackAndDatacenter: true, MinNodes: 2})
+	if err != nil {
+		t.Fatalf("WaitForReady() unexpectedly returned an error: %v", err)
+	}
+	if !report.PrimaryScope || len(report.Nodes) != 2 {
+		t.Errorf("expected 2 nodes in the primary scope, got %v in %v", report.Nodes, report.Scope)
+	}
+	if len(report.FailedSeeds) != 1 || report.FailedSeeds[0].Seed.Hostname() != cluster.Node(0).Host() {
+		t.Errorf("expected seed %s to fail, got %v", cluster.Node(0).Host(), report.FailedSeeds)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err = h.WaitForReady(ctx, helper.ReadyOptions{MinNodes: 3}); err == nil {
+		t.Fatalf("expected WaitForReady() to fail when scope has not enough nodes")
+	}
+}
+
+func TestTopologyRegistry(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+		{Datacenter: "dc1", Rack: "rack1"},
+		{Datacenter: "dc1", Rack: "rack2"},
+	})
+	hosts := cluster.Hosts()
+
+	registry, err := helper.NewTopologyRegistry(
+		hosts[:1],
+		helper.WithPort(cluster.Port()),
+		helper.WithRoutingScope(rt.NewDCScope("dc1", nil)),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create topology registry: %v", err)
+	}
+	defer registry.Stop()
+
+	rack1 := helper.NewHelperFromRegistry(registry, helper.WithRoutingScope(rt.NewRackScope("dc1", "rack1", nil)))
+	rack2 := rack1.Update(helper.WithRoutingScope(rt.NewRackScope("dc1", "rack2", nil)), helper.WithPort(9999))
+	// Lists of scopes are discovered in background, helpers wait for them before routing
+	for _, h := range []*helper.Helper{rack1, rack2} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = h.WaitForReady(ctx, helper.ReadyOptions{MinNodes: 1})
+		cancel()
+		if err != nil {
+			t.Fatalf("WaitForReady() unexpectedly returned an error: %v", err)
+		}
+	}
+	for range 3 {
+		if node := rack1.NextNode(); node.Hostname() != hosts[0] {
+			t.Fatalf("expected helper to route to %s, got %s", hosts[0], node.String())
+		}
+		if node := rack2.NextNode(); node.Hostname() != hosts[1] || node.Port() != "9999" {
+			t.Fatalf("expected updated helper to route to %s:9999, got %s", hosts[1], node.String())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = rack1.Close(ctx); err != nil {
+		t.Fatalf("Close() unexpectedly returned an error: %v", err)
+	}
+	if state := registry.State(); state == helper.StateClosed {
+		t.Fatalf("expected helper created from registry to leave it running, got %s", state)
+	}
+}
+
+func TestTableRouting(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+		{Datacenter: "dc1", Rack: "rack1"},
+		{Datacenter: "dc1", Rack: "rack2"},
+	})
+	first := cluster.Node(0).URL()
+
+	h, err := helper.NewHelper(
+		cluster.Hosts(),
+		helper.WithPort(cluster.Port()),
+		helper.WithRoutingScope(rt.NewRackScope("dc1", "rack1", nil)),
+		helper.WithTableRoute("hot_*", rt.NewRackScope("dc1", "rack2", nil), nil),
+		helper.WithTableRoute("logs", nil, func([]url.URL) url.URL { return first }),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	t.Cleanup(h.Stop)
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+
+	tcases := []struct {
+		name     string
+		call     func(ctx context.Context) error
+		expected *alternatortest.Node
+	}{
+		{
+			name: "Pattern",
+			call: func(ctx context.Context) error {
+				_, err := ddb.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String("hot_items")})
+				return err
+			},
+			expected: cluster.Node(1),
+		},
+		{
+			name: "BalancingPolicy",
+			call: func(ctx context.Context) error {
+				_, err := ddb.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String("logs")})
+				return err
+			},
+			expected: cluster.Node(0),
+		},
+		{
+			name: "BatchOperation",
+			call: func(ctx context.Context) error {
+				_, err := ddb.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+					RequestItems: map[string]types.KeysAndAttributes{
+						"hot_batch": {Keys: []map[string]types.AttributeValue{
+							{"ID": &types.AttributeValueMemberS{Value: "1"}},
+						}},
+					},
+				})
+				return err
+			},
+			expected: cluster.Node(1),
+		},
+	}
+	// List of nodes of the scope of a route is discovered in background after its first request,
+	// until then requests are sent to nodes of the main list, that has only the other rack
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = h.WaitForReady(ctx, helper.ReadyOptions{MinNodes: 1}); err != nil {
+		t.Fatalf("WaitForReady() unexpectedly returned an error: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		info, _ := helper.ServedByError(tcases[0].call(context.Background()))
+		if info.Node.Host == tcases[0].expected.URL().Host {
+			break
+		}
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			for range 4 {
+				info, ok := helper.ServedByError(tc.call(context.Background()))
+				if !ok {
+					t.Fatalf("no routing info in error")
+				}
+				if info.Node.Host != tc.expected.URL().Host {
+					t.Fatalf("expected request to be served by %s, got %s", tc.expected.URL().Host, info.Node.Host)
+				}
+			}
+		})
+	}
+}
+
+// staticNodes is a nodes list provider that implements only `AlternatorNodesSource`
+type staticNodes []url.URL
+
+func (s staticNodes) NextNode() url.URL                                      { return s[0] }
+func (s staticNodes) GetNodes() []url.URL                                    { return s }
+func (s staticNodes) UpdateLiveNodes() error                                 { return nil }
+func (s staticNodes) CheckIfRackAndDatacenterSetCorrectly() error            { return nil }
+func (s staticNodes) CheckIfRackDatacenterFeatureIsSupported() (bool, error) { return false, nil }
+func (s staticNodes) Start()                                                 {}
+func (s staticNodes) Stop()                                                  {}
+
+func TestNodesSourceInterfaces(t *testing.T) {
+	var source helper.AlternatorNodesSource = staticNodes{{Scheme: "http", Host: "127.0.0.1:8000"}}
+	if _, ok := source.(helper.ManagedNodesSource); ok {
+		t.Fatalf("expected nodes provider with original methods only not to implement ManagedNodesSource")
+	}
+
+	var live any = &shared.AlternatorLiveNodes{}
+	if _, ok := live.(helper.AlternatorNodesSource); !ok {
+		t.Fatalf("expected AlternatorLiveNodes to implement AlternatorNodesSource")
+	}
+	if _, ok := live.(helper.ManagedNodesSource); !ok {
+		t.Fatalf("expected AlternatorLiveNodes to implement ManagedNodesSource")
+	}
+}
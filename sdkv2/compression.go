@@ -0,0 +1,65 @@
+package sdkv2
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+const requestCompressionMiddlewareID = "AlternatorRequestCompression"
+
+// requestCompressionMiddleware returns a middleware that gzip compresses request body before its hash is computed,
+//
+//	so that both payload hash and Content-Encoding header are covered by the signature
+func (lb *Helper) requestCompressionMiddleware() func(stack *middleware.Stack) error {
+	minSize := lb.cfg.RequestCompressionMinSize
+	compress := middleware.FinalizeMiddlewareFunc(
+		requestCompressionMiddlewareID,
+		func(
+			ctx context.Context,
+			in middleware.FinalizeInput,
+			next middleware.FinalizeHandler,
+		) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			req, ok := in.Request.(*smithyhttp.Request)
+			if !ok || req.GetStream() == nil || req.Header.Get("Content-Encoding") != "" ||
+				(req.ContentLength >= 0 && req.ContentLength < int64(minSize)) {
+				return next.HandleFinalize(ctx, in)
+			}
+			body, err := io.ReadAll(req.GetStream())
+			if err != nil {
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+			}
+			body, compressed, err := shared.GzipBody(body, minSize)
+			if err != nil {
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+			}
+			if req, err = req.SetStream(bytes.NewReader(body)); err != nil {
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+			}
+			if compressed {
+				req.ContentLength = int64(len(body))
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			in.Request = req
+			return next.HandleFinalize(ctx, in)
+		},
+	)
+	return func(stack *middleware.Stack) error {
+		return stack.Finalize.Insert(compress, "ComputePayloadHash", middleware.Before)
+	}
+}
+
+// acceptGzipResponses makes DynamoDB client ask for gzip encoded responses if response decompression is enabled,
+//
+//	otherwise client asks for identity encoding, which compression transport leaves as is
+func (lb *Helper) acceptGzipResponses(o *dynamodb.Options) {
+	if lb.cfg.ResponseDecompression {
+		o.EnableAcceptEncodingGzip = true
+	}
+}
@@ -0,0 +1,38 @@
+package sdkv2
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+const credentialsSource = "AlternatorCredentialsProvider"
+
+// credentialsProvider adapts `shared.CredentialsProvider` to `aws.CredentialsProvider`
+type credentialsProvider struct {
+	provider shared.CredentialsProvider
+	logger   logx.Logger
+}
+
+func (p credentialsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := p.provider.Retrieve(ctx, p.logger)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	return aws.Credentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Source:          credentialsSource,
+		CanExpire:       !creds.Expires.IsZero(),
+		Expires:         creds.Expires,
+	}, nil
+}
+
+// newCredentialsProvider returns provider that caches credentials retrieved from `provider` until they expire
+func newCredentialsProvider(provider shared.CredentialsProvider, logger logx.Logger) aws.CredentialsProvider {
+	return aws.NewCredentialsCache(credentialsProvider{provider: provider, logger: logger})
+}
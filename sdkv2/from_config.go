@@ -0,0 +1,108 @@
+package sdkv2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+// ErrConfigConflict is returned by `NewDynamoDBFromConfig` when user supplied config or options
+// contain settings that can't be combined with the helper
+var ErrConfigConflict = errors.New("config conflicts with alternator helper")
+
+// NewDynamoDBFromConfig creates a new DynamoDB client from user supplied config, preconfigured
+// to route requests to Alternator nodes.
+// Settings of the base config, such as retryer, logger or API options, are kept,
+// only http client, endpoint resolution and, if helper has them, credentials are overridden.
+//
+// Http client of the base config is cloned and its transport, cloned once per helper, is patched with helper settings,
+// it has to be either `*http.Client` with a transport that is or wraps `*http.Transport`,
+// or `*awshttp.BuildableClient` that `config.LoadDefaultConfig` creates.
+// `*http.Transport` wrapped by another `http.RoundTripper` can't be cloned, so it is patched in place, once per helper.
+// Settings that would be silently ignored, like custom endpoint in the base config or in `optFns`,
+// are reported as `ErrConfigConflict`.
+func (lb *Helper) NewDynamoDBFromConfig(base aws.Config, optFns ...func(*dynamodb.Options)) (*dynamodb.Client, error) {
+	httpClient, err := lb.httpClientFromConfig(base.HTTPClient)
+	conflicts := []error{err}
+	if base.BaseEndpoint != nil {
+		conflicts = append(conflicts, fmt.Errorf("%w: BaseEndpoint is set", ErrConfigConflict))
+	}
+	if base.EndpointResolverWithOptions != nil { //nolint:staticcheck // deprecated field is checked on purpose
+		conflicts = append(conflicts, fmt.Errorf("%w: EndpointResolverWithOptions is set", ErrConfigConflict))
+	}
+
+	// Options are applied to a blank value to find out what they override
+	var probe dynamodb.Options
+	for _, fn := range optFns {
+		fn(&probe)
+	}
+	if probe.BaseEndpoint != nil {
+		conflicts = append(conflicts, fmt.Errorf("%w: options set BaseEndpoint", ErrConfigConflict))
+	}
+	if probe.EndpointResolverV2 != nil {
+		conflicts = append(conflicts, fmt.Errorf("%w: options set EndpointResolverV2", ErrConfigConflict))
+	}
+	if probe.HTTPClient != nil {
+		conflicts = append(conflicts, fmt.Errorf("%w: options set HTTPClient", ErrConfigConflict))
+	}
+	if err = errors.Join(conflicts...); err != nil {
+		return nil, err
+	}
+
+	if base.Region == "" {
+		base.Region = lb.cfg.AWSRegion
+	}
+	cfg := lb.patchAWSConfig(base, httpClient)
+	optFns = append([]func(*dynamodb.Options){lb.acceptGzipResponses}, optFns...)
+	optFns = append(optFns, dynamodb.WithEndpointResolverV2(lb.endpointResolverV2()))
+	return dynamodb.NewFromConfig(cfg, optFns...), nil
+}
+
+// httpClientFromConfig returns copy of http client of the base config patched by the helper
+func (lb *Helper) httpClientFromConfig(client aws.HTTPClient) (*http.Client, error) {
+	switch c := client.(type) {
+	case nil:
+		return lb.newHTTPClient()
+	case *http.Client:
+		out := *c
+		if out.Transport == nil {
+			out.Transport = lb.transports.BaseTransport()
+			return &out, lb.transports.Patch(&out)
+		}
+		if lb.cfg.HTTPTransport != nil {
+			return nil, fmt.Errorf("%w: both HTTPClient transport and WithHTTPTransport are set", ErrConfigConflict)
+		}
+		if t, ok := out.Transport.(*http.Transport); ok {
+			// Transport is cloned once per helper, since it is going to be patched
+			out.Transport = lb.transports.CloneTransport(t, t.Clone)
+			return &out, lb.transports.Patch(&out)
+		}
+		if _, ok := shared.UnwrapHTTPTransport(out.Transport); !ok {
+			return nil, fmt.Errorf(
+				"%w: HTTPClient transport %T does not wrap *http.Transport",
+				ErrConfigConflict,
+				out.Transport,
+			)
+		}
+		// Wrapped transport is patched in place, only once
+		return &out, lb.transports.Patch(&out)
+	case *awshttp.BuildableClient:
+		if lb.cfg.HTTPTransport != nil {
+			return nil, fmt.Errorf("%w: both HTTPClient and WithHTTPTransport are set", ErrConfigConflict)
+		}
+		out := &http.Client{
+			// Every call of GetTransport returns a new clone, so it is called once per client and helper
+			Transport: lb.transports.CloneTransport(c, c.GetTransport),
+			Timeout:   c.GetTimeout(),
+		}
+		return out, lb.transports.Patch(out)
+	default:
+		return nil, fmt.Errorf("%w: HTTPClient %T is not an *http.Client", ErrConfigConflict, client)
+	}
+}
@@ -31,15 +31,19 @@ package sdkv2
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
 
 	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/rt"
 
 	smithyendpoints "github.com/aws/smithy-go/endpoints"
 )
@@ -47,6 +51,53 @@ import (
 // Option is option for the `NewHelper`
 type Option = shared.Option
 
+// HTTP2Mode controls whether http clients use HTTP/2 to talk to Alternator nodes
+type HTTP2Mode = shared.HTTP2Mode
+
+// CertificateExpiry describes client or server certificate seen by http clients
+type CertificateExpiry = shared.CertificateExpiry
+
+// ReadyOptions configures `Helper.WaitForReady`
+type ReadyOptions = shared.ReadyOptions
+
+// ReadyReport is a result of `Helper.WaitForReady`
+type ReadyReport = shared.ReadyReport
+
+// SeedError describes a seed node that failed to return list of nodes
+type SeedError = shared.SeedError
+
+// LifecycleState a state of background node discovery
+type LifecycleState = shared.LifecycleState
+
+// NodeInfo is a discovered node along with its datacenter and rack, if they are known
+type NodeInfo = shared.NodeInfo
+
+// BalancingPolicy picks a node to send request to out of nodes of a routing scope
+type BalancingPolicy = shared.BalancingPolicy
+
+// TableRoute describes how requests to tables matching the pattern are routed
+type TableRoute = shared.TableRoute
+
+const (
+	// HTTP2Default leaves protocol negotiation up to `http.Transport` defaults
+	HTTP2Default = shared.HTTP2Default
+	// HTTP2Off makes http clients use HTTP/1.1 only
+	HTTP2Off = shared.HTTP2Off
+	// HTTP2Attempt makes http clients negotiate HTTP/2 over TLS and fall back to HTTP/1.1
+	HTTP2Attempt = shared.HTTP2Attempt
+	// HTTP2Required makes http clients use HTTP/2 only, including h2c for plain HTTP
+	HTTP2Required = shared.HTTP2Required
+
+	// StateNew background node discovery is not started yet
+	StateNew = shared.StateNew
+	// StateRunning background node discovery is running
+	StateRunning = shared.StateRunning
+	// StateStopped background node discovery is stopped by `Stop`, it can be started again by `Start`
+	StateStopped = shared.StateStopped
+	// StateClosed helper is closed by `Close`
+	StateClosed = shared.StateClosed
+)
+
 var (
 	// WithScheme changes schema (http/https) for both dynamodb and alternator requests
 	WithScheme = shared.WithScheme
@@ -81,6 +132,10 @@ var (
 	// WithCredentials provides credentials to DynamoDB client, which could be used by Alternator as well
 	WithCredentials = shared.WithCredentials
 
+	// WithCredentialsProvider provides DynamoDB client with credentials retrieved from the provider,
+	// they are cached until they expire, so that rotated credentials take effect without restart
+	WithCredentialsProvider = shared.WithCredentialsProvider
+
 	// WithClientCertificateFile provides client certificates http clients for both DynamoDB and Alternator requests
 	// from files
 	WithClientCertificateFile = shared.WithClientCertificateFile
@@ -89,6 +144,18 @@ var (
 	// in a form of `tls.Certificate`
 	WithClientCertificate = shared.WithClientCertificate
 
+	// WithClientCertificatePEM provides client certificates http clients for both DynamoDB and Alternator requests
+	// in PEM format from a callback
+	WithClientCertificatePEM = shared.WithClientCertificatePEM
+
+	// WithClientCertificatePKCS12File provides client certificates http clients for both DynamoDB and Alternator
+	// requests from a password protected PKCS#12 file
+	WithClientCertificatePKCS12File = shared.WithClientCertificatePKCS12File
+
+	// WithClientCertificateDir provides client certificates http clients for both DynamoDB and Alternator requests
+	// from tls.crt and tls.key files in a directory, e.g. a mounted Kubernetes secret
+	WithClientCertificateDir = shared.WithClientCertificateDir
+
 	// WithClientCertificateSource provides client certificates http clients for both DynamoDB and Alternator requests
 	// in a form of custom implementation of `CertSource` interface
 	WithClientCertificateSource = shared.WithClientCertificateSource
@@ -96,12 +163,63 @@ var (
 	// WithIgnoreServerCertificateError makes both http clients ignore tls error when value is true
 	WithIgnoreServerCertificateError = shared.WithIgnoreServerCertificateError
 
+	// WithRootCAFile makes http clients verify server certificates against root CAs from a PEM file,
+	// file is read again when it changes
+	WithRootCAFile = shared.WithRootCAFile
+
+	// WithRootCAs makes http clients verify server certificates against provided root CAs
+	WithRootCAs = shared.WithRootCAs
+
+	// WithRootCASource makes http clients verify server certificates against root CAs
+	// from a custom implementation of `RootCASource` interface
+	WithRootCASource = shared.WithRootCASource
+
+	// WithTLSServerName makes http clients verify server certificates against provided name instead of node address
+	WithTLSServerName = shared.WithTLSServerName
+
+	// WithSANVerifier overrides a strategy to check that server certificate belongs to the node
+	WithSANVerifier = shared.WithSANVerifier
+
+	// WithCertificatePins pins server certificates by base64 encoded SHA-256 hashes of their SubjectPublicKeyInfo
+	WithCertificatePins = shared.WithCertificatePins
+
+	// WithVerifyPeerCertificate adds custom server certificate verification
+	WithVerifyPeerCertificate = shared.WithVerifyPeerCertificate
+
+	// WithCertificateExpiryMonitor overrides monitor of client and server certificates expiration time,
+	// nil disables monitoring
+	WithCertificateExpiryMonitor = shared.WithCertificateExpiryMonitor
+
+	// WithTLSPolicy restricts TLS versions, cipher suites and curves for both http clients to a preset
+	WithTLSPolicy = shared.WithTLSPolicy
+
+	// WithTLSMinVersion overrides minimal TLS version of the policy
+	WithTLSMinVersion = shared.WithTLSMinVersion
+
+	// WithTLSMaxVersion overrides maximal TLS version of the policy
+	WithTLSMaxVersion = shared.WithTLSMaxVersion
+
+	// WithTLSCipherSuites overrides TLS 1.2 cipher suites of the policy
+	WithTLSCipherSuites = shared.WithTLSCipherSuites
+
+	// WithTLSCurvePreferences overrides key exchange curves of the policy
+	WithTLSCurvePreferences = shared.WithTLSCurvePreferences
+
 	// WithOptimizeHeaders makes DynamoDB client remove headers not used by Alternator reducing outgoing traffic
 	WithOptimizeHeaders = shared.WithOptimizeHeaders
 
 	// WithCustomOptimizeHeaders makes DynamoDB client remove headers not used by Alternator reducing outgoing traffic
 	WithCustomOptimizeHeaders = shared.WithCustomOptimizeHeaders
 
+	// WithRequestCompression makes DynamoDB client gzip compress request bodies
+	WithRequestCompression = shared.WithRequestCompression
+
+	// WithRequestCompressionMinSize sets minimal size of request body to be compressed
+	WithRequestCompressionMinSize = shared.WithRequestCompressionMinSize
+
+	// WithResponseDecompression makes DynamoDB client request gzip encoded responses and decompress them
+	WithResponseDecompression = shared.WithResponseDecompression
+
 	// WithKeyLogWriter makes both (DynamoDB and Alternator) clients to write TLS master key into a file
 	// It helps to debug issues by looking at decoded HTTPS traffic between Alternator and client
 	WithKeyLogWriter = shared.WithKeyLogWriter
@@ -118,9 +236,59 @@ var (
 	// WithIdleHTTPConnectionTimeout controls timeout for idle http connections held by http.Transport
 	WithIdleHTTPConnectionTimeout = shared.WithIdleHTTPConnectionTimeout
 
-	// WithHTTPTransport sets custom transport for http client
+	// WithMaxIdleHTTPConnectionsPerHost controls maximum number of idle http connections held by http.Transport
+	// to every node
+	WithMaxIdleHTTPConnectionsPerHost = shared.WithMaxIdleHTTPConnectionsPerHost
+
+	// WithMaxHTTPConnectionsPerHost limits total number of http connections to every node, 0 means no limit
+	WithMaxHTTPConnectionsPerHost = shared.WithMaxHTTPConnectionsPerHost
+
+	// WithDialTimeout controls how long http clients wait for a connect to a node to complete
+	WithDialTimeout = shared.WithDialTimeout
+
+	// WithDialKeepAlive controls interval between TCP keep-alive probes
+	WithDialKeepAlive = shared.WithDialKeepAlive
+
+	// WithTLSHandshakeTimeout controls how long http clients wait for a TLS handshake to complete
+	WithTLSHandshakeTimeout = shared.WithTLSHandshakeTimeout
+
+	// WithResponseHeaderTimeout controls how long http clients wait for response headers
+	WithResponseHeaderTimeout = shared.WithResponseHeaderTimeout
+
+	// WithHTTP2 controls whether both (DynamoDB and Alternator) http clients use HTTP/2
+	WithHTTP2 = shared.WithHTTP2
+
+	// WithHTTPTransport sets custom transport for both (DynamoDB and Alternator) http clients
 	// For testing purposes only, don't use it on production
 	WithHTTPTransport = shared.WithHTTPTransport
+
+	// WithWarmUpConnections makes DynamoDB client open given number of keep-alive connections to every node
+	// on startup and whenever new node is discovered
+	WithWarmUpConnections = shared.WithWarmUpConnections
+
+	// WithDrainRequestsOnClose makes Close wait for requests in flight to finish before closing connections
+	WithDrainRequestsOnClose = shared.WithDrainRequestsOnClose
+
+	// WithNode returns a context that makes requests performed with it go to the given node
+	WithNode = shared.WithNode
+
+	// WithScope returns a context that makes requests performed with it go to nodes of the given routing scope
+	WithScope = shared.WithScope
+
+	// NewTopologyRegistry creates node discovery to be shared by several helpers created by `NewHelperFromRegistry`
+	NewTopologyRegistry = shared.NewTopologyRegistry
+
+	// WithTableRoute routes requests to tables matching the pattern to nodes of the scope picked by the policy
+	WithTableRoute = shared.WithTableRoute
+
+	// WithBalancingPolicy returns a context that makes requests performed with it go to the node picked by the policy
+	WithBalancingPolicy = shared.WithBalancingPolicy
+
+	// RoundRobin returns a balancing policy that picks nodes in turn
+	RoundRobin = shared.RoundRobin
+
+	// Random returns a balancing policy that picks a random node
+	Random = shared.Random
 )
 
 // AlternatorNodesSource an interface for nodes list provider
@@ -134,7 +302,32 @@ type AlternatorNodesSource interface {
 	Stop()
 }
 
-var _ AlternatorNodesSource = &shared.AlternatorLiveNodes{}
+// ManagedNodesSource an interface for nodes list provider that routes requests by their context,
+// reports readiness and new nodes and can be closed, nodes provider that doesn't implement it
+// gets no per-request routing, connection warm-up of new nodes and readiness checks beyond one update
+type ManagedNodesSource interface {
+	NextNodeForContext(ctx context.Context) url.URL
+	WaitForReady(ctx context.Context, opts shared.ReadyOptions) (shared.ReadyReport, error)
+	OnNewNodes(fn func([]url.URL))
+	Close(ctx context.Context) error
+	State() shared.LifecycleState
+}
+
+// TopologySource an interface for nodes list provider that knows locations of nodes and shares its discovery
+// with views of other routing scopes, helpers created by `Update` share discovery only if provider implements it
+type TopologySource interface {
+	Topology() []shared.NodeInfo
+	View(scope rt.Scope, scheme string, port int) *shared.NodesView
+}
+
+var (
+	_ AlternatorNodesSource = &shared.AlternatorLiveNodes{}
+	_ AlternatorNodesSource = &shared.NodesView{}
+	_ ManagedNodesSource    = &shared.AlternatorLiveNodes{}
+	_ ManagedNodesSource    = &shared.NodesView{}
+	_ TopologySource        = &shared.AlternatorLiveNodes{}
+	_ TopologySource        = &shared.NodesView{}
+)
 
 // Helper manages the integration between the AWS SDK and ScyllaDB's Alternator.
 // It handles dynamic node discovery, rack/datacenter-aware routing, and creates
@@ -149,8 +342,14 @@ var _ AlternatorNodesSource = &shared.AlternatorLiveNodes{}
 // It internally relies on the shared.AlternatorLiveNodes component for tracking
 // and routing to healthy nodes.
 type Helper struct {
-	nodes AlternatorNodesSource
-	cfg   shared.Config
+	nodes      AlternatorNodesSource
+	cfg        shared.Config
+	tracker    *shared.RequestTracker
+	transports *shared.TransportPatcher
+	// warmedTransports is a set of `*http.Transport` connection warm-up has been started for
+	warmedTransports sync.Map
+	// ownsNodes is false for helpers created by `NewHelperFromRegistry`, they don't stop or close shared discovery
+	ownsNodes bool
 }
 
 // NewHelper creates a new Helper instance configured with the provided initial Alternator nodes, in a form of ip or dns name (without port)
@@ -160,55 +359,170 @@ func NewHelper(initialNodes []string, options ...shared.Option) (*Helper, error)
 	for _, opt := range options {
 		opt(cfg)
 	}
+	if cfg.CredentialsProvider != nil {
+		// Node discovery and clients of the helper share retrieved credentials
+		cfg.CredentialsProvider = shared.NewCachedCredentialsProvider(cfg.CredentialsProvider)
+	}
 
 	nodes, err := shared.NewAlternatorLiveNodes(initialNodes, cfg.ToALNOptions()...)
 	if err != nil {
 		return nil, err
 	}
+	tracker := shared.NewRequestTracker()
 	return &Helper{
-		nodes: nodes,
-		cfg:   *cfg,
+		nodes:      nodes,
+		cfg:        *cfg,
+		tracker:    tracker,
+		transports: newTransportPatcher(*cfg, tracker),
+		ownsNodes:  true,
 	}, nil
 }
 
+// NewHelperFromRegistry creates a new Helper that uses node discovery of the registry created by `NewTopologyRegistry`,
+// instead of running its own. Helpers of the same registry share lists of nodes, a list per routing scope.
+// Options that configure node discovery, like TLS settings of discovery requests, are taken from the registry,
+// while routing scope, scheme and port are taken from options. Helper does not stop or close the registry.
+func NewHelperFromRegistry(registry *shared.AlternatorLiveNodes, options ...shared.Option) *Helper {
+	cfg := shared.NewDefaultConfig()
+	for _, opt := range options {
+		opt(cfg)
+	}
+	tracker := shared.NewRequestTracker()
+	return &Helper{
+		nodes:      registry.View(cfg.RoutingScope, cfg.Scheme, cfg.Port),
+		cfg:        *cfg,
+		tracker:    tracker,
+		transports: newTransportPatcher(*cfg, tracker),
+	}
+}
+
+// AWSConfig produces a conf for the AWS SDK that will integrate the alternator loadbalancing with the AWS SDK.
+// Every call creates a new `HTTPClient` on top of the transport shared by all clients of the helper,
+// `APIOptions` of the config contain a middleware that sends every request to the next Alternator node,
+// so the config can be used to create both DynamoDB and DynamoDB Streams clients without custom endpoint resolver.
+//
+// Returned config can be customized before it is used, e.g. by setting `Retryer` or by appending own middlewares
+// to `APIOptions`, `HTTPClient` can be replaced with a client that wraps it.
+func (lb *Helper) AWSConfig() (aws.Config, error) {
+	cfg, err := lb.awsConfig()
+	if err != nil {
+		return aws.Config{}, err
+	}
+	cfg.APIOptions = append(cfg.APIOptions, lb.routeToNodeMiddleware)
+	return cfg, nil
+}
+
 func (lb *Helper) awsConfig() (aws.Config, error) {
 	cfg := aws.Config{
 		// Region is used in the signature algorithm so prevent request sent
 		// to one region to be forward by an attacker to a different region.
 		// But Alternator doesn't check it. It can be anything.
 		Region: lb.cfg.AWSRegion,
-		BaseEndpoint: aws.String(
-			fmt.Sprintf("%s://%s:%d", lb.cfg.Scheme, "dynamodb.fake.alterntor.cluster.node", lb.cfg.Port),
-		),
 	}
+	httpClient, err := lb.newHTTPClient()
+	if err != nil {
+		return aws.Config{}, err
+	}
+	return lb.patchAWSConfig(cfg, httpClient), nil
+}
 
-	cfg.HTTPClient = &http.Client{
-		Transport: shared.DefaultHTTPTransport(),
+// patchAWSConfig wires alternator endpoint, patched http client, middlewares and credentials into the config
+func (lb *Helper) patchAWSConfig(cfg aws.Config, httpClient *http.Client) aws.Config {
+	cfg.BaseEndpoint = aws.String(
+		fmt.Sprintf("%s://%s:%d", lb.cfg.Scheme, "dynamodb.fake.alterntor.cluster.node", lb.cfg.Port),
+	)
+	cfg.HTTPClient = httpClient
+
+	lb.startConnectionWarmer(httpClient.Transport)
+	httpClient.Transport = lb.tracker.Track(httpClient.Transport)
+
+	// APIOptions of the base config should not be modified
+	cfg.APIOptions = append(slices.Clone(cfg.APIOptions), routingInfoMiddleware)
+
+	if lb.cfg.OptimizeHeaders != nil {
+		cfg.APIOptions = append(cfg.APIOptions, lb.optimizeHeadersMiddleware())
 	}
 
-	err := shared.PatchHTTPClient(lb.cfg, cfg.HTTPClient)
-	if err != nil {
-		return aws.Config{}, err
+	if lb.cfg.RequestCompression {
+		cfg.APIOptions = append(cfg.APIOptions, lb.requestCompressionMiddleware())
 	}
 
-	if lb.cfg.AccessKeyID != "" && lb.cfg.SecretAccessKey != "" {
-		// The third credential below, the session token, is only used for
-		// temporary credentials, and is not supported by Alternator anyway.
-		cfg.Credentials = credentials.NewStaticCredentialsProvider(lb.cfg.AccessKeyID, lb.cfg.SecretAccessKey, "")
+	if len(lb.cfg.TableRoutes) != 0 {
+		cfg.APIOptions = append(cfg.APIOptions, lb.tableRoutingMiddleware)
 	}
 
-	return cfg, nil
+	if provider := lb.cfg.GetCredentialsProvider(); provider != nil {
+		cfg.Credentials = newCredentialsProvider(provider, lb.cfg.Logger)
+	}
+
+	return cfg
+}
+
+// newHTTPClient creates http client on top of the transport shared by clients of the helper
+func (lb *Helper) newHTTPClient() (*http.Client, error) {
+	httpClient := &http.Client{
+		Transport: lb.transports.BaseTransport(),
+	}
+	if err := lb.transports.Patch(httpClient); err != nil {
+		return nil, err
+	}
+	return httpClient, nil
 }
 
-// Update takes config of current helper, updates its config and creates a new helper with updated config
+// newTransportPatcher creates patcher of transports of the helper,
+//
+//	transports of the caller it patches in place are released once the tracker is closed
+func newTransportPatcher(cfg shared.Config, tracker *shared.RequestTracker) *shared.TransportPatcher {
+	patcher := shared.NewTransportPatcher(cfg)
+	tracker.OnClose(patcher.Close)
+	return patcher
+}
+
+// startConnectionWarmer starts warming up connections of the transport, once per underlying `http.Transport`,
+//
+//	so that clients sharing transport don't register more listeners and don't repeat warm-ups
+func (lb *Helper) startConnectionWarmer(transport http.RoundTripper) {
+	if lb.cfg.WarmUpConnections <= 0 {
+		return
+	}
+	base, ok := shared.UnwrapHTTPTransport(transport)
+	if !ok {
+		return
+	}
+	if _, loaded := lb.warmedTransports.LoadOrStore(base, struct{}{}); loaded {
+		return
+	}
+	warmer := shared.NewConnectionWarmer(
+		base,
+		lb.cfg.WarmUpConnections,
+		lb.cfg.MaxIdleHTTPConnections,
+		lb.cfg.Logger,
+	)
+	lb.tracker.OnClose(warmer.Close)
+	if source, ok := lb.nodes.(ManagedNodesSource); ok {
+		source.OnNewNodes(warmer.WarmUp)
+	}
+	warmer.WarmUp(lb.nodes.GetNodes())
+}
+
+// Update takes config of current helper, updates its config and creates a new helper with updated config.
+// New helper shares node discovery with the current one, using a view for its routing scope, scheme and port,
+// if nodes provider implements `TopologySource`
 func (lb *Helper) Update(opts ...Option) *Helper {
 	cfg := lb.cfg
 	for _, opt := range opts {
 		opt(&cfg)
 	}
+	nodes := lb.nodes
+	if source, ok := lb.nodes.(TopologySource); ok {
+		nodes = source.View(cfg.RoutingScope, cfg.Scheme, cfg.Port)
+	}
 	return &Helper{
-		nodes: lb.nodes,
-		cfg:   cfg,
+		nodes:      nodes,
+		cfg:        cfg,
+		tracker:    lb.tracker,
+		transports: newTransportPatcher(cfg, lb.tracker),
+		ownsNodes:  lb.ownsNodes,
 	}
 }
 
@@ -217,6 +531,15 @@ func (lb *Helper) NextNode() url.URL {
 	return lb.nodes.NextNode()
 }
 
+// nextNodeForContext returns node the request with ctx is sent to, nodes provider that doesn't implement
+// `ManagedNodesSource` ignores routing set in ctx
+func (lb *Helper) nextNodeForContext(ctx context.Context) url.URL {
+	if source, ok := lb.nodes.(ManagedNodesSource); ok {
+		return source.NextNodeForContext(ctx)
+	}
+	return lb.nodes.NextNode()
+}
+
 // UpdateLiveNodes forces an immediate refresh of the live Alternator nodes list.
 func (lb *Helper) UpdateLiveNodes() error {
 	return lb.nodes.UpdateLiveNodes()
@@ -234,15 +557,83 @@ func (lb *Helper) CheckIfRackDatacenterFeatureIsSupported() (bool, error) {
 	return lb.nodes.CheckIfRackDatacenterFeatureIsSupported()
 }
 
+// WaitForReady reads list of nodes from every seed node and runs checks requested by opts,
+// retrying until they pass or ctx is done. Use it on startup to fail fast when cluster or scope is unreachable
+func (lb *Helper) WaitForReady(ctx context.Context, opts ReadyOptions) (ReadyReport, error) {
+	if source, ok := lb.nodes.(ManagedNodesSource); ok {
+		return source.WaitForReady(ctx, opts)
+	}
+	if err := lb.nodes.UpdateLiveNodes(); err != nil {
+		return ReadyReport{Attempts: 1}, err
+	}
+	report := ReadyReport{Nodes: lb.nodes.GetNodes(), Attempts: 1}
+	if len(report.Nodes) < opts.MinNodes {
+		return report, fmt.Errorf("found %d live nodes, at least %d required", len(report.Nodes), opts.MinNodes)
+	}
+	if opts.CheckRackAndDatacenter {
+		return report, lb.nodes.CheckIfRackAndDatacenterSetCorrectly()
+	}
+	return report, nil
+}
+
 // Start begins background routines used for periodic node discovery and updates.
-// It is not required to start if automatically on first API call
+// It is not required to start it, it is started automatically on first API call, but it is required to restart
+// it after `Stop`
 func (lb *Helper) Start() {
 	lb.nodes.Start()
 }
 
-// Stop stops background routines used for periodic node discovery and updates.
+// Stop stops background routines used for periodic node discovery and updates, they can be started again by `Start`
 func (lb *Helper) Stop() {
-	lb.nodes.Stop()
+	if lb.ownsNodes {
+		lb.nodes.Stop()
+	}
+}
+
+// State returns lifecycle state of background node discovery
+func (lb *Helper) State() LifecycleState {
+	if source, ok := lb.nodes.(ManagedNodesSource); ok {
+		return source.State()
+	}
+	return shared.StateRunning
+}
+
+// Close shuts helper down: http clients created by it reject new requests with `shared.ErrClosed`,
+// requests in flight are waited for if `WithDrainRequestsOnClose` is set, node discovery is stopped,
+// background routines are waited for and idle connections are closed.
+// Helpers created by `Update` share the state, closing one of them closes all.
+// Helpers created by `NewHelperFromRegistry` leave node discovery of the registry running.
+// It is safe to call it multiple times and concurrently, it returns `ctx.Err()` if ctx is done before shutdown ends.
+func (lb *Helper) Close(ctx context.Context) error {
+	err := lb.tracker.Close(ctx, lb.cfg.DrainRequestsOnClose)
+	if lb.ownsNodes {
+		if source, ok := lb.nodes.(ManagedNodesSource); ok {
+			err = errors.Join(err, source.Close(ctx))
+		} else {
+			lb.nodes.Stop()
+		}
+	}
+	return err
+}
+
+// Topology returns discovered nodes along with their datacenter and rack, where they are known,
+// nodes provider that doesn't implement `TopologySource` knows no locations
+func (lb *Helper) Topology() []NodeInfo {
+	if source, ok := lb.nodes.(TopologySource); ok {
+		return source.Topology()
+	}
+	nodes := lb.nodes.GetNodes()
+	out := make([]NodeInfo, len(nodes))
+	for i, node := range nodes {
+		out[i] = NodeInfo{URL: node}
+	}
+	return out
+}
+
+// CertificateExpiries returns expiration time of client and server certificates seen in TLS handshakes so far,
+// ordered by expiration time
+func (lb *Helper) CertificateExpiries() []CertificateExpiry {
+	return lb.cfg.CertificateExpiryMonitor.Expiries()
 }
 
 func (lb *Helper) endpointResolverV2() dynamodb.EndpointResolverV2 {
@@ -255,7 +646,27 @@ func (lb *Helper) NewDynamoDB() (*dynamodb.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return dynamodb.NewFromConfig(cfg, dynamodb.WithEndpointResolverV2(lb.endpointResolverV2())), nil
+	return dynamodb.NewFromConfig(
+		cfg,
+		lb.acceptGzipResponses,
+		dynamodb.WithEndpointResolverV2(lb.endpointResolverV2()),
+	), nil
+}
+
+func (lb *Helper) streamsEndpointResolverV2() dynamodbstreams.EndpointResolverV2 {
+	return &StreamsEndpointResolverV2{lb: lb}
+}
+
+// NewDynamoDBStreams creates a new DynamoDB Streams client preconfigured to route requests to Alternator nodes
+func (lb *Helper) NewDynamoDBStreams() (*dynamodbstreams.Client, error) {
+	cfg, err := lb.awsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamodbstreams.NewFromConfig(
+		cfg,
+		dynamodbstreams.WithEndpointResolverV2(lb.streamsEndpointResolverV2()),
+	), nil
 }
 
 // EndpointResolverV2 implementation for `dynamodb.EndpointResolverV2` that makes it return alternator nodes
@@ -265,10 +676,26 @@ type EndpointResolverV2 struct {
 
 // ResolveEndpoint returns alternator endpoint wrapped in `smithyendpoints.Endpoint`
 func (r *EndpointResolverV2) ResolveEndpoint(
-	_ context.Context,
+	ctx context.Context,
 	_ dynamodb.EndpointParameters,
 ) (smithyendpoints.Endpoint, error) {
 	return smithyendpoints.Endpoint{
-		URI: r.lb.NextNode(),
+		URI: r.lb.nextNodeForContext(ctx),
+	}, nil
+}
+
+// StreamsEndpointResolverV2 implementation for `dynamodbstreams.EndpointResolverV2`
+// that makes it return alternator nodes
+type StreamsEndpointResolverV2 struct {
+	lb *Helper
+}
+
+// ResolveEndpoint returns alternator endpoint wrapped in `smithyendpoints.Endpoint`
+func (r *StreamsEndpointResolverV2) ResolveEndpoint(
+	ctx context.Context,
+	_ dynamodbstreams.EndpointParameters,
+) (smithyendpoints.Endpoint, error) {
+	return smithyendpoints.Endpoint{
+		URI: r.lb.nextNodeForContext(ctx),
 	}, nil
 }
@@ -9,11 +9,14 @@ import (
 	"errors"
 	"net/url"
 	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 
 	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 
 	"github.com/scylladb/alternator-client-golang/shared/rt"
 
@@ -155,6 +158,64 @@ func TestDynamoDBOperations(t *testing.T) {
 			helper.WithIgnoreServerCertificateError(true),
 		)
 	})
+	t.Run("OptimizeHeaders", func(t *testing.T) {
+		testDynamoDBOperations(t, helper.WithPort(httpPort), helper.WithOptimizeHeaders(true))
+	})
+}
+
+func TestOptimizeHeaders(t *testing.T) {
+	h, err := helper.NewHelper(
+		knownNodes,
+		helper.WithPort(httpPort),
+		helper.WithCredentials("whatever", "secret"),
+		helper.WithOptimizeHeaders(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create alternator helper: %v", err)
+	}
+	defer h.Stop()
+
+	ddb, err := h.NewDynamoDB()
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+
+	var signedHeaders string
+	captureSignedHeaders := middleware.FinalizeMiddlewareFunc(
+		"CaptureSignedHeaders",
+		func(
+			ctx context.Context,
+			in middleware.FinalizeInput,
+			next middleware.FinalizeHandler,
+		) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if req, ok := in.Request.(*smithyhttp.Request); ok {
+				_, signedHeaders, _ = strings.Cut(req.Header.Get("Authorization"), "SignedHeaders=")
+				signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+			}
+			return next.HandleFinalize(ctx, in)
+		},
+	)
+
+	_, err = ddb.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{
+		TableName: aws.String("table-that-does-not-exist"),
+	}, func(o *dynamodb.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Finalize.Add(captureSignedHeaders, middleware.After)
+		})
+	})
+	if err != nil && !errors.As(err, notFoundErr) {
+		t.Fatalf("unexpected operation error: %v", err)
+	}
+
+	if signedHeaders == "" {
+		t.Fatalf("request was not signed")
+	}
+	allowedHeaders := []string{"host", "x-amz-target", "content-length", "accept-encoding", "authorization", "x-amz-date"}
+	for _, header := range strings.Split(signedHeaders, ";") {
+		if !slices.Contains(allowedHeaders, header) {
+			t.Errorf("header %q should have been removed before signing", header)
+		}
+	}
 }
 
 type KeyWriter struct {
@@ -0,0 +1,36 @@
+package sdkv2
+
+import (
+	"context"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+const optimizeHeadersMiddlewareID = "AlternatorOptimizeHeaders"
+
+// optimizeHeadersMiddleware returns a middleware that removes headers not used by Alternator before request
+//
+//	is signed, so that removed headers never end up in SignedHeaders
+func (lb *Helper) optimizeHeadersMiddleware() func(stack *middleware.Stack) error {
+	allowedHeaders := lb.cfg.AllowedHeaders()
+	removedHeaders := shared.NewRemovedHeadersLogger(lb.cfg.Logger)
+	strip := middleware.FinalizeMiddlewareFunc(
+		optimizeHeadersMiddlewareID,
+		func(
+			ctx context.Context,
+			in middleware.FinalizeInput,
+			next middleware.FinalizeHandler,
+		) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if req, ok := in.Request.(*smithyhttp.Request); ok {
+				shared.StripAndLogHeaders(req.Header, removedHeaders, allowedHeaders...)
+			}
+			return next.HandleFinalize(ctx, in)
+		},
+	)
+	return func(stack *middleware.Stack) error {
+		return stack.Finalize.Insert(strip, "Signing", middleware.Before)
+	}
+}
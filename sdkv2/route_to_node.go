@@ -0,0 +1,36 @@
+package sdkv2
+
+import (
+	"context"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const routeToNodeMiddlewareID = "AlternatorRouteToNode"
+
+// routeToNodeMiddleware sends request to the next Alternator node after endpoint is resolved and before
+//
+//	request is signed, it does the same job `EndpointResolverV2` does, but for any service client
+func (lb *Helper) routeToNodeMiddleware(stack *middleware.Stack) error {
+	return stack.Finalize.Insert(
+		middleware.FinalizeMiddlewareFunc(
+			routeToNodeMiddlewareID,
+			func(
+				ctx context.Context,
+				in middleware.FinalizeInput,
+				next middleware.FinalizeHandler,
+			) (middleware.FinalizeOutput, middleware.Metadata, error) {
+				if req, ok := in.Request.(*smithyhttp.Request); ok {
+					node := lb.nextNodeForContext(ctx)
+					req.URL.Scheme = node.Scheme
+					req.URL.Host = node.Host
+					req.Host = ""
+				}
+				return next.HandleFinalize(ctx, in)
+			},
+		),
+		"ResolveEndpointV2",
+		middleware.After,
+	)
+}
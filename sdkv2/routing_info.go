@@ -0,0 +1,59 @@
+package sdkv2
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+// RoutingInfo describes how a request was routed: node that served it, number of attempts and routing scope tier
+type RoutingInfo = shared.RoutingInfo
+
+const routingInfoMiddlewareID = "AlternatorRoutingInfo"
+
+type routingInfoKey struct{}
+
+// routingInfoMiddleware collects routing info of every attempt made by the operation,
+//
+//	stores it in the operation metadata and wraps operation error into `shared.RoutingError`
+func routingInfoMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(
+		middleware.InitializeMiddlewareFunc(
+			routingInfoMiddlewareID,
+			func(
+				ctx context.Context,
+				in middleware.InitializeInput,
+				next middleware.InitializeHandler,
+			) (middleware.InitializeOutput, middleware.Metadata, error) {
+				ctx, rec := shared.WithRoutingRecorder(ctx)
+				out, metadata, err := next.HandleInitialize(ctx, in)
+				if info, ok := rec.Info(); ok {
+					metadata.Set(routingInfoKey{}, info)
+					if err != nil {
+						err = &shared.RoutingError{Err: err, Info: info}
+					}
+				}
+				return out, metadata, err
+			},
+		),
+		middleware.Before,
+	)
+}
+
+// ServedBy returns routing info of the operation from its result metadata, e.g. `output.ResultMetadata`
+func ServedBy(metadata middleware.Metadata) (RoutingInfo, bool) {
+	info, ok := metadata.Get(routingInfoKey{}).(RoutingInfo)
+	return info, ok
+}
+
+// ServedByError returns routing info of the failed operation from its error
+func ServedByError(err error) (RoutingInfo, bool) {
+	var routingErr *shared.RoutingError
+	if errors.As(err, &routingErr) {
+		return routingErr.Info, true
+	}
+	return RoutingInfo{}, false
+}
@@ -0,0 +1,125 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ShardEnd is a checkpoint value stored for shards that were read to the end,
+//
+//	child shards are read only after their parents reach it
+const ShardEnd = "SHARD_END"
+
+// CheckpointStore persists sequence number of the last processed record of every shard
+type CheckpointStore interface {
+	// GetCheckpoint returns last stored sequence number of the shard, empty string if there is none
+	GetCheckpoint(ctx context.Context, streamARN, shardID string) (string, error)
+	// SetCheckpoint stores sequence number of the last processed record of the shard
+	SetCheckpoint(ctx context.Context, streamARN, shardID, sequenceNumber string) error
+}
+
+// MemoryCheckpointStore keeps checkpoints in memory, they are lost when process exits
+type MemoryCheckpointStore struct {
+	lock        sync.Mutex
+	checkpoints map[string]map[string]string
+}
+
+// NewMemoryCheckpointStore creates new empty `MemoryCheckpointStore`
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		checkpoints: map[string]map[string]string{},
+	}
+}
+
+// GetCheckpoint implements `CheckpointStore`
+func (s *MemoryCheckpointStore) GetCheckpoint(_ context.Context, streamARN, shardID string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.checkpoints[streamARN][shardID], nil
+}
+
+// SetCheckpoint implements `CheckpointStore`
+func (s *MemoryCheckpointStore) SetCheckpoint(_ context.Context, streamARN, shardID, sequenceNumber string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.checkpoints[streamARN] == nil {
+		s.checkpoints[streamARN] = map[string]string{}
+	}
+	s.checkpoints[streamARN][shardID] = sequenceNumber
+	return nil
+}
+
+var _ CheckpointStore = &MemoryCheckpointStore{}
+
+// FileCheckpointStore keeps checkpoints in a JSON file, file is rewritten atomically on every checkpoint
+type FileCheckpointStore struct {
+	path  string
+	store *MemoryCheckpointStore
+}
+
+// NewFileCheckpointStore creates `FileCheckpointStore` backed by the file at path, loading checkpoints from it
+//
+//	if it exists
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	s := &FileCheckpointStore{
+		path:  path,
+		store: NewMemoryCheckpointStore(),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints file: %w", err)
+	}
+	if err = json.Unmarshal(data, &s.store.checkpoints); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoints file: %w", err)
+	}
+	if s.store.checkpoints == nil {
+		s.store.checkpoints = map[string]map[string]string{}
+	}
+	return s, nil
+}
+
+// GetCheckpoint implements `CheckpointStore`
+func (s *FileCheckpointStore) GetCheckpoint(ctx context.Context, streamARN, shardID string) (string, error) {
+	return s.store.GetCheckpoint(ctx, streamARN, shardID)
+}
+
+// SetCheckpoint implements `CheckpointStore`
+func (s *FileCheckpointStore) SetCheckpoint(_ context.Context, streamARN, shardID, sequenceNumber string) error {
+	s.store.lock.Lock()
+	defer s.store.lock.Unlock()
+	if s.store.checkpoints[streamARN] == nil {
+		s.store.checkpoints[streamARN] = map[string]string{}
+	}
+	s.store.checkpoints[streamARN][shardID] = sequenceNumber
+
+	data, err := json.MarshalIndent(s.store.checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoints: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoints file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint: errcheck // file is already renamed on success
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write checkpoints file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoints file: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write checkpoints file: %w", err)
+	}
+	return nil
+}
+
+var _ CheckpointStore = &FileCheckpointStore{}
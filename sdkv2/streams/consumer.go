@@ -0,0 +1,543 @@
+/*
+Package streams provides a consumer of Alternator (DynamoDB) Streams built on top of AWS SDK V2.
+
+Consumer discovers shards of a stream, reads child shards only after their parents are read to the end,
+polls GetRecords with backoff, dispatches records to a handler and checkpoints sequence numbers
+of processed records through a pluggable CheckpointStore.
+
+Consumer uses the client it is given for all requests, when it is created by `sdkv2.Helper.NewDynamoDBStreams`
+shard reads are load balanced across Alternator nodes the same way as DynamoDB requests.
+
+Example usage:
+
+	h, err := sdkv2.NewHelper([]string{"x.x.x.x"}, sdkv2.WithPort(8000))
+	if err != nil {
+	    log.Fatal(err)
+	}
+	client, err := h.NewDynamoDBStreams()
+	if err != nil {
+	    log.Fatal(err)
+	}
+	store, err := streams.NewFileCheckpointStore("checkpoints.json")
+	if err != nil {
+	    log.Fatal(err)
+	}
+	handler := func(ctx context.Context, shardID string, records []types.Record) error {
+	    // process records
+	    return nil
+	}
+	consumer := streams.NewConsumer(client, streamARN, handler, streams.WithCheckpointStore(store))
+	err = consumer.Run(ctx)
+*/
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+const (
+	defaultPollInterval      = time.Second
+	defaultDiscoveryInterval = 10 * time.Second
+	defaultMinBackoff        = 100 * time.Millisecond
+	defaultMaxBackoff        = 10 * time.Second
+)
+
+// API is a subset of `dynamodbstreams.Client` used by `Consumer`
+type API interface {
+	DescribeStream(
+		ctx context.Context,
+		params *dynamodbstreams.DescribeStreamInput,
+		optFns ...func(*dynamodbstreams.Options),
+	) (*dynamodbstreams.DescribeStreamOutput, error)
+	GetShardIterator(
+		ctx context.Context,
+		params *dynamodbstreams.GetShardIteratorInput,
+		optFns ...func(*dynamodbstreams.Options),
+	) (*dynamodbstreams.GetShardIteratorOutput, error)
+	GetRecords(
+		ctx context.Context,
+		params *dynamodbstreams.GetRecordsInput,
+		optFns ...func(*dynamodbstreams.Options),
+	) (*dynamodbstreams.GetRecordsOutput, error)
+}
+
+var _ API = &dynamodbstreams.Client{}
+
+// Handler processes records read from a shard, records are checkpointed once it returns nil,
+//
+//	error returned by it stops the consumer
+type Handler func(ctx context.Context, shardID string, records []types.Record) error
+
+// Option is option for the `NewConsumer`
+type Option func(*config)
+
+type config struct {
+	store             CheckpointStore
+	startingPosition  types.ShardIteratorType
+	limit             int32
+	pollInterval      time.Duration
+	discoveryInterval time.Duration
+	minBackoff        time.Duration
+	maxBackoff        time.Duration
+	logger            logx.Logger
+}
+
+// WithCheckpointStore sets store for checkpoints, `MemoryCheckpointStore` is used by default
+func WithCheckpointStore(store CheckpointStore) Option {
+	return func(c *config) {
+		c.store = store
+	}
+}
+
+// WithStartingPosition sets where to start reading shards that have no checkpoint,
+//
+//	`types.ShardIteratorTypeTrimHorizon` (default) or `types.ShardIteratorTypeLatest`,
+//	child shards of the shards read by the consumer are always read from the beginning
+func WithStartingPosition(position types.ShardIteratorType) Option {
+	return func(c *config) {
+		c.startingPosition = position
+	}
+}
+
+// WithLimit sets maximum number of records returned by a single GetRecords call
+func WithLimit(limit int32) Option {
+	return func(c *config) {
+		c.limit = limit
+	}
+}
+
+// WithPollInterval sets how long to wait before polling a shard again when it has no new records
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.pollInterval = interval
+	}
+}
+
+// WithDiscoveryInterval sets how often to look for new shards
+func WithDiscoveryInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.discoveryInterval = interval
+	}
+}
+
+// WithBackoff sets minimal and maximal delay between retries of failed requests,
+//
+//	delay doubles after every consecutive failure
+func WithBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(c *config) {
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithLogger sets logger
+func WithLogger(logger logx.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// Consumer reads records of a stream from all of its shards
+type Consumer struct {
+	api       API
+	streamARN string
+	handler   Handler
+	cfg       config
+}
+
+// NewConsumer creates new `Consumer` of the stream
+func NewConsumer(api API, streamARN string, handler Handler, opts ...Option) *Consumer {
+	cfg := config{
+		startingPosition:  types.ShardIteratorTypeTrimHorizon,
+		pollInterval:      defaultPollInterval,
+		discoveryInterval: defaultDiscoveryInterval,
+		minBackoff:        defaultMinBackoff,
+		maxBackoff:        defaultMaxBackoff,
+		logger:            logx.Noop{},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.store == nil {
+		cfg.store = NewMemoryCheckpointStore()
+	}
+	return &Consumer{
+		api:       api,
+		streamARN: streamARN,
+		handler:   handler,
+		cfg:       cfg,
+	}
+}
+
+// shardState tracks shard reading progress within `Run`
+type shardState struct {
+	shard    types.Shard
+	started  bool
+	finished bool
+}
+
+// Run reads the stream until ctx is done or handler returns an error,
+//
+//	returns error of the handler, of the checkpoint store or of a request that can't be retried, or ctx error
+func (c *Consumer) Run(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		runErr   error
+		finished = make(chan string)
+		shards   = map[string]*shardState{}
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			runErr = err
+			cancel()
+		})
+	}
+
+	ticker := time.NewTicker(c.cfg.discoveryInterval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		if err := c.discoverShards(runCtx, shards); err != nil && runCtx.Err() == nil {
+			c.cfg.logger.Warn("failed to describe stream", logx.A("stream", c.streamARN), logx.A("error", err))
+		}
+
+		for id, state := range shards {
+			if state.started || state.finished || !c.parentFinished(shards, state.shard) {
+				continue
+			}
+			state.started = true
+			iteratorType := c.cfg.startingPosition
+			if _, ok := shards[aws.ToString(state.shard.ParentShardId)]; ok {
+				iteratorType = types.ShardIteratorTypeTrimHorizon
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := c.readShard(runCtx, id, iteratorType); err != nil {
+					if runCtx.Err() == nil {
+						fail(err)
+					}
+					return
+				}
+				select {
+				case finished <- id:
+				case <-runCtx.Done():
+				}
+			}()
+		}
+
+		select {
+		case <-runCtx.Done():
+			break loop
+		case id := <-finished:
+			// Children of the finished shard can be started right away
+			shards[id].finished = true
+		case <-ticker.C:
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	if runErr != nil {
+		return runErr
+	}
+	return ctx.Err()
+}
+
+func (c *Consumer) parentFinished(shards map[string]*shardState, shard types.Shard) bool {
+	parent, ok := shards[aws.ToString(shard.ParentShardId)]
+	// Parent shard could be already trimmed from the stream
+	return !ok || parent.finished
+}
+
+// discoverShards adds shards of the stream that are not known yet to `shards`
+func (c *Consumer) discoverShards(ctx context.Context, shards map[string]*shardState) error {
+	var lastShardID *string
+	for {
+		out, err := c.api.DescribeStream(ctx, &dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(c.streamARN),
+			ExclusiveStartShardId: lastShardID,
+		})
+		if err != nil {
+			return err
+		}
+		if out.StreamDescription == nil {
+			return nil
+		}
+		for _, shard := range out.StreamDescription.Shards {
+			id := aws.ToString(shard.ShardId)
+			if _, ok := shards[id]; ok {
+				continue
+			}
+			checkpoint, err := c.cfg.store.GetCheckpoint(ctx, c.streamARN, id)
+			if err != nil {
+				return fmt.Errorf("failed to read checkpoint of shard %s: %w", id, err)
+			}
+			shards[id] = &shardState{shard: shard, finished: checkpoint == ShardEnd}
+		}
+		lastShardID = out.StreamDescription.LastEvaluatedShardId
+		if lastShardID == nil {
+			return nil
+		}
+	}
+}
+
+// readShard reads the shard from its checkpoint to the end
+func (c *Consumer) readShard(ctx context.Context, shardID string, iteratorType types.ShardIteratorType) error {
+	logger := c.cfg.logger.With(logx.A("stream", c.streamARN), logx.A("shard", shardID))
+	checkpoint, err := c.cfg.store.GetCheckpoint(ctx, c.streamARN, shardID)
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint of shard %s: %w", shardID, err)
+	}
+
+	var iterator *string
+	renewIterator := func() error {
+		iterator, err = c.shardIterator(ctx, logger, shardID, checkpoint, iteratorType)
+		var trimmed *types.TrimmedDataAccessException
+		if checkpoint != "" && errors.As(err, &trimmed) {
+			logger.Warn("records after checkpoint are trimmed, reading shard from trim horizon",
+				logx.A("checkpoint", checkpoint))
+			checkpoint, iteratorType = "", types.ShardIteratorTypeTrimHorizon
+			iterator, err = c.shardIterator(ctx, logger, shardID, checkpoint, iteratorType)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get iterator of shard %s: %w", shardID, err)
+		}
+		return nil
+	}
+	if err = renewIterator(); err != nil {
+		return err
+	}
+
+	backoff := c.cfg.minBackoff
+	for {
+		if iterator == nil {
+			// Shard that is trimmed past the checkpoint has no iterator,
+			// it is read to the end only if it is closed, open shard is retried
+			closed, err := c.shardClosed(ctx, shardID)
+			if err != nil {
+				if err = c.backOff(ctx, logger, err, &backoff); err != nil {
+					return fmt.Errorf("failed to describe shard %s: %w", shardID, err)
+				}
+				continue
+			}
+			if closed {
+				break
+			}
+			logger.Warn("no iterator for open shard, requesting new one", logx.A("backoff", backoff))
+			if err = sleep(ctx, backoff); err != nil {
+				return err
+			}
+			backoff = min(backoff*2, c.cfg.maxBackoff)
+			if err = renewIterator(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		out, err := c.api.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: iterator,
+			Limit:         c.limit(),
+		})
+		if err != nil {
+			class := classifyError(err)
+			if err = c.backOff(ctx, logger, err, &backoff); err != nil {
+				return fmt.Errorf("failed to read records of shard %s: %w", shardID, err)
+			}
+			if class == classIterator {
+				if err = renewIterator(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		backoff = c.cfg.minBackoff
+
+		if len(out.Records) != 0 {
+			if err = c.handler(ctx, shardID, out.Records); err != nil {
+				return fmt.Errorf("failed to handle records of shard %s: %w", shardID, err)
+			}
+			last := out.Records[len(out.Records)-1]
+			if last.Dynamodb != nil && last.Dynamodb.SequenceNumber != nil {
+				checkpoint = *last.Dynamodb.SequenceNumber
+				if err = c.cfg.store.SetCheckpoint(ctx, c.streamARN, shardID, checkpoint); err != nil {
+					return fmt.Errorf("failed to store checkpoint of shard %s: %w", shardID, err)
+				}
+			}
+		}
+
+		if out.NextShardIterator == nil {
+			// Closed shard has no next iterator once its last record is read
+			break
+		}
+		iterator = out.NextShardIterator
+		if len(out.Records) == 0 {
+			if err = sleep(ctx, c.cfg.pollInterval); err != nil {
+				return err
+			}
+		}
+	}
+
+	logger.Debug("shard is read to the end")
+	if err = c.cfg.store.SetCheckpoint(ctx, c.streamARN, shardID, ShardEnd); err != nil {
+		return fmt.Errorf("failed to store checkpoint of shard %s: %w", shardID, err)
+	}
+	return nil
+}
+
+// shardIterator returns iterator pointing right after the checkpoint, or at `iteratorType` if there is none,
+//
+//	requests failed with transient errors are retried until ctx is done
+func (c *Consumer) shardIterator(
+	ctx context.Context,
+	logger logx.Logger,
+	shardID, checkpoint string,
+	iteratorType types.ShardIteratorType,
+) (*string, error) {
+	in := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(c.streamARN),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: iteratorType,
+	}
+	if checkpoint != "" {
+		in.ShardIteratorType = types.ShardIteratorTypeAfterSequenceNumber
+		in.SequenceNumber = aws.String(checkpoint)
+	}
+
+	backoff := c.cfg.minBackoff
+	for {
+		out, err := c.api.GetShardIterator(ctx, in)
+		if err == nil {
+			return out.ShardIterator, nil
+		}
+		if classifyError(err) == classIterator {
+			// Checkpoint is trimmed, it is up to the caller to pick another position
+			return nil, err
+		}
+		if err = c.backOff(ctx, logger, err, &backoff); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shardClosed tells whether the shard has an ending sequence number,
+//
+//	shard that is no longer listed by the stream is trimmed, so it is closed as well
+func (c *Consumer) shardClosed(ctx context.Context, shardID string) (bool, error) {
+	var lastShardID *string
+	for {
+		out, err := c.api.DescribeStream(ctx, &dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(c.streamARN),
+			ExclusiveStartShardId: lastShardID,
+		})
+		if err != nil {
+			return false, err
+		}
+		if out.StreamDescription == nil {
+			return false, nil
+		}
+		for _, shard := range out.StreamDescription.Shards {
+			if aws.ToString(shard.ShardId) == shardID {
+				return shard.SequenceNumberRange != nil && shard.SequenceNumberRange.EndingSequenceNumber != nil, nil
+			}
+		}
+		lastShardID = out.StreamDescription.LastEvaluatedShardId
+		if lastShardID == nil {
+			return true, nil
+		}
+	}
+}
+
+// backOff waits before failed request is retried and doubles the backoff,
+//
+//	error is returned as is if it is permanent, or if ctx is done
+func (c *Consumer) backOff(ctx context.Context, logger logx.Logger, err error, backoff *time.Duration) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	switch classifyError(err) {
+	case classPermanent:
+		return err
+	case classIterator:
+		logger.Debug("shard iterator is no longer valid, requesting new one", logx.A("error", err),
+			logx.A("backoff", *backoff))
+	default:
+		logger.Warn("stream request failed", logx.A("error", err), logx.A("backoff", *backoff))
+	}
+	if err = sleep(ctx, *backoff); err != nil {
+		return err
+	}
+	*backoff = min(*backoff*2, c.cfg.maxBackoff)
+	return nil
+}
+
+// errorClass tells how a failed stream request is handled
+type errorClass int
+
+const (
+	// classPermanent errors won't succeed on retry, they are returned to the caller
+	classPermanent errorClass = iota
+	// classTransient errors are throttling and server errors, request is retried after backoff
+	classTransient
+	// classIterator errors mean iterator is expired or points to trimmed records, new one is requested after backoff
+	classIterator
+)
+
+func classifyError(err error) errorClass {
+	var (
+		expired *types.ExpiredIteratorException
+		trimmed *types.TrimmedDataAccessException
+		respErr *awshttp.ResponseError
+		apiErr  smithy.APIError
+	)
+	switch {
+	case errors.As(err, &expired), errors.As(err, &trimmed):
+		return classIterator
+	case errors.As(err, &respErr) &&
+		(respErr.HTTPStatusCode() >= http.StatusInternalServerError ||
+			respErr.HTTPStatusCode() == http.StatusTooManyRequests):
+		return classTransient
+	case errors.As(err, &apiErr):
+		if _, ok := retry.DefaultThrottleErrorCodes[apiErr.ErrorCode()]; ok || apiErr.ErrorFault() == smithy.FaultServer {
+			return classTransient
+		}
+	}
+	return classPermanent
+}
+
+func (c *Consumer) limit() *int32 {
+	if c.cfg.limit <= 0 {
+		return nil
+	}
+	return aws.Int32(c.cfg.limit)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,416 @@
+package streams_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+
+	"github.com/scylladb/alternator-client-golang/sdkv2/streams"
+)
+
+const streamARN = "arn:scylla:alternator:::table/test/stream/1"
+
+type fakeShard struct {
+	id      string
+	parent  string
+	records []string
+	closed  bool
+	// trimmed shard has no iterator, as if its records were trimmed past the checkpoint
+	trimmed bool
+	// deleted shard is listed by the stream, but it can't be read
+	deleted bool
+}
+
+// fakeStream implements `streams.API`, iterators have a form of "<shard>:<position>"
+type fakeStream struct {
+	lock             sync.Mutex
+	shards           []*fakeShard
+	getRecordsErrors []error
+	expireIterators  int
+	missingIterators int
+	iteratorRequests []time.Time
+}
+
+func (f *fakeStream) shard(id string) *fakeShard {
+	for _, shard := range f.shards {
+		if shard.id == id {
+			return shard
+		}
+	}
+	return nil
+}
+
+func (f *fakeStream) DescribeStream(
+	_ context.Context,
+	_ *dynamodbstreams.DescribeStreamInput,
+	_ ...func(*dynamodbstreams.Options),
+) (*dynamodbstreams.DescribeStreamOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	out := &types.StreamDescription{StreamArn: aws.String(streamARN)}
+	for _, shard := range f.shards {
+		s := types.Shard{ShardId: aws.String(shard.id)}
+		if shard.parent != "" {
+			s.ParentShardId = aws.String(shard.parent)
+		}
+		if shard.closed {
+			s.SequenceNumberRange = &types.SequenceNumberRange{EndingSequenceNumber: aws.String("end")}
+		}
+		out.Shards = append(out.Shards, s)
+	}
+	return &dynamodbstreams.DescribeStreamOutput{StreamDescription: out}, nil
+}
+
+func (f *fakeStream) GetShardIterator(
+	_ context.Context,
+	in *dynamodbstreams.GetShardIteratorInput,
+	_ ...func(*dynamodbstreams.Options),
+) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.iteratorRequests = append(f.iteratorRequests, time.Now())
+	shard := f.shard(aws.ToString(in.ShardId))
+	if shard == nil || shard.deleted {
+		return nil, &types.ResourceNotFoundException{}
+	}
+	if shard.trimmed || f.missingIterators > 0 {
+		f.missingIterators = max(f.missingIterators-1, 0)
+		return &dynamodbstreams.GetShardIteratorOutput{}, nil
+	}
+	pos := 0
+	switch in.ShardIteratorType {
+	case types.ShardIteratorTypeLatest:
+		pos = len(shard.records)
+	case types.ShardIteratorTypeAfterSequenceNumber:
+		pos = slices.Index(shard.records, aws.ToString(in.SequenceNumber)) + 1
+		if pos == 0 {
+			return nil, &types.TrimmedDataAccessException{}
+		}
+	}
+	return &dynamodbstreams.GetShardIteratorOutput{
+		ShardIterator: aws.String(fmt.Sprintf("%s:%d", shard.id, pos)),
+	}, nil
+}
+
+func (f *fakeStream) GetRecords(
+	_ context.Context,
+	in *dynamodbstreams.GetRecordsInput,
+	_ ...func(*dynamodbstreams.Options),
+) (*dynamodbstreams.GetRecordsOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.getRecordsErrors) > 0 {
+		err := f.getRecordsErrors[0]
+		f.getRecordsErrors = f.getRecordsErrors[1:]
+		return nil, err
+	}
+	if f.expireIterators > 0 {
+		f.expireIterators--
+		return nil, &types.ExpiredIteratorException{}
+	}
+
+	id, posStr, _ := strings.Cut(aws.ToString(in.ShardIterator), ":")
+	shard := f.shard(id)
+	pos, _ := strconv.Atoi(posStr)
+	end := len(shard.records)
+	if in.Limit != nil {
+		end = min(end, pos+int(*in.Limit))
+	}
+
+	out := &dynamodbstreams.GetRecordsOutput{}
+	for _, seq := range shard.records[pos:end] {
+		out.Records = append(out.Records, types.Record{
+			Dynamodb: &types.StreamRecord{SequenceNumber: aws.String(seq)},
+		})
+	}
+	if !shard.closed || end < len(shard.records) {
+		out.NextShardIterator = aws.String(fmt.Sprintf("%s:%d", shard.id, end))
+	}
+	return out, nil
+}
+
+var _ streams.API = &fakeStream{}
+
+type collector struct {
+	lock    sync.Mutex
+	records []string
+	done    chan struct{}
+	want    int
+}
+
+func newCollector(want int) *collector {
+	return &collector{done: make(chan struct{}), want: want}
+}
+
+func (c *collector) handle(_ context.Context, _ string, records []types.Record) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, record := range records {
+		c.records = append(c.records, aws.ToString(record.Dynamodb.SequenceNumber))
+	}
+	if len(c.records) == c.want {
+		close(c.done)
+	}
+	return nil
+}
+
+func runUntil(t *testing.T, consumer *streams.Consumer, done <-chan struct{}) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.Run(ctx)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatalf("consumer has not processed all records in time")
+	}
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected consumer to stop with context.Canceled, got %v", err)
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	t.Parallel()
+
+	opts := []streams.Option{
+		streams.WithPollInterval(time.Millisecond),
+		streams.WithDiscoveryInterval(10 * time.Millisecond),
+		streams.WithBackoff(time.Millisecond, 5*time.Millisecond),
+		streams.WithLimit(2),
+	}
+
+	t.Run("ShardLineage", func(t *testing.T) {
+		t.Parallel()
+		stream := &fakeStream{
+			shards: []*fakeShard{
+				{id: "child", parent: "parent", records: []string{"4", "5"}},
+				{id: "parent", parent: "trimmed", records: []string{"1", "2", "3"}, closed: true},
+			},
+			getRecordsErrors: []error{&types.LimitExceededException{}, &types.InternalServerError{}},
+			expireIterators:  1,
+		}
+		store := streams.NewMemoryCheckpointStore()
+		c := newCollector(5)
+		consumer := streams.NewConsumer(stream, streamARN, c.handle, append(opts, streams.WithCheckpointStore(store))...)
+		runUntil(t, consumer, c.done)
+
+		if !slices.Equal(c.records, []string{"1", "2", "3", "4", "5"}) {
+			t.Errorf("expected records of parent shard to be handled before records of child shard, got %v", c.records)
+		}
+		for shard, want := range map[string]string{"parent": streams.ShardEnd, "child": "5"} {
+			got, _ := store.GetCheckpoint(context.Background(), streamARN, shard)
+			if got != want {
+				t.Errorf("expected checkpoint of shard %s to be %q, got %q", shard, want, got)
+			}
+		}
+	})
+
+	t.Run("ResumeFromCheckpoint", func(t *testing.T) {
+		t.Parallel()
+		stream := &fakeStream{
+			shards: []*fakeShard{
+				{id: "parent", records: []string{"1", "2"}, closed: true},
+				{id: "child", parent: "parent", records: []string{"3", "4", "5"}},
+			},
+		}
+		store, err := streams.NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+		if err != nil {
+			t.Fatalf("failed to create checkpoint store: %v", err)
+		}
+		_ = store.SetCheckpoint(context.Background(), streamARN, "parent", streams.ShardEnd)
+		_ = store.SetCheckpoint(context.Background(), streamARN, "child", "3")
+
+		c := newCollector(2)
+		consumer := streams.NewConsumer(stream, streamARN, c.handle, append(opts, streams.WithCheckpointStore(store))...)
+		runUntil(t, consumer, c.done)
+
+		if !slices.Equal(c.records, []string{"4", "5"}) {
+			t.Errorf("expected only records after checkpoint to be handled, got %v", c.records)
+		}
+	})
+
+	t.Run("TrimmedShard", func(t *testing.T) {
+		t.Parallel()
+		stream := &fakeStream{
+			shards: []*fakeShard{
+				{id: "parent", records: []string{"1", "2"}, closed: true, trimmed: true},
+				{id: "child", parent: "parent", records: []string{"3"}},
+			},
+		}
+		store := streams.NewMemoryCheckpointStore()
+		c := newCollector(1)
+		consumer := streams.NewConsumer(stream, streamARN, c.handle, append(opts, streams.WithCheckpointStore(store))...)
+		runUntil(t, consumer, c.done)
+
+		if !slices.Equal(c.records, []string{"3"}) {
+			t.Errorf("expected only records of child shard to be handled, got %v", c.records)
+		}
+		if got, _ := store.GetCheckpoint(context.Background(), streamARN, "parent"); got != streams.ShardEnd {
+			t.Errorf("expected shard without iterator to be read to the end, got checkpoint %q", got)
+		}
+	})
+
+	t.Run("OpenShardWithoutIterator", func(t *testing.T) {
+		t.Parallel()
+		stream := &fakeStream{
+			shards:           []*fakeShard{{id: "shard", records: []string{"1"}}},
+			missingIterators: 2,
+		}
+		store := streams.NewMemoryCheckpointStore()
+		c := newCollector(1)
+		consumer := streams.NewConsumer(stream, streamARN, c.handle, append(opts, streams.WithCheckpointStore(store))...)
+		runUntil(t, consumer, c.done)
+
+		if !slices.Equal(c.records, []string{"1"}) {
+			t.Errorf("expected records of open shard to be handled, got %v", c.records)
+		}
+		if got, _ := store.GetCheckpoint(context.Background(), streamARN, "shard"); got != "1" {
+			t.Errorf("expected open shard not to be read to the end, got checkpoint %q", got)
+		}
+	})
+
+	t.Run("TrimmedCheckpoint", func(t *testing.T) {
+		t.Parallel()
+		stream := &fakeStream{
+			shards: []*fakeShard{{id: "shard", records: []string{"3", "4"}}},
+		}
+		store := streams.NewMemoryCheckpointStore()
+		_ = store.SetCheckpoint(context.Background(), streamARN, "shard", "1")
+		c := newCollector(2)
+		consumer := streams.NewConsumer(stream, streamARN, c.handle, append(opts, streams.WithCheckpointStore(store))...)
+		runUntil(t, consumer, c.done)
+
+		if !slices.Equal(c.records, []string{"3", "4"}) {
+			t.Errorf("expected shard to be read from trim horizon, got %v", c.records)
+		}
+	})
+
+	t.Run("PermanentError", func(t *testing.T) {
+		t.Parallel()
+		validationErr := errors.New("validation failed")
+		tcases := []struct {
+			name   string
+			stream *fakeStream
+			check  func(error) bool
+		}{
+			{
+				name:   "DeletedShard",
+				stream: &fakeStream{shards: []*fakeShard{{id: "shard", deleted: true}}},
+				check: func(err error) bool {
+					var notFound *types.ResourceNotFoundException
+					return errors.As(err, &notFound)
+				},
+			},
+			{
+				name: "GetRecords",
+				stream: &fakeStream{
+					shards:           []*fakeShard{{id: "shard", records: []string{"1"}}},
+					getRecordsErrors: []error{validationErr},
+				},
+				check: func(err error) bool {
+					return errors.Is(err, validationErr)
+				},
+			},
+		}
+		for _, tc := range tcases {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+				consumer := streams.NewConsumer(tc.stream, streamARN, newCollector(1).handle, opts...)
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				err := consumer.Run(ctx)
+				if !tc.check(err) {
+					t.Fatalf("expected permanent error to be returned, got %v", err)
+				}
+				if !strings.Contains(err.Error(), "shard shard") {
+					t.Errorf("expected error to name the shard, got %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("ExpiredIteratorBackoff", func(t *testing.T) {
+		t.Parallel()
+		stream := &fakeStream{
+			shards:          []*fakeShard{{id: "shard", records: []string{"1"}}},
+			expireIterators: 2,
+		}
+		c := newCollector(1)
+		consumer := streams.NewConsumer(stream, streamARN, c.handle,
+			streams.WithPollInterval(time.Millisecond),
+			streams.WithBackoff(20*time.Millisecond, 100*time.Millisecond),
+		)
+		runUntil(t, consumer, c.done)
+
+		stream.lock.Lock()
+		defer stream.lock.Unlock()
+		if len(stream.iteratorRequests) != 3 {
+			t.Fatalf("expected iterator to be requested 3 times, got %d", len(stream.iteratorRequests))
+		}
+		for i := 1; i < len(stream.iteratorRequests); i++ {
+			if gap := stream.iteratorRequests[i].Sub(stream.iteratorRequests[i-1]); gap < 20*time.Millisecond {
+				t.Errorf("expected expired iterator to be renewed after backoff, got %v", gap)
+			}
+		}
+	})
+
+	t.Run("HandlerError", func(t *testing.T) {
+		t.Parallel()
+		stream := &fakeStream{
+			shards: []*fakeShard{{id: "shard", records: []string{"1"}}},
+		}
+		handlerErr := errors.New("handler failed")
+		consumer := streams.NewConsumer(stream, streamARN, func(context.Context, string, []types.Record) error {
+			return handlerErr
+		}, opts...)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := consumer.Run(ctx); !errors.Is(err, handlerErr) {
+			t.Fatalf("expected handler error, got %v", err)
+		}
+	})
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	store, err := streams.NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatalf("failed to create checkpoint store: %v", err)
+	}
+	ctx := context.Background()
+	if err = store.SetCheckpoint(ctx, streamARN, "shard", "42"); err != nil {
+		t.Fatalf("failed to store checkpoint: %v", err)
+	}
+
+	reopened, err := streams.NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen checkpoint store: %v", err)
+	}
+	got, err := reopened.GetCheckpoint(ctx, streamARN, "shard")
+	if err != nil {
+		t.Fatalf("failed to read checkpoint: %v", err)
+	}
+	if got != "42" {
+		t.Fatalf("expected checkpoint %q, got %q", "42", got)
+	}
+	if got, _ = reopened.GetCheckpoint(ctx, streamARN, "other"); got != "" {
+		t.Fatalf("expected no checkpoint for unknown shard, got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package sdkv2
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+const tableRoutingMiddlewareID = "AlternatorTableRouting"
+
+// tableRoutingMiddleware routes operation according to the route of the table it targets,
+//
+//	see `shared.WithTableRoute`
+func (lb *Helper) tableRoutingMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(
+		middleware.InitializeMiddlewareFunc(
+			tableRoutingMiddlewareID,
+			func(
+				ctx context.Context,
+				in middleware.InitializeInput,
+				next middleware.InitializeHandler,
+			) (middleware.InitializeOutput, middleware.Metadata, error) {
+				return next.HandleInitialize(lb.cfg.TableRoutes.Apply(ctx, tableName(in.Parameters)), in)
+			},
+		),
+		middleware.After,
+	)
+}
+
+// tableName returns table of the operation input, taken from `TableName` field or, for batch operations,
+//
+//	from the only key of `RequestItems`, empty string if operation targets no table or several tables
+func tableName(params any) string {
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ""
+	}
+	v = v.Elem()
+	if field := v.FieldByName("TableName"); field.IsValid() {
+		if name, ok := field.Interface().(*string); ok && name != nil {
+			return *name
+		}
+		return ""
+	}
+	if field := v.FieldByName("RequestItems"); field.IsValid() && field.Kind() == reflect.Map && field.Len() == 1 {
+		if key := field.MapKeys()[0]; key.Kind() == reflect.String {
+			return key.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,331 @@
+// Package alternatortest provides an in-process fake Alternator cluster for tests.
+//
+// Every node of the cluster is an httptest server listening on its own loopback
+// address (127.0.0.1, 127.0.0.2, ...) and on the same port, the same way real
+// Alternator nodes share a port, so the cluster can be used with the helpers as is.
+// On platforms where only 127.0.0.1 is routable (e.g. macOS) additional loopback
+// addresses have to be configured first, e.g. "sudo ifconfig lo0 alias 127.0.0.2 up",
+// until then NewCluster of more than one node fails with ErrLoopbackAliasesUnavailable
+// and StartCluster skips the test.
+//
+// The fake nodes serve:
+//   - "/localnodes" with "dc" and "rack" filtering.
+//   - "/" health check.
+//   - A minimal in-memory DynamoDB JSON protocol: CreateTable, DescribeTable, DeleteTable,
+//     PutItem, GetItem, DeleteItem, Query and Scan, and DynamoDB Streams ListStreams.
+//   - Scan of ".scylla.alternator.system.local" and ".scylla.alternator.system.peers" tables.
+//
+// Misbehaviour can be scripted per node via Fault, see Node.InjectFault.
+//
+// Example:
+//
+//	cluster, err := alternatortest.NewCluster(alternatortest.Topology("dc1", "rack1", 3))
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	defer cluster.Close()
+//
+//	h, err := sdkv2.NewHelper(cluster.Hosts(), sdkv2.WithPort(cluster.Port()))
+package alternatortest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const (
+	defaultDatacenter = "datacenter1"
+	defaultRack       = "rack1"
+	listenAttempts    = 10
+)
+
+// ErrLoopbackAliasesUnavailable is returned by `NewCluster` when platform doesn't route loopback addresses
+// other than 127.0.0.1 and nodes can't listen on them
+var ErrLoopbackAliasesUnavailable = errors.New("loopback addresses other than 127.0.0.1 are not configured")
+
+// NodeConfig describes location of a fake node in the cluster topology
+type NodeConfig struct {
+	Datacenter string
+	Rack       string
+}
+
+// Topology returns configuration for `count` nodes located in the same datacenter and rack
+func Topology(datacenter, rack string, count int) []NodeConfig {
+	out := make([]NodeConfig, count)
+	for i := range out {
+		out[i] = NodeConfig{Datacenter: datacenter, Rack: rack}
+	}
+	return out
+}
+
+// Option a configuration option for `NewCluster`
+type Option func(config *clusterConfig)
+
+type clusterConfig struct {
+	tls bool
+}
+
+// WithTLS makes nodes serve https with a self-signed certificate,
+//
+//	clients have to ignore server certificate errors to talk to them
+func WithTLS() Option {
+	return func(config *clusterConfig) {
+		config.tls = true
+	}
+}
+
+// Cluster is a fake Alternator cluster, all nodes share the same in-memory tables
+type Cluster struct {
+	nodes  []*Node
+	port   int
+	scheme string
+	store  *store
+}
+
+// NewCluster starts a fake node for every provided `NodeConfig`,
+//
+//	nodes with empty datacenter or rack are placed into "datacenter1" and "rack1"
+func NewCluster(nodes []NodeConfig, opts ...Option) (*Cluster, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("cluster should have at least one node")
+	}
+	if len(nodes) > 254 {
+		return nil, errors.New("cluster can't have more than 254 nodes")
+	}
+
+	cfg := clusterConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	c := &Cluster{
+		scheme: "http",
+		store:  newStore(),
+	}
+	if cfg.tls {
+		c.scheme = "https"
+	}
+
+	var (
+		listeners []net.Listener
+		err       error
+	)
+	for range listenAttempts {
+		listeners, err = listenOnLoopbacks(len(nodes))
+		if err == nil || errors.Is(err, ErrLoopbackAliasesUnavailable) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on loopback addresses: %w", err)
+	}
+	c.port = listeners[0].Addr().(*net.TCPAddr).Port
+
+	for i, nodeCfg := range nodes {
+		if nodeCfg.Datacenter == "" {
+			nodeCfg.Datacenter = defaultDatacenter
+		}
+		if nodeCfg.Rack == "" {
+			nodeCfg.Rack = defaultRack
+		}
+		n := &Node{
+			Datacenter: nodeCfg.Datacenter,
+			Rack:       nodeCfg.Rack,
+			host:       listeners[i].Addr().(*net.TCPAddr).IP.String(),
+			cluster:    c,
+		}
+		n.server = httptest.NewUnstartedServer(n)
+		_ = n.server.Listener.Close()
+		n.server.Listener = listeners[i]
+		if cfg.tls {
+			n.server.StartTLS()
+		} else {
+			n.server.Start()
+		}
+		c.nodes = append(c.nodes, n)
+	}
+	return c, nil
+}
+
+func listenOnLoopbacks(count int) ([]net.Listener, error) {
+	first, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	port := first.Addr().(*net.TCPAddr).Port
+	listeners := []net.Listener{first}
+	for i := 2; i <= count; i++ {
+		l, err := net.Listen("tcp", net.JoinHostPort(fmt.Sprintf("127.0.0.%d", i), strconv.Itoa(port)))
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			if errors.Is(err, syscall.EADDRNOTAVAIL) {
+				return nil, fmt.Errorf("%w: %w", ErrLoopbackAliasesUnavailable, err)
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// Close shuts down all nodes of the cluster
+func (c *Cluster) Close() {
+	for _, n := range c.nodes {
+		n.server.Close()
+	}
+}
+
+// Port returns port all nodes listen on
+func (c *Cluster) Port() int {
+	return c.port
+}
+
+// Scheme returns scheme nodes serve: http or https
+func (c *Cluster) Scheme() string {
+	return c.scheme
+}
+
+// Hosts returns addresses of all nodes, without port
+func (c *Cluster) Hosts() []string {
+	out := make([]string, len(c.nodes))
+	for i, n := range c.nodes {
+		out[i] = n.host
+	}
+	return out
+}
+
+// Nodes returns all nodes of the cluster
+func (c *Cluster) Nodes() []*Node {
+	return c.nodes
+}
+
+// Node returns node by its index
+func (c *Cluster) Node(idx int) *Node {
+	return c.nodes[idx]
+}
+
+// NodeByHost returns node by its address, nil if there is no such node
+func (c *Cluster) NodeByHost(host string) *Node {
+	for _, n := range c.nodes {
+		if n.host == host {
+			return n
+		}
+	}
+	return nil
+}
+
+// Node is a single fake Alternator node
+type Node struct {
+	Datacenter string
+	Rack       string
+
+	host       string
+	server     *httptest.Server
+	cluster    *Cluster
+	down       atomic.Bool
+	requests   atomic.Int64
+	faultsLock sync.Mutex
+	faults     []Fault
+}
+
+// Host returns address of the node, without port
+func (n *Node) Host() string {
+	return n.host
+}
+
+// URL returns base URL of the node
+func (n *Node) URL() url.URL {
+	return url.URL{
+		Scheme: n.cluster.scheme,
+		Host:   net.JoinHostPort(n.host, strconv.Itoa(n.cluster.port)),
+	}
+}
+
+// Requests returns number of requests node has received
+func (n *Node) Requests() int64 {
+	return n.requests.Load()
+}
+
+// SetDown marks node as down, down node resets all connections and is not listed in "/localnodes"
+func (n *Node) SetDown(down bool) {
+	n.down.Store(down)
+}
+
+// IsDown reports whether node is marked as down
+func (n *Node) IsDown() bool {
+	return n.down.Load()
+}
+
+// InjectFault adds faults that are consulted, in order, before request is served
+func (n *Node) InjectFault(faults ...Fault) {
+	n.faultsLock.Lock()
+	defer n.faultsLock.Unlock()
+	n.faults = append(n.faults, faults...)
+}
+
+// ClearFaults removes all injected faults
+func (n *Node) ClearFaults() {
+	n.faultsLock.Lock()
+	defer n.faultsLock.Unlock()
+	n.faults = nil
+}
+
+// ServeHTTP implements `http.Handler`
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.requests.Add(1)
+	if n.down.Load() {
+		ResetConnection()(w, r)
+		return
+	}
+
+	n.faultsLock.Lock()
+	faults := append([]Fault(nil), n.faults...)
+	n.faultsLock.Unlock()
+	for _, fault := range faults {
+		if fault(w, r) {
+			return
+		}
+	}
+
+	switch {
+	case r.URL.Path == "/localnodes" && r.Method == http.MethodGet:
+		n.serveLocalNodes(w, r)
+	case r.URL.Path == "/" && r.Method == http.MethodGet:
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "healthy: %s", r.Host)
+	case r.URL.Path == "/" && r.Method == http.MethodPost:
+		if !n.serveSystemTable(w, r) {
+			n.cluster.store.serveDynamoDB(w, r)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (n *Node) serveLocalNodes(w http.ResponseWriter, r *http.Request) {
+	dc := r.URL.Query().Get("dc")
+	if dc == "" {
+		// Same as Alternator, when no datacenter is given, nodes from the local one are returned
+		dc = n.Datacenter
+	}
+	rack := r.URL.Query().Get("rack")
+
+	hosts := []string{}
+	for _, node := range n.cluster.nodes {
+		if node.down.Load() || node.Datacenter != dc || (rack != "" && node.Rack != rack) {
+			continue
+		}
+		hosts = append(hosts, node.host)
+	}
+	writeJSON(w, http.StatusOK, hosts)
+}
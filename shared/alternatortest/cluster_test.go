@@ -0,0 +1,114 @@
+package alternatortest_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/alternatortest"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+func newCluster(t *testing.T) *alternatortest.Cluster {
+	t.Helper()
+	cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+		{Datacenter: "dc1", Rack: "rack1"},
+		{Datacenter: "dc1", Rack: "rack2"},
+		{Datacenter: "dc2", Rack: "rack1"},
+	})
+	return cluster
+}
+
+func TestLocalNodes(t *testing.T) {
+	t.Parallel()
+	cluster := newCluster(t)
+	hosts := cluster.Hosts()
+
+	tcases := []struct {
+		name     string
+		scope    rt.Scope
+		expected []string
+	}{
+		{name: "Cluster", scope: rt.NewClusterScope(), expected: hosts[:2]},
+		{name: "DC", scope: rt.NewDCScope("dc2", nil), expected: hosts[2:]},
+		{name: "Rack", scope: rt.NewRackScope("dc1", "rack2", nil), expected: hosts[1:2]},
+		{
+			name:     "Fallback",
+			scope:    rt.NewRackScope("dc1", "rack3", rt.NewDCScope("dc2", nil)),
+			expected: hosts[2:],
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			aln, err := shared.NewAlternatorLiveNodes(
+				hosts[:1],
+				shared.WithALNPort(cluster.Port()),
+				shared.WithALNRoutingScope(tc.scope),
+				shared.WithALNLogger(logx.Noop{}),
+			)
+			if err != nil {
+				t.Fatalf("failed to create AlternatorLiveNodes: %v", err)
+			}
+			defer aln.Stop()
+
+			if err = aln.UpdateLiveNodes(); err != nil {
+				t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+			}
+			var got []string
+			for _, node := range aln.GetNodes() {
+				got = append(got, node.Hostname())
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("expected nodes %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFaults(t *testing.T) {
+	t.Parallel()
+	cluster := newCluster(t)
+	node := cluster.Node(0)
+	nodeURL := node.URL()
+
+	node.InjectFault(alternatortest.Times(1, alternatortest.OnPath("/", alternatortest.InternalServerError())))
+	// Requests the fault skips don't count
+	localNodesURL := nodeURL
+	localNodesURL.Path = "/localnodes"
+	resp, err := http.Get(localNodesURL.String())
+	if err != nil {
+		t.Fatalf("request unexpectedly failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	resp, err = http.Get(nodeURL.String())
+	if err != nil {
+		t.Fatalf("request unexpectedly failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	resp, err = http.Get(nodeURL.String())
+	if err != nil {
+		t.Fatalf("request unexpectedly failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	node.SetDown(true)
+	if resp, err = http.Get(nodeURL.String()); err == nil {
+		_ = resp.Body.Close()
+		t.Fatalf("request to a down node should have failed")
+	}
+}
@@ -0,0 +1,634 @@
+package alternatortest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const errorTypePrefix = "com.amazonaws.dynamodb.v20120810#"
+
+type apiError struct {
+	status    int
+	errorType string
+	message   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.errorType, e.message)
+}
+
+func validationError(format string, args ...any) *apiError {
+	return &apiError{
+		status:    http.StatusBadRequest,
+		errorType: "ValidationException",
+		message:   fmt.Sprintf(format, args...),
+	}
+}
+
+func tableNotFound(name string) *apiError {
+	return &apiError{
+		status:    http.StatusBadRequest,
+		errorType: "ResourceNotFoundException",
+		message:   fmt.Sprintf("Requested resource not found: Table: %s not found", name),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, err *apiError) {
+	writeJSON(w, err.status, map[string]string{
+		"__type":  errorTypePrefix + err.errorType,
+		"message": err.message,
+	})
+}
+
+// item is a DynamoDB item, values are kept in their JSON form, e.g. {"S": "value"}
+type item map[string]json.RawMessage
+
+type keySchemaElement struct {
+	AttributeName string
+	KeyType       string
+}
+
+type table struct {
+	name                 string
+	hashKey              string
+	rangeKey             string
+	keySchema            []keySchemaElement
+	attributeDefinitions json.RawMessage
+	streamSpecification  *streamSpecification
+	created              time.Time
+	items                map[string]item
+}
+
+type streamSpecification struct {
+	StreamEnabled  bool
+	StreamViewType string `json:",omitempty"`
+}
+
+func (t *table) streamARN() string {
+	return fmt.Sprintf("arn:scylla:alternator:::table/%s/stream/%s", t.name, t.created.UTC().Format(time.RFC3339Nano))
+}
+
+func (t *table) description(status string) map[string]any {
+	out := map[string]any{
+		"TableName":            t.name,
+		"TableStatus":          status,
+		"KeySchema":            t.keySchema,
+		"AttributeDefinitions": t.attributeDefinitions,
+		"ItemCount":            len(t.items),
+		"CreationDateTime":     t.created.Unix(),
+	}
+	if t.streamSpecification != nil && t.streamSpecification.StreamEnabled {
+		out["StreamSpecification"] = t.streamSpecification
+		out["LatestStreamArn"] = t.streamARN()
+	}
+	return out
+}
+
+func (t *table) itemKey(key item) (string, *apiError) {
+	out, err := keyAttribute(key, t.hashKey)
+	if err != nil {
+		return "", err
+	}
+	if t.rangeKey != "" {
+		rng, err := keyAttribute(key, t.rangeKey)
+		if err != nil {
+			return "", err
+		}
+		out += "\x00" + rng
+	}
+	return out, nil
+}
+
+func keyAttribute(key item, name string) (string, *apiError) {
+	v, ok := key[name]
+	if !ok {
+		return "", validationError("One of the required keys was not given a value")
+	}
+	typ, val, err := scalar(v)
+	if err != nil {
+		return "", err
+	}
+	return typ + ":" + val, nil
+}
+
+func (t *table) keyOf(it item) item {
+	out := item{t.hashKey: it[t.hashKey]}
+	if t.rangeKey != "" {
+		out[t.rangeKey] = it[t.rangeKey]
+	}
+	return out
+}
+
+// sortedItems returns items ordered by hash key and then by range key
+func (t *table) sortedItems() []item {
+	out := make([]item, 0, len(t.items))
+	for _, it := range t.items {
+		out = append(out, it)
+	}
+	slices.SortFunc(out, func(a, b item) int {
+		aHash, _ := keyAttribute(a, t.hashKey)
+		bHash, _ := keyAttribute(b, t.hashKey)
+		if c := strings.Compare(aHash, bHash); c != 0 {
+			return c
+		}
+		if t.rangeKey == "" {
+			return 0
+		}
+		c, _ := compareValues(a[t.rangeKey], b[t.rangeKey])
+		return c
+	})
+	return out
+}
+
+// readBody reads request body, decoding it if it is gzip encoded
+func readBody(r *http.Request) ([]byte, error) {
+	switch encoding := r.Header.Get("Content-Encoding"); {
+	case encoding == "":
+		return io.ReadAll(r.Body)
+	case strings.EqualFold(encoding, "gzip"):
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close() //nolint: errcheck // no need to check
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+type store struct {
+	lock   sync.Mutex
+	tables map[string]*table
+}
+
+func newStore() *store {
+	return &store{tables: map[string]*table{}}
+}
+
+func (s *store) serveDynamoDB(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, validationError("failed to read request body: %v", err))
+		return
+	}
+
+	handlers := map[string]func([]byte) (any, *apiError){
+		"CreateTable":   s.createTable,
+		"DescribeTable": s.describeTable,
+		"DeleteTable":   s.deleteTable,
+		"PutItem":       s.putItem,
+		"GetItem":       s.getItem,
+		"DeleteItem":    s.deleteItem,
+		"Query":         s.query,
+		"Scan":          s.scan,
+		"ListStreams":   s.listStreams,
+	}
+	op := operationName(r)
+	handler, ok := handlers[op]
+	if !ok {
+		writeError(w, &apiError{
+			status:    http.StatusBadRequest,
+			errorType: "UnknownOperationException",
+			message:   fmt.Sprintf("Unsupported operation %s", op),
+		})
+		return
+	}
+
+	s.lock.Lock()
+	resp, apiErr := handler(body)
+	s.lock.Unlock()
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func decode(body []byte, v any) *apiError {
+	if err := json.Unmarshal(body, v); err != nil {
+		return validationError("failed to parse request: %v", err)
+	}
+	return nil
+}
+
+func (s *store) table(name string) (*table, *apiError) {
+	t, ok := s.tables[name]
+	if !ok {
+		return nil, tableNotFound(name)
+	}
+	return t, nil
+}
+
+func (s *store) createTable(body []byte) (any, *apiError) {
+	var req struct {
+		TableName            string
+		KeySchema            []keySchemaElement
+		AttributeDefinitions json.RawMessage
+		StreamSpecification  *streamSpecification
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if _, ok := s.tables[req.TableName]; ok {
+		return nil, &apiError{
+			status:    http.StatusBadRequest,
+			errorType: "ResourceInUseException",
+			message:   fmt.Sprintf("Table %s already exists", req.TableName),
+		}
+	}
+
+	t := &table{
+		name:                 req.TableName,
+		keySchema:            req.KeySchema,
+		attributeDefinitions: req.AttributeDefinitions,
+		streamSpecification:  req.StreamSpecification,
+		created:              time.Now(),
+		items:                map[string]item{},
+	}
+	for _, el := range req.KeySchema {
+		switch el.KeyType {
+		case "HASH":
+			t.hashKey = el.AttributeName
+		case "RANGE":
+			t.rangeKey = el.AttributeName
+		}
+	}
+	if t.hashKey == "" {
+		return nil, validationError("KeySchema should contain HASH key")
+	}
+	s.tables[t.name] = t
+	return map[string]any{"TableDescription": t.description("ACTIVE")}, nil
+}
+
+func (s *store) describeTable(body []byte) (any, *apiError) {
+	var req struct{ TableName string }
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"Table": t.description("ACTIVE")}, nil
+}
+
+func (s *store) deleteTable(body []byte) (any, *apiError) {
+	var req struct{ TableName string }
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.tables, req.TableName)
+	return map[string]any{"TableDescription": t.description("DELETING")}, nil
+}
+
+func (s *store) putItem(body []byte) (any, *apiError) {
+	var req struct {
+		TableName string
+		Item      item
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.itemKey(req.Item)
+	if err != nil {
+		return nil, err
+	}
+	t.items[key] = req.Item
+	return map[string]any{}, nil
+}
+
+func (s *store) getItem(body []byte) (any, *apiError) {
+	var req struct {
+		TableName string
+		Key       item
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.itemKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if it, ok := t.items[key]; ok {
+		return map[string]any{"Item": it}, nil
+	}
+	return map[string]any{}, nil
+}
+
+func (s *store) deleteItem(body []byte) (any, *apiError) {
+	var req struct {
+		TableName string
+		Key       item
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.itemKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	delete(t.items, key)
+	return map[string]any{}, nil
+}
+
+type pageRequest struct {
+	TableName         string
+	Limit             int
+	ExclusiveStartKey item
+}
+
+func (s *store) scan(body []byte) (any, *apiError) {
+	var req pageRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return t.page(t.sortedItems(), req), nil
+}
+
+func (s *store) query(body []byte) (any, *apiError) {
+	var req struct {
+		pageRequest
+		KeyConditionExpression    string
+		ExpressionAttributeNames  map[string]string
+		ExpressionAttributeValues item
+		ScanIndexForward          *bool
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	conditions, err := parseKeyCondition(
+		req.KeyConditionExpression,
+		req.ExpressionAttributeNames,
+		req.ExpressionAttributeValues,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(conditions, func(c condition) bool { return c.attribute == t.hashKey && c.op == "=" }) {
+		return nil, validationError("Query condition missed key schema element: %s", t.hashKey)
+	}
+
+	var matched []item
+	for _, it := range t.sortedItems() {
+		ok, err := matchConditions(it, conditions)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, it)
+		}
+	}
+	if req.ScanIndexForward != nil && !*req.ScanIndexForward {
+		slices.Reverse(matched)
+	}
+	return t.page(matched, req.pageRequest), nil
+}
+
+func (t *table) page(items []item, req pageRequest) map[string]any {
+	if req.ExclusiveStartKey != nil {
+		startKey, _ := t.itemKey(req.ExclusiveStartKey)
+		for i, it := range items {
+			if key, _ := t.itemKey(it); key == startKey {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+	out := map[string]any{}
+	if req.Limit > 0 && len(items) > req.Limit {
+		items = items[:req.Limit]
+		out["LastEvaluatedKey"] = t.keyOf(items[len(items)-1])
+	}
+	if items == nil {
+		items = []item{}
+	}
+	out["Items"] = items
+	out["Count"] = len(items)
+	out["ScannedCount"] = len(items)
+	return out
+}
+
+type condition struct {
+	attribute string
+	op        string
+	values    []json.RawMessage
+}
+
+var keyConditionTokenRe = regexp.MustCompile(`<=|>=|=|<|>|\(|\)|,|[#:]?[A-Za-z0-9_.\-]+`)
+
+// parseKeyCondition parses KeyConditionExpression, supported forms are:
+//
+//	a = :v, a < :v, a <= :v, a > :v, a >= :v, a BETWEEN :v1 AND :v2, begins_with(a, :v)
+//
+// joined by AND
+func parseKeyCondition(expr string, names map[string]string, values item) ([]condition, *apiError) {
+	tokens := keyConditionTokenRe.FindAllString(expr, -1)
+	pos := 0
+	next := func() string {
+		if pos >= len(tokens) {
+			return ""
+		}
+		pos++
+		return tokens[pos-1]
+	}
+	name := func(token string) string {
+		if strings.HasPrefix(token, "#") {
+			return names[token]
+		}
+		return token
+	}
+	value := func(token string) (json.RawMessage, *apiError) {
+		v, ok := values[token]
+		if !ok {
+			return nil, validationError("Value %s is not defined in ExpressionAttributeValues", token)
+		}
+		return v, nil
+	}
+
+	var out []condition
+	for {
+		var c condition
+		token := next()
+		if strings.EqualFold(token, "begins_with") {
+			if next() != "(" {
+				return nil, validationError("Invalid KeyConditionExpression: %s", expr)
+			}
+			c.attribute = name(next())
+			c.op = "begins_with"
+			if next() != "," {
+				return nil, validationError("Invalid KeyConditionExpression: %s", expr)
+			}
+			v, err := value(next())
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, v)
+			if next() != ")" {
+				return nil, validationError("Invalid KeyConditionExpression: %s", expr)
+			}
+		} else {
+			c.attribute = name(token)
+			c.op = strings.ToUpper(next())
+			switch c.op {
+			case "=", "<", "<=", ">", ">=":
+				v, err := value(next())
+				if err != nil {
+					return nil, err
+				}
+				c.values = append(c.values, v)
+			case "BETWEEN":
+				low, err := value(next())
+				if err != nil {
+					return nil, err
+				}
+				if !strings.EqualFold(next(), "AND") {
+					return nil, validationError("Invalid KeyConditionExpression: %s", expr)
+				}
+				high, err := value(next())
+				if err != nil {
+					return nil, err
+				}
+				c.values = append(c.values, low, high)
+			default:
+				return nil, validationError("Invalid KeyConditionExpression: %s", expr)
+			}
+		}
+		out = append(out, c)
+
+		switch token := next(); {
+		case token == "":
+			return out, nil
+		case strings.EqualFold(token, "AND"):
+		default:
+			return nil, validationError("Invalid KeyConditionExpression: %s", expr)
+		}
+	}
+}
+
+func matchConditions(it item, conditions []condition) (bool, *apiError) {
+	for _, c := range conditions {
+		v, ok := it[c.attribute]
+		if !ok {
+			return false, nil
+		}
+		cmp, err := compareValues(v, c.values[0])
+		if err != nil {
+			return false, err
+		}
+		var matched bool
+		switch c.op {
+		case "=":
+			matched = cmp == 0
+		case "<":
+			matched = cmp < 0
+		case "<=":
+			matched = cmp <= 0
+		case ">":
+			matched = cmp > 0
+		case ">=":
+			matched = cmp >= 0
+		case "BETWEEN":
+			high, err := compareValues(v, c.values[1])
+			if err != nil {
+				return false, err
+			}
+			matched = cmp >= 0 && high <= 0
+		case "begins_with":
+			_, a, _ := scalar(v)
+			_, prefix, _ := scalar(c.values[0])
+			matched = strings.HasPrefix(a, prefix)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// scalar returns type and value of scalar attribute value: S, N or B
+func scalar(v json.RawMessage) (string, string, *apiError) {
+	var av map[string]any
+	if err := json.Unmarshal(v, &av); err != nil {
+		return "", "", validationError("invalid attribute value: %v", err)
+	}
+	for _, typ := range []string{"S", "N", "B"} {
+		if val, ok := av[typ].(string); ok {
+			return typ, val, nil
+		}
+	}
+	return "", "", validationError("key attribute should be of type S, N or B")
+}
+
+func compareValues(a, b json.RawMessage) (int, *apiError) {
+	aType, aVal, err := scalar(a)
+	if err != nil {
+		return 0, err
+	}
+	bType, bVal, err := scalar(b)
+	if err != nil {
+		return 0, err
+	}
+	if aType != bType {
+		return 0, validationError("type mismatch between %s and %s", aType, bType)
+	}
+	switch aType {
+	case "N":
+		aNum, ok := new(big.Float).SetString(aVal)
+		if !ok {
+			return 0, validationError("invalid number %s", aVal)
+		}
+		bNum, ok := new(big.Float).SetString(bVal)
+		if !ok {
+			return 0, validationError("invalid number %s", bVal)
+		}
+		return aNum.Cmp(bNum), nil
+	case "B":
+		aBytes, _ := base64.StdEncoding.DecodeString(aVal)
+		bBytes, _ := base64.StdEncoding.DecodeString(bVal)
+		return bytes.Compare(aBytes, bBytes), nil
+	default:
+		return strings.Compare(aVal, bVal), nil
+	}
+}
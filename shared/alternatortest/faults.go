@@ -0,0 +1,119 @@
+package alternatortest
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Fault is consulted before node serves a request, it returns true when it has fully handled the request
+//
+//	and node should not serve it
+type Fault func(w http.ResponseWriter, r *http.Request) bool
+
+// Latency delays the request by given duration and lets node serve it afterward
+func Latency(d time.Duration) Fault {
+	return func(_ http.ResponseWriter, r *http.Request) bool {
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+		}
+		return false
+	}
+}
+
+// ErrorResponse makes node respond with DynamoDB error of given type
+func ErrorResponse(status int, errorType, message string) Fault {
+	return func(w http.ResponseWriter, _ *http.Request) bool {
+		writeError(w, &apiError{status: status, errorType: errorType, message: message})
+		return true
+	}
+}
+
+// Throttling makes node respond with `ThrottlingException`
+func Throttling() Fault {
+	return ErrorResponse(http.StatusBadRequest, "ThrottlingException", "Rate of requests exceeds the allowed throughput")
+}
+
+// InternalServerError makes node respond with `InternalServerError`
+func InternalServerError() Fault {
+	return ErrorResponse(http.StatusInternalServerError, "InternalServerError", "Internal server error")
+}
+
+// ResetConnection makes node drop the connection without responding
+func ResetConnection() Fault {
+	return func(w http.ResponseWriter, _ *http.Request) bool {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		conn, _, err := hj.Hijack()
+		if err != nil {
+			return true
+		}
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			// Zero linger makes close send RST instead of FIN
+			_ = tcpConn.SetLinger(0)
+		}
+		_ = conn.Close()
+		return true
+	}
+}
+
+// BlackHole makes node never respond, request hangs until client gives up
+func BlackHole() Fault {
+	return func(_ http.ResponseWriter, r *http.Request) bool {
+		<-r.Context().Done()
+		return true
+	}
+}
+
+// OnOperation applies the fault only to given DynamoDB operation, e.g. "PutItem"
+func OnOperation(operation string, fault Fault) Fault {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if operationName(r) != operation {
+			return false
+		}
+		return fault(w, r)
+	}
+}
+
+// OnPath applies the fault only to requests to given path, e.g. "/localnodes"
+func OnPath(path string, fault Fault) Fault {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != path {
+			return false
+		}
+		return fault(w, r)
+	}
+}
+
+// Times applies the fault only to first `n` requests it is applied to, requests the fault skips,
+// e.g. ones to other operation of `OnOperation`, are not counted
+func Times(n int, fault Fault) Fault {
+	var counter atomic.Int64
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		for {
+			applied := counter.Load()
+			if applied >= int64(n) {
+				return false
+			}
+			if counter.CompareAndSwap(applied, applied+1) {
+				break
+			}
+		}
+		if fault(w, r) {
+			return true
+		}
+		counter.Add(-1)
+		return false
+	}
+}
+
+func operationName(r *http.Request) string {
+	_, op, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+	return op
+}
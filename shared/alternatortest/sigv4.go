@@ -0,0 +1,164 @@
+package alternatortest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// RequireSigV4 makes node reject DynamoDB requests that are not signed with given credentials,
+//
+//	signature is verified strictly, so any header signed by the client and modified or removed afterward fails it
+func RequireSigV4(accessKeyID, secretAccessKey string) Fault {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost {
+			return false
+		}
+		if err := verifySigV4(r, accessKeyID, secretAccessKey); err != nil {
+			writeError(w, err)
+			return true
+		}
+		return false
+	}
+}
+
+func verifySigV4(r *http.Request, accessKeyID, secretAccessKey string) *apiError {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return &apiError{
+			status:    http.StatusBadRequest,
+			errorType: "MissingAuthenticationTokenException",
+			message:   "Request is missing Authentication Token",
+		}
+	}
+	invalid := func(format string, args ...any) *apiError {
+		return &apiError{
+			status:    http.StatusBadRequest,
+			errorType: "InvalidSignatureException",
+			message:   fmt.Sprintf(format, args...),
+		}
+	}
+
+	algorithm, params, _ := strings.Cut(auth, " ")
+	if algorithm != sigV4Algorithm {
+		return invalid("unsupported signing algorithm %q", algorithm)
+	}
+	fields := map[string]string{}
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		fields[key] = value
+	}
+	key, scope, _ := strings.Cut(fields["Credential"], "/")
+	if key != accessKeyID {
+		return invalid("unknown access key %q", key)
+	}
+	scopeParts := strings.Split(scope, "/")
+	if len(scopeParts) != 4 || scopeParts[3] != "aws4_request" {
+		return invalid("malformed credential scope %q", scope)
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		return invalid("X-Amz-Date header is missing")
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return validationError("failed to read request body: %v", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	// Signature has to cover body as it is received, including its encoding
+	payloadHash := hashHex(body)
+	if header := r.Header.Get("X-Amz-Content-Sha256"); header != "" && header != payloadHash {
+		return invalid("X-Amz-Content-Sha256 does not match request body")
+	}
+
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	if r.Header.Get("Content-Encoding") != "" && !slices.Contains(signedHeaders, "content-encoding") {
+		return invalid("Content-Encoding header is not signed")
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		if name == "host" {
+			values = []string{r.Host}
+		} else {
+			values = r.Header.Values(name)
+		}
+		if len(values) == 0 {
+			return invalid("signed header %q is missing", name)
+		}
+		for i, v := range values {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalPath(r.URL),
+		canonicalQuery(r.URL),
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := []byte("AWS4" + secretAccessKey)
+	for _, part := range scopeParts {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	expected := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(fields["Signature"])) {
+		return invalid("The request signature we calculated does not match the signature you provided")
+	}
+	return nil
+}
+
+func canonicalPath(u *url.URL) string {
+	if p := u.EscapedPath(); p != "" {
+		return p
+	}
+	return "/"
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var parts []string
+	for _, k := range keys {
+		values := slices.Clone(query[k])
+		slices.Sort(values)
+		for _, v := range values {
+			parts = append(parts, queryEscape(k)+"="+queryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func queryEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
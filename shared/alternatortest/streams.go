@@ -0,0 +1,31 @@
+package alternatortest
+
+import (
+	"slices"
+	"strings"
+)
+
+func (s *store) listStreams(body []byte) (any, *apiError) {
+	var req struct{ TableName string }
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	streams := []map[string]string{}
+	for _, t := range s.tables {
+		if req.TableName != "" && req.TableName != t.name {
+			continue
+		}
+		if t.streamSpecification == nil || !t.streamSpecification.StreamEnabled {
+			continue
+		}
+		streams = append(streams, map[string]string{
+			"StreamArn":   t.streamARN(),
+			"TableName":   t.name,
+			"StreamLabel": t.created.UTC().Format("2006-01-02T15:04:05.000"),
+		})
+	}
+	slices.SortFunc(streams, func(a, b map[string]string) int {
+		return strings.Compare(a["TableName"], b["TableName"])
+	})
+	return map[string]any{"Streams": streams}, nil
+}
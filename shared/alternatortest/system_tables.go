@@ -0,0 +1,69 @@
+package alternatortest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+const (
+	systemLocalTable = ".scylla.alternator.system.local"
+	systemPeersTable = ".scylla.alternator.system.peers"
+)
+
+// serveSystemTable serves Scan of "system.local" and "system.peers" tables the way Alternator exposes them,
+//
+//	"local" describes the node itself and "peers" the rest of nodes, down nodes included,
+//	returns false if request is not a Scan of one of them
+func (n *Node) serveSystemTable(w http.ResponseWriter, r *http.Request) bool {
+	if operationName(r) != "Scan" {
+		return false
+	}
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, validationError("failed to read request body: %v", err))
+		return true
+	}
+	// Body is put back decoded for requests that are not served here
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.Header.Del("Content-Encoding")
+	var req struct {
+		TableName string
+	}
+	if err = json.Unmarshal(body, &req); err != nil {
+		return false
+	}
+
+	var rows []map[string]map[string]string
+	switch req.TableName {
+	case systemLocalTable:
+		rows = append(rows, map[string]map[string]string{
+			"key":               {"S": "local"},
+			"broadcast_address": {"S": n.host},
+			"rpc_address":       {"S": n.host},
+			"data_center":       {"S": n.Datacenter},
+			"rack":              {"S": n.Rack},
+		})
+	case systemPeersTable:
+		for _, peer := range n.cluster.nodes {
+			if peer == n {
+				continue
+			}
+			rows = append(rows, map[string]map[string]string{
+				"peer":        {"S": peer.host},
+				"rpc_address": {"S": peer.host},
+				"data_center": {"S": peer.Datacenter},
+				"rack":        {"S": peer.Rack},
+			})
+		}
+	default:
+		return false
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"Items":        rows,
+		"Count":        len(rows),
+		"ScannedCount": len(rows),
+	})
+	return true
+}
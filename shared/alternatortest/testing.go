@@ -0,0 +1,22 @@
+package alternatortest
+
+import (
+	"errors"
+	"testing"
+)
+
+// StartCluster is `NewCluster` for tests, cluster is closed when test ends,
+//
+//	test is skipped when platform can't run nodes on distinct loopback addresses and fails on other errors
+func StartCluster(tb testing.TB, nodes []NodeConfig, opts ...Option) *Cluster {
+	tb.Helper()
+	cluster, err := NewCluster(nodes, opts...)
+	if errors.Is(err, ErrLoopbackAliasesUnavailable) {
+		tb.Skipf("fake cluster of %d nodes can't be started on this platform: %v", len(nodes), err)
+	}
+	if err != nil {
+		tb.Fatalf("failed to start fake cluster: %v", err)
+	}
+	tb.Cleanup(cluster.Close)
+	return cluster
+}
@@ -0,0 +1,189 @@
+package shared
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+// CertificateKind tells whether certificate is presented by the client or by the server
+type CertificateKind string
+
+const (
+	// ClientCertificate a certificate served to Alternator by `CertSource`
+	ClientCertificate CertificateKind = "client"
+	// ServerCertificate a certificate presented by Alternator node during TLS handshake
+	ServerCertificate CertificateKind = "server"
+)
+
+// DefaultCertificateExpiryThresholds thresholds `CertificateExpiryMonitor` warns at unless configured otherwise
+var DefaultCertificateExpiryThresholds = []time.Duration{30 * 24 * time.Hour, 7 * 24 * time.Hour, 24 * time.Hour}
+
+// CertificateExpiry describes leaf certificate seen by http clients
+type CertificateExpiry struct {
+	Kind CertificateKind
+	// Node an address of the node that presented server certificate, empty if it is unknown
+	Node     string
+	Subject  string
+	NotAfter time.Time
+	// LastSeen time of the last TLS handshake the certificate was seen in
+	LastSeen time.Time
+}
+
+// CertificateExpiryEvent is emitted once per threshold when remaining validity of a certificate drops below it
+type CertificateExpiryEvent struct {
+	CertificateExpiry
+	// Threshold that was crossed, 0 when certificate has expired
+	Threshold time.Duration
+	// Remaining validity of the certificate, negative when it has expired
+	Remaining time.Duration
+}
+
+// CertificateExpiryMonitor inspects leaf certificates of TLS handshakes, logs and emits `CertificateExpiryEvent`
+//
+//	when they are about to expire, all methods are safe to call on nil monitor
+type CertificateExpiryMonitor struct {
+	handler    func(CertificateExpiryEvent)
+	thresholds []time.Duration
+	mutex      sync.Mutex
+	certs      map[string]*monitoredCertificate
+}
+
+type monitoredCertificate struct {
+	expiry CertificateExpiry
+	// notified the smallest threshold event was emitted for, -1 when none
+	notified time.Duration
+}
+
+// NewCertificateExpiryMonitor creates new `CertificateExpiryMonitor`, `handler` is optional,
+//
+//	empty thresholds default to `DefaultCertificateExpiryThresholds`
+func NewCertificateExpiryMonitor(
+	handler func(CertificateExpiryEvent),
+	thresholds ...time.Duration,
+) *CertificateExpiryMonitor {
+	if len(thresholds) == 0 {
+		thresholds = DefaultCertificateExpiryThresholds
+	}
+	thresholds = slices.Clone(thresholds)
+	slices.Sort(thresholds)
+	return &CertificateExpiryMonitor{
+		handler:    handler,
+		thresholds: thresholds,
+		certs:      map[string]*monitoredCertificate{},
+	}
+}
+
+// Expiries returns certificates seen so far, ordered by expiration time
+func (m *CertificateExpiryMonitor) Expiries() []CertificateExpiry {
+	if m == nil {
+		return nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	out := make([]CertificateExpiry, 0, len(m.certs))
+	for _, cert := range m.certs {
+		out = append(out, cert.expiry)
+	}
+	slices.SortFunc(out, func(a, b CertificateExpiry) int {
+		return a.NotAfter.Compare(b.NotAfter)
+	})
+	return out
+}
+
+// Observe records the leaf certificate, logs and emits an event if it crossed a threshold since it was seen last time
+func (m *CertificateExpiryMonitor) Observe(kind CertificateKind, node string, leaf *x509.Certificate, log logx.Logger) {
+	if m == nil || leaf == nil {
+		return
+	}
+
+	// Server certificates are tracked per node, unless node is unknown
+	key := string(kind) + "/" + node
+	if kind == ServerCertificate && node == "" {
+		sum := sha256.Sum256(leaf.Raw)
+		key += hex.EncodeToString(sum[:])
+	}
+
+	now := time.Now()
+	m.mutex.Lock()
+	cert := m.certs[key]
+	if cert == nil || !cert.expiry.NotAfter.Equal(leaf.NotAfter) || cert.expiry.Subject != leaf.Subject.String() {
+		cert = &monitoredCertificate{
+			expiry: CertificateExpiry{
+				Kind:     kind,
+				Node:     node,
+				Subject:  leaf.Subject.String(),
+				NotAfter: leaf.NotAfter,
+			},
+			notified: -1,
+		}
+		m.certs[key] = cert
+	}
+	cert.expiry.LastSeen = now
+
+	remaining := leaf.NotAfter.Sub(now)
+	threshold, crossed := m.crossedThreshold(remaining)
+	if !crossed || (cert.notified >= 0 && threshold >= cert.notified) {
+		m.mutex.Unlock()
+		return
+	}
+	cert.notified = threshold
+	event := CertificateExpiryEvent{
+		CertificateExpiry: cert.expiry,
+		Threshold:         threshold,
+		Remaining:         remaining,
+	}
+	m.mutex.Unlock()
+
+	attrs := []logx.Attr{
+		logx.A("kind", kind),
+		logx.A("node", node),
+		logx.A("subject", event.Subject),
+		logx.A("not_after", event.NotAfter),
+	}
+	if threshold == 0 {
+		log.Error("certificate has expired", attrs...)
+	} else {
+		log.Warn("certificate expires soon", append(attrs, logx.A("remaining", remaining.Round(time.Minute)))...)
+	}
+	if m.handler != nil {
+		m.handler(event)
+	}
+}
+
+// crossedThreshold returns the smallest threshold remaining validity is below, 0 if certificate has expired
+func (m *CertificateExpiryMonitor) crossedThreshold(remaining time.Duration) (time.Duration, bool) {
+	if remaining <= 0 {
+		return 0, true
+	}
+	for _, threshold := range m.thresholds {
+		if remaining <= threshold {
+			return threshold, true
+		}
+	}
+	return 0, false
+}
+
+// leafCertificate returns parsed leaf certificate of `tls.Certificate`
+func leafCertificate(cert *tls.Certificate) *x509.Certificate {
+	if cert == nil {
+		return nil
+	}
+	if cert.Leaf != nil {
+		return cert.Leaf
+	}
+	if len(cert.Certificate) == 0 {
+		return nil
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil
+	}
+	return leaf
+}
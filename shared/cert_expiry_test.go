@@ -0,0 +1,161 @@
+package shared_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+type expiryEvents struct {
+	lock   sync.Mutex
+	events []shared.CertificateExpiryEvent
+}
+
+func (e *expiryEvents) handle(event shared.CertificateExpiryEvent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *expiryEvents) thresholds() []time.Duration {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	var out []time.Duration
+	for _, event := range e.events {
+		out = append(out, event.Threshold)
+	}
+	return out
+}
+
+func certExpiringIn(name string, validity time.Duration) *x509.Certificate {
+	return &x509.Certificate{
+		Raw:      []byte(name),
+		Subject:  pkix.Name{CommonName: name},
+		NotAfter: time.Now().Add(validity),
+	}
+}
+
+func TestCertificateExpiryMonitor(t *testing.T) {
+	t.Parallel()
+
+	const day = 24 * time.Hour
+
+	tcases := []struct {
+		name               string
+		validity           []time.Duration
+		expectedThresholds []time.Duration
+	}{
+		{name: "NotExpiring", validity: []time.Duration{90 * day}},
+		{
+			name:               "OncePerThreshold",
+			validity:           []time.Duration{10 * day, 10 * day},
+			expectedThresholds: []time.Duration{30 * day},
+		},
+		{name: "SmallestCrossed", validity: []time.Duration{2 * day}, expectedThresholds: []time.Duration{7 * day}},
+		{
+			name:               "Rotated",
+			validity:           []time.Duration{10 * day, 12 * time.Hour, 25 * day},
+			expectedThresholds: []time.Duration{30 * day, day, 30 * day},
+		},
+		{name: "Expired", validity: []time.Duration{-time.Hour, -time.Hour}, expectedThresholds: []time.Duration{0}},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			events := &expiryEvents{}
+			monitor := shared.NewCertificateExpiryMonitor(events.handle)
+			// Same certificate is seen again when validity repeats
+			certs := map[time.Duration]*x509.Certificate{}
+			for _, validity := range tc.validity {
+				if certs[validity] == nil {
+					certs[validity] = certExpiringIn("client", validity)
+				}
+				monitor.Observe(shared.ClientCertificate, "", certs[validity], logx.Noop{})
+			}
+			if got := events.thresholds(); !slices.Equal(got, tc.expectedThresholds) {
+				t.Fatalf("expected events at %v, got %v", tc.expectedThresholds, got)
+			}
+			if expiries := monitor.Expiries(); len(expiries) != 1 {
+				t.Fatalf("expected one certificate to be tracked, got %v", expiries)
+			}
+		})
+	}
+
+	t.Run("Expiries", func(t *testing.T) {
+		t.Parallel()
+		monitor := shared.NewCertificateExpiryMonitor(nil, time.Hour)
+		monitor.Observe(shared.ServerCertificate, "10.0.0.1", certExpiringIn("node1", 48*time.Hour), logx.Noop{})
+		monitor.Observe(shared.ServerCertificate, "10.0.0.2", certExpiringIn("node2", 24*time.Hour), logx.Noop{})
+		monitor.Observe(shared.ClientCertificate, "", certExpiringIn("client", 72*time.Hour), logx.Noop{})
+
+		var nodes []string
+		for _, expiry := range monitor.Expiries() {
+			nodes = append(nodes, expiry.Node)
+		}
+		if !slices.Equal(nodes, []string{"10.0.0.2", "10.0.0.1", ""}) {
+			t.Fatalf("expected certificates ordered by expiration time, got %v", nodes)
+		}
+
+		var nilMonitor *shared.CertificateExpiryMonitor
+		nilMonitor.Observe(shared.ClientCertificate, "", certExpiringIn("client", time.Hour), logx.Noop{})
+		if expiries := nilMonitor.Expiries(); expiries != nil {
+			t.Fatalf("expected nil monitor to track nothing, got %v", expiries)
+		}
+	})
+}
+
+func TestCertificateExpiryMonitorHandshake(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	pair := newTestKeyPair(t, "client")
+	clientCert, err := tls.X509KeyPair(pair.certPEM, pair.keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load key pair: %v", err)
+	}
+
+	events := &expiryEvents{}
+	monitor := shared.NewCertificateExpiryMonitor(events.handle, 7*24*time.Hour)
+	err = doTLSRequest(
+		srv,
+		shared.WithALNRootCAs(pool),
+		shared.WithALNClientCertificate(clientCert),
+		shared.WithALNCertificateExpiryMonitor(monitor),
+	)
+	if err != nil {
+		t.Fatalf("request unexpectedly failed: %v", err)
+	}
+
+	expiries := monitor.Expiries()
+	if len(expiries) != 2 {
+		t.Fatalf("expected client and server certificates to be tracked, got %v", expiries)
+	}
+	// Client certificate expires in an hour, server certificate generated by httptest lasts for decades
+	if expiries[0].Kind != shared.ClientCertificate || expiries[1].Kind != shared.ServerCertificate {
+		t.Fatalf("unexpected certificates: %v", expiries)
+	}
+	if expiries[1].Node != "127.0.0.1" || !expiries[1].NotAfter.Equal(srv.Certificate().NotAfter) {
+		t.Fatalf("unexpected server certificate: %+v", expiries[1])
+	}
+	if got := events.thresholds(); !slices.Equal(got, []time.Duration{7 * 24 * time.Hour}) {
+		t.Fatalf("expected an event for client certificate, got %v", got)
+	}
+}
@@ -0,0 +1,63 @@
+package shared
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// spkiPinPrefix is an optional prefix of a pin, as in `openssl ... | base64` recipes and HPKP headers
+const spkiPinPrefix = "sha256/"
+
+// VerifyPeerCertificateFunc a custom server certificate verification,
+//
+//	has the same semantics as `tls.Config.VerifyPeerCertificate`, `verifiedChains` is empty when
+//	`IgnoreServerCertificateError` is set
+type VerifyPeerCertificateFunc func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+
+// SPKIPin returns base64 encoded SHA-256 hash of certificate's SubjectPublicKeyInfo,
+//
+//	in a form accepted by `WithCertificatePins`
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// PinMismatchError is returned when none of certificates presented by the node matches pinned SPKI hashes
+type PinMismatchError struct {
+	// Node an address of the node
+	Node string
+	// Expected pinned SPKI hashes
+	Expected []string
+	// Got SPKI hashes of certificates of all chains of the node, leaf first, every hash once
+	Got []string
+}
+
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf(
+		"tls: certificate of node %s does not match any pinned SPKI hash, expected one of [%s], got [%s]",
+		e.Node, strings.Join(e.Expected, ", "), strings.Join(e.Got, ", "),
+	)
+}
+
+// verifyPins checks that at least one certificate of any of the chains matches one of the pins,
+//
+//	so that a pinned CA is accepted whichever of its cross-signed chains is verified first
+func verifyPins(node string, pins []string, chains [][]*x509.Certificate) error {
+	var got []string
+	for _, chain := range chains {
+		for _, cert := range chain {
+			pin := SPKIPin(cert)
+			if slices.ContainsFunc(pins, func(p string) bool { return strings.TrimPrefix(p, spkiPinPrefix) == pin }) {
+				return nil
+			}
+			if !slices.Contains(got, pin) {
+				got = append(got, pin)
+			}
+		}
+	}
+	return &PinMismatchError{Node: node, Expected: pins, Got: got}
+}
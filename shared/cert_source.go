@@ -22,6 +22,7 @@ type CertFileSource struct {
 	cert     *tls.Certificate
 	mutex    sync.Mutex
 	modTime  time.Time
+	keyMod   time.Time
 }
 
 // NewFileCertificate creates new instance of `CertFileSource` to serve certificate and key from a file
@@ -40,6 +41,8 @@ func (c *CertFileSource) GetClientCertificate(
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	// Both files are checked, so that certificate is reloaded when only key is rotated,
+	// tls.LoadX509KeyPair fails if files do not match, so that half-written rotation is retried on next handshake
 	certStat, err := os.Stat(c.certPath)
 	if err != nil {
 		err = fmt.Errorf("failed to stat certificate file %s: %w", c.certPath, err)
@@ -50,7 +53,17 @@ func (c *CertFileSource) GetClientCertificate(
 		return nil, err
 	}
 
-	if c.cert != nil && certStat.ModTime().Equal(c.modTime) {
+	keyStat, err := os.Stat(c.keyPath)
+	if err != nil {
+		err = fmt.Errorf("failed to stat key file %s: %w", c.keyPath, err)
+		if c.cert != nil {
+			log.Error(err.Error())
+			return c.cert, nil
+		}
+		return nil, err
+	}
+
+	if c.cert != nil && certStat.ModTime().Equal(c.modTime) && keyStat.ModTime().Equal(c.keyMod) {
 		return c.cert, nil // Return cached certificate if unchanged
 	}
 
@@ -66,6 +79,7 @@ func (c *CertFileSource) GetClientCertificate(
 
 	c.cert = &cert
 	c.modTime = certStat.ModTime()
+	c.keyMod = keyStat.ModTime()
 	return c.cert, nil
 }
 
@@ -0,0 +1,135 @@
+package shared
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+const (
+	defaultDirCertFile = "tls.crt"
+	defaultDirKeyFile  = "tls.key"
+	// kubernetesDataDir a symlink Kubernetes swaps atomically to the new version of a mounted secret
+	kubernetesDataDir = "..data"
+)
+
+// CertDirSource serves certificate and key from files in a directory, e.g. a mounted Kubernetes secret,
+//
+//	files are read again when they change or `..data` symlink is swapped to the new version of the secret
+type CertDirSource struct {
+	dir      string
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	mutex    sync.Mutex
+	version  dirVersion
+}
+
+// dirVersion identifies content of the directory without reading files
+type dirVersion struct {
+	data    string
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// NewDirCertificate creates new instance of `CertDirSource` to serve certificate and key from files in a directory,
+//
+//	empty `certFile` and `keyFile` default to tls.crt and tls.key, names used by Kubernetes TLS secrets
+func NewDirCertificate(dir, certFile, keyFile string) *CertDirSource {
+	if certFile == "" {
+		certFile = defaultDirCertFile
+	}
+	if keyFile == "" {
+		keyFile = defaultDirKeyFile
+	}
+	return &CertDirSource{
+		dir:      dir,
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+}
+
+// GetClientCertificate implementation of tls.Config.GetClientCertificate that serves certificate from a directory
+func (c *CertDirSource) GetClientCertificate(
+	_ *tls.CertificateRequestInfo,
+	log logx.Logger,
+) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	version, err := c.currentVersion()
+	if err == nil && c.cert != nil && version == c.version {
+		return c.cert, nil // Return cached certificate if unchanged
+	}
+
+	var cert *tls.Certificate
+	if err == nil {
+		cert, err = c.load(version)
+	}
+	if err != nil {
+		if c.cert != nil {
+			log.Error(err.Error())
+			return c.cert, nil
+		}
+		return nil, err
+	}
+
+	c.cert = cert
+	c.version = version
+	return c.cert, nil
+}
+
+func (c *CertDirSource) currentVersion() (dirVersion, error) {
+	var version dirVersion
+	data, err := os.Readlink(filepath.Join(c.dir, kubernetesDataDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return version, fmt.Errorf("failed to read %s symlink in %s: %w", kubernetesDataDir, c.dir, err)
+	}
+	version.data = data
+
+	certStat, err := os.Stat(filepath.Join(c.dir, c.certFile))
+	if err != nil {
+		return version, fmt.Errorf("failed to stat certificate file in %s: %w", c.dir, err)
+	}
+	keyStat, err := os.Stat(filepath.Join(c.dir, c.keyFile))
+	if err != nil {
+		return version, fmt.Errorf("failed to stat key file in %s: %w", c.dir, err)
+	}
+	version.certMod = certStat.ModTime()
+	version.keyMod = keyStat.ModTime()
+	return version, nil
+}
+
+// load reads certificate and key, it fails if directory has changed while files were read,
+// so that certificate of one version of the secret is never paired with key of another
+func (c *CertDirSource) load(version dirVersion) (*tls.Certificate, error) {
+	certPEM, err := os.ReadFile(filepath.Join(c.dir, c.certFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file in %s: %w", c.dir, err)
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(c.dir, c.keyFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file in %s: %w", c.dir, err)
+	}
+
+	after, err := c.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+	if after != version {
+		return nil, fmt.Errorf("certificate in %s has changed while being read, it will be read again", c.dir)
+	}
+
+	// tls.X509KeyPair fails if key does not match certificate
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate from %s: %w", c.dir, err)
+	}
+	return &cert, nil
+}
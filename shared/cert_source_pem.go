@@ -0,0 +1,79 @@
+package shared
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+// PEMCallbackSource serves certificate and key returned by a callback in PEM format,
+//
+//	e.g. fetched from a secret store
+type PEMCallbackSource struct {
+	fn              func(ctx context.Context) (certPEM, keyPEM []byte, err error)
+	refreshInterval time.Duration
+	cert            *tls.Certificate
+	mutex           sync.Mutex
+	loadedAt        time.Time
+}
+
+// NewPEMCertificate creates new instance of `PEMCallbackSource`,
+//
+//	callback is called again when certificate is older than `refreshInterval`, 0 means on every TLS handshake
+func NewPEMCertificate(
+	fn func(ctx context.Context) (certPEM, keyPEM []byte, err error),
+	refreshInterval time.Duration,
+) *PEMCallbackSource {
+	return &PEMCallbackSource{
+		fn:              fn,
+		refreshInterval: refreshInterval,
+	}
+}
+
+// GetClientCertificate implementation of tls.Config.GetClientCertificate that serves certificate
+// returned by the callback
+func (c *PEMCallbackSource) GetClientCertificate(
+	info *tls.CertificateRequestInfo,
+	log logx.Logger,
+) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.cert != nil && c.refreshInterval > 0 && time.Since(c.loadedAt) < c.refreshInterval {
+		return c.cert, nil
+	}
+
+	ctx := context.Background()
+	if info != nil {
+		ctx = info.Context()
+	}
+	cert, err := c.load(ctx)
+	if err != nil {
+		if c.cert != nil {
+			log.Error(err.Error())
+			return c.cert, nil
+		}
+		return nil, err
+	}
+
+	c.cert = cert
+	c.loadedAt = time.Now()
+	return c.cert, nil
+}
+
+func (c *PEMCallbackSource) load(ctx context.Context) (*tls.Certificate, error) {
+	certPEM, keyPEM, err := c.fn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+	// tls.X509KeyPair fails if key does not match certificate
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	return &cert, nil
+}
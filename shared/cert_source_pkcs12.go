@@ -0,0 +1,109 @@
+package shared
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+// PKCS12FileSource serves certificate and key from a password protected PKCS#12 (.p12, .pfx) file,
+//
+//	the file is read again when it changes
+type PKCS12FileSource struct {
+	path     string
+	password string
+	cert     *tls.Certificate
+	mutex    sync.Mutex
+	modTime  time.Time
+}
+
+// NewPKCS12FileCertificate creates new instance of `PKCS12FileSource` to serve certificate and key
+// from a PKCS#12 file
+func NewPKCS12FileCertificate(path, password string) *PKCS12FileSource {
+	return &PKCS12FileSource{
+		path:     path,
+		password: password,
+	}
+}
+
+// GetClientCertificate implementation of tls.Config.GetClientCertificate that serves certificate from a PKCS#12 file
+func (c *PKCS12FileSource) GetClientCertificate(
+	_ *tls.CertificateRequestInfo,
+	log logx.Logger,
+) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stat, err := os.Stat(c.path)
+	if err != nil {
+		err = fmt.Errorf("failed to stat PKCS#12 file %s: %w", c.path, err)
+		if c.cert != nil {
+			log.Error(err.Error())
+			return c.cert, nil
+		}
+		return nil, err
+	}
+
+	if c.cert != nil && stat.ModTime().Equal(c.modTime) {
+		return c.cert, nil // Return cached certificate if unchanged
+	}
+
+	cert, err := loadPKCS12File(c.path, c.password)
+	if err != nil {
+		if c.cert != nil {
+			log.Error(err.Error())
+			return c.cert, nil
+		}
+		return nil, err
+	}
+
+	c.cert = cert
+	c.modTime = stat.ModTime()
+	return c.cert, nil
+}
+
+func loadPKCS12File(path, password string) (*tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PKCS#12 file %s: %w", path, err)
+	}
+	key, leaf, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PKCS#12 file %s: %w", path, err)
+	}
+	if err = verifyKeyPair(leaf, key); err != nil {
+		return nil, fmt.Errorf("failed to load PKCS#12 file %s: %w", path, err)
+	}
+
+	cert := &tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range caCerts {
+		cert.Certificate = append(cert.Certificate, ca.Raw)
+	}
+	return cert, nil
+}
+
+// verifyKeyPair checks that private key matches public key of the certificate
+func verifyKeyPair(leaf *x509.Certificate, key crypto.PrivateKey) error {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("unsupported private key type %T", key)
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(leaf.PublicKey) {
+		return errors.New("private key does not match public key of the certificate")
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package shared_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+type testKeyPair struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestKeyPair(t *testing.T, name string) testKeyPair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return testKeyPair{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func expectClientCertificate(t *testing.T, source shared.CertSource, expected string) {
+	t.Helper()
+	cert, err := source.GetClientCertificate(nil, logx.Noop{})
+	if err != nil {
+		t.Fatalf("failed to get certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	if leaf.Subject.CommonName != expected {
+		t.Fatalf("expected certificate %s, got %s", expected, leaf.Subject.CommonName)
+	}
+}
+
+func touch(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("failed to update modification time of %s: %v", path, err)
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestPEMCallbackSource(t *testing.T) {
+	t.Parallel()
+
+	first := newTestKeyPair(t, "first")
+	second := newTestKeyPair(t, "second")
+
+	var certPEM, keyPEM []byte
+	var callbackErr error
+	source := shared.NewPEMCertificate(func(context.Context) ([]byte, []byte, error) {
+		return certPEM, keyPEM, callbackErr
+	}, 0)
+
+	callbackErr = errors.New("secret store is unavailable")
+	if _, err := source.GetClientCertificate(nil, logx.Noop{}); err == nil {
+		t.Fatalf("expected error when there is no certificate yet")
+	}
+
+	callbackErr = nil
+	certPEM, keyPEM = first.certPEM, first.keyPEM
+	expectClientCertificate(t, source, "first")
+
+	// Half-written rotation: certificate is rotated, key is not yet
+	certPEM = second.certPEM
+	expectClientCertificate(t, source, "first")
+
+	keyPEM = second.keyPEM
+	expectClientCertificate(t, source, "second")
+
+	callbackErr = errors.New("secret store is unavailable")
+	expectClientCertificate(t, source, "second")
+}
+
+func TestPKCS12FileSource(t *testing.T) {
+	t.Parallel()
+
+	first := newTestKeyPair(t, "first")
+	second := newTestKeyPair(t, "second")
+	encode := func(pair testKeyPair, password string) []byte {
+		data, err := pkcs12.Modern.Encode(pair.key, pair.cert, nil, password)
+		if err != nil {
+			t.Fatalf("failed to encode PKCS#12: %v", err)
+		}
+		return data
+	}
+
+	path := filepath.Join(t.TempDir(), "client.p12")
+	writeFile(t, path, encode(first, "secret"))
+
+	if _, err := shared.NewPKCS12FileCertificate(path, "wrong").GetClientCertificate(nil, logx.Noop{}); err == nil {
+		t.Fatalf("expected error for wrong password")
+	}
+
+	source := shared.NewPKCS12FileCertificate(path, "secret")
+	expectClientCertificate(t, source, "first")
+
+	modTime := time.Now().Add(time.Minute)
+	writeFile(t, path, []byte("half-written"))
+	touch(t, path, modTime)
+	expectClientCertificate(t, source, "first")
+
+	writeFile(t, path, encode(second, "secret"))
+	touch(t, path, modTime.Add(time.Minute))
+	expectClientCertificate(t, source, "second")
+}
+
+func TestCertDirSource(t *testing.T) {
+	t.Parallel()
+
+	first := newTestKeyPair(t, "first")
+	second := newTestKeyPair(t, "second")
+	dir := t.TempDir()
+
+	// Mimic Kubernetes secret volume layout: files are symlinks to ..data/<file>,
+	// ..data is a symlink to a timestamped directory, which is swapped atomically on update
+	writeVersion := func(name string, pair testKeyPair) {
+		versionDir := filepath.Join(dir, name)
+		if err := os.Mkdir(versionDir, 0o700); err != nil {
+			t.Fatalf("failed to create %s: %v", versionDir, err)
+		}
+		writeFile(t, filepath.Join(versionDir, "tls.crt"), pair.certPEM)
+		writeFile(t, filepath.Join(versionDir, "tls.key"), pair.keyPEM)
+		// Files of the new version keep the same modification time
+		touch(t, filepath.Join(versionDir, "tls.crt"), time.Unix(0, 0))
+		touch(t, filepath.Join(versionDir, "tls.key"), time.Unix(0, 0))
+
+		tmp := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink(name, tmp); err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+			t.Fatalf("failed to swap ..data symlink: %v", err)
+		}
+	}
+
+	writeVersion("..2025_01_01", first)
+	for _, file := range []string{"tls.crt", "tls.key"} {
+		if err := os.Symlink(filepath.Join("..data", file), filepath.Join(dir, file)); err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+	}
+
+	source := shared.NewDirCertificate(dir, "", "")
+	expectClientCertificate(t, source, "first")
+
+	writeVersion("..2025_01_02", second)
+	expectClientCertificate(t, source, "second")
+
+	// Mismatched pair is never served
+	mismatched := t.TempDir()
+	writeFile(t, filepath.Join(mismatched, "cert.pem"), first.certPEM)
+	writeFile(t, filepath.Join(mismatched, "key.pem"), second.keyPEM)
+	_, err := shared.NewDirCertificate(mismatched, "cert.pem", "key.pem").GetClientCertificate(nil, logx.Noop{})
+	if err == nil {
+		t.Fatalf("expected error for mismatched certificate and key")
+	}
+}
+
+func TestCertFileSource(t *testing.T) {
+	t.Parallel()
+
+	first := newTestKeyPair(t, "first")
+	second := newTestKeyPair(t, "second")
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeFile(t, certPath, first.certPEM)
+	writeFile(t, keyPath, first.keyPEM)
+
+	certModTime := time.Now().Add(-time.Hour)
+	touch(t, certPath, certModTime)
+
+	source := shared.NewFileCertificate(certPath, keyPath)
+	expectClientCertificate(t, source, "first")
+
+	// Certificate is rotated before key, mismatched pair is not served
+	writeFile(t, certPath, second.certPEM)
+	touch(t, certPath, certModTime.Add(time.Minute))
+	expectClientCertificate(t, source, "first")
+
+	// Certificate modification time is preserved by the rotation tool, key change has to trigger reload anyway
+	touch(t, certPath, certModTime)
+	expectClientCertificate(t, source, "first")
+	writeFile(t, keyPath, second.keyPEM)
+	touch(t, keyPath, time.Now().Add(time.Minute))
+	expectClientCertificate(t, source, "second")
+}
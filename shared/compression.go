@@ -0,0 +1,104 @@
+package shared
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const defaultRequestCompressionMinSize = 1024
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// Compression takes original `http.RoundTripper`, requests gzip encoded responses and decompresses them,
+//
+//	request bodies are compressed by helper clients before requests are signed, see `GzipBody`
+type Compression struct {
+	original http.RoundTripper
+}
+
+// NewCompressionTransport wraps provided `http.RoundTripper` and returns new instance of `Compression`
+func NewCompressionTransport(original http.RoundTripper) *Compression {
+	return &Compression{
+		original: original,
+	}
+}
+
+// RoundTrip an implementation of `http.RoundTripper.RoundTrip`
+//
+//	requests gzip encoded response unless Accept-Encoding is set already and decompresses gzip encoded body
+func (c Compression) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Header.Get("Accept-Encoding") == "" {
+		r = r.Clone(r.Context())
+		r.Header.Set("Accept-Encoding", "gzip")
+	}
+	resp, err := c.original.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		if err = decompressResponse(resp); err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// GzipBody gzip compresses request body, bodies shorter than `minSize` are not compressed and false is returned,
+//
+//	it has to be called before request is signed, so that signature covers compressed body and Content-Encoding
+func GzipBody(body []byte, minSize int) ([]byte, bool, error) {
+	if len(body) == 0 || len(body) < minSize {
+		return body, false, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzipWriterPool.Get().(*gzip.Writer)
+	defer gzipWriterPool.Put(zw)
+	zw.Reset(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, false, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+func decompressResponse(resp *http.Response) error {
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Body = &gzipReadCloser{Reader: zr, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (g *gzipReadCloser) Close() error {
+	_ = g.Reader.Close()
+	return g.body.Close()
+}
+
+// Unwrap returns original `http.RoundTripper`
+func (c Compression) Unwrap() http.RoundTripper {
+	return c.original
+}
+
+var _ http.RoundTripper = Compression{}
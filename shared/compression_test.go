@@ -0,0 +1,154 @@
+package shared_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+func TestCompressionTransport(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Request-Encoding", r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			_, _ = zw.Write(data)
+			_ = zw.Close()
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &http.Client{
+		Transport: shared.NewCompressionTransport(http.DefaultTransport.(*http.Transport).Clone()),
+	}
+
+	tcases := []struct {
+		name             string
+		body             string
+		expectedEncoding string
+	}{
+		{name: "BelowThreshold", body: "short"},
+		{name: "AboveThreshold", body: strings.Repeat("long", 100), expectedEncoding: "gzip"},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			body, compressed, err := shared.GzipBody([]byte(tc.body), 64)
+			if err != nil {
+				t.Fatalf("failed to compress body: %v", err)
+			}
+			req, err := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			if compressed {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request unexpectedly failed: %v", err)
+			}
+			defer resp.Body.Close() //nolint: errcheck // no need to check
+
+			if got := req.Header.Get("Accept-Encoding"); got != "" {
+				t.Errorf("expected Accept-Encoding of original request to stay empty, got %q", got)
+			}
+			if got := resp.Header.Get("X-Request-Encoding"); got != tc.expectedEncoding {
+				t.Errorf("expected request encoding %q, got %q", tc.expectedEncoding, got)
+			}
+			if !resp.Uncompressed {
+				t.Errorf("response should have been decompressed")
+			}
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if string(data) != tc.body {
+				t.Errorf("expected response body %q, got %q", tc.body, string(data))
+			}
+		})
+	}
+}
+
+func TestCompressionTransportKeepsAcceptEncoding(t *testing.T) {
+	t.Parallel()
+
+	const body = "plain response"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &http.Client{
+		Transport: shared.NewCompressionTransport(http.DefaultTransport.(*http.Transport).Clone()),
+	}
+
+	tcases := []struct {
+		name           string
+		acceptEncoding string
+		expected       string
+	}{
+		{name: "Empty", expected: "gzip"},
+		{name: "Identity", acceptEncoding: "identity", expected: "identity"},
+		{name: "Several", acceptEncoding: "gzip, deflate", expected: "gzip, deflate"},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request unexpectedly failed: %v", err)
+			}
+			defer resp.Body.Close() //nolint: errcheck // no need to check
+
+			if got := resp.Header.Get("X-Accept-Encoding"); got != tc.expected {
+				t.Errorf("expected Accept-Encoding %q, got %q", tc.expected, got)
+			}
+			// Response that is not gzip encoded is returned as is
+			if resp.Uncompressed {
+				t.Errorf("response should not have been decompressed")
+			}
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if string(data) != body {
+				t.Errorf("expected response body %q, got %q", body, string(data))
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@
 package shared
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -30,14 +32,35 @@ type Config struct {
 	AccessKeyID string
 	// SecretAccessKey from AWS credentials
 	SecretAccessKey string
+	// CredentialsProvider provides credentials, takes precedence over AccessKeyID and SecretAccessKey
+	CredentialsProvider CredentialsProvider
 	// NodesListUpdatePeriod how often read list of nodes, while requests are running
 	NodesListUpdatePeriod time.Duration
 	// ClientCertificateSource a certificate store to supplies client certificate to the http client
 	ClientCertificateSource CertSource
 	// Makes it ignore server certificate errors
 	IgnoreServerCertificateError bool
+	// RootCASource supplies root CAs to verify server certificates against, nil means system roots
+	RootCASource RootCASource
+	// TLSServerName a name to verify server certificates against instead of node address
+	TLSServerName string
+	// SANVerifier a strategy to check that server certificate belongs to the node
+	SANVerifier SANVerifier
+	// CertificatePins base64 encoded SHA-256 hashes of SubjectPublicKeyInfo, one of server certificates has to match
+	CertificatePins []string
+	// VerifyPeerCertificate a custom server certificate verification
+	VerifyPeerCertificate VerifyPeerCertificateFunc
+	// CertificateExpiryMonitor inspects expiration time of client and server certificates, nil disables it
+	CertificateExpiryMonitor *CertificateExpiryMonitor
 	// OptimizeHeaders - when true removes unnecessary http headers reducing network footprint
 	OptimizeHeaders func(Config) []string
+	// RequestCompression - when true gzip compresses DynamoDB request bodies
+	RequestCompression bool
+	// RequestCompressionMinSize - request bodies shorter than this are sent uncompressed
+	RequestCompressionMinSize int
+	// ResponseDecompression - when true DynamoDB client requests gzip encoded responses and decompresses them,
+	// when false response encoding is left up to AWS SDK and http.Transport
+	ResponseDecompression bool
 	// Update node list when no requests are running
 	IdleNodesListUpdatePeriod time.Duration
 	Logger                    logx.Logger
@@ -45,12 +68,36 @@ type Config struct {
 	KeyLogWriter io.Writer
 	// TLS session cache
 	TLSSessionCache tls.ClientSessionCache
+	// TLSPolicy a preset restricting TLS versions, cipher suites and curves
+	TLSPolicy TLSPolicy
+	// TLSPolicyOverrides non-zero fields take precedence over `TLSPolicy`
+	TLSPolicyOverrides TLSPolicy
 	// Maximum number of idle HTTP connections
 	MaxIdleHTTPConnections int
 	// Time to keep idle http connection alive
 	IdleHTTPConnectionTimeout time.Duration
+	// Maximum number of idle HTTP connections per node, 0 means http.DefaultMaxIdleConnsPerHost
+	MaxIdleHTTPConnectionsPerHost int
+	// Maximum number of HTTP connections per node, including active and idle ones, 0 means no limit
+	MaxHTTPConnectionsPerHost int
+	// Maximum amount of time a dial will wait for a connect to complete
+	DialTimeout time.Duration
+	// Interval between keep-alive probes for an active network connection
+	DialKeepAlive time.Duration
+	// Maximum amount of time to wait for a TLS handshake
+	TLSHandshakeTimeout time.Duration
+	// Amount of time to wait for a server's response headers after fully writing the request
+	ResponseHeaderTimeout time.Duration
+	// Controls whether http clients use HTTP/2
+	HTTP2Mode HTTP2Mode
 	// A custom http transport
 	HTTPTransport http.RoundTripper
+	// Number of keep-alive connections to open to every newly discovered node, 0 disables warm-up
+	WarmUpConnections int
+	// DrainRequestsOnClose - when true helper's Close waits for requests in flight to finish
+	DrainRequestsOnClose bool
+	// TableRoutes routing scope and balancing policy per table
+	TableRoutes TableRoutes
 }
 
 // Option a configuration option
@@ -76,6 +123,8 @@ func NewDefaultConfig() *Config {
 		TLSSessionCache:           defaultTLSSessionCache,
 		MaxIdleHTTPConnections:    100,
 		IdleHTTPConnectionTimeout: defaultIdleConnectionTimeout,
+		RequestCompressionMinSize: defaultRequestCompressionMinSize,
+		CertificateExpiryMonitor:  NewCertificateExpiryMonitor(nil),
 		Logger:                    logxzap.DefaultLogger(),
 	}
 }
@@ -98,8 +147,16 @@ func (c *Config) ToALNOptions() []ALNOption {
 		WithALNIgnoreServerCertificateError(c.IgnoreServerCertificateError),
 		WithALNMaxIdleHTTPConnections(c.MaxIdleHTTPConnections),
 		WithALNIdleHTTPConnectionTimeout(c.IdleHTTPConnectionTimeout),
+		WithALNMaxIdleHTTPConnectionsPerHost(c.MaxIdleHTTPConnectionsPerHost),
+		WithALNMaxHTTPConnectionsPerHost(c.MaxHTTPConnectionsPerHost),
+		WithALNDialTimeout(c.DialTimeout),
+		WithALNDialKeepAlive(c.DialKeepAlive),
+		WithALNTLSHandshakeTimeout(c.TLSHandshakeTimeout),
+		WithALNResponseHeaderTimeout(c.ResponseHeaderTimeout),
+		WithALNHTTP2(c.HTTP2Mode),
 		WithALNRoutingScope(c.RoutingScope),
 		WithALNLogger(c.Logger),
+		WithALNCertificateExpiryMonitor(c.CertificateExpiryMonitor),
 	}
 
 	if c.IdleNodesListUpdatePeriod != 0 {
@@ -110,6 +167,30 @@ func (c *Config) ToALNOptions() []ALNOption {
 		out = append(out, WithALNClientCertificateSource(c.ClientCertificateSource))
 	}
 
+	if c.RootCASource != nil {
+		out = append(out, WithALNRootCASource(c.RootCASource))
+	}
+
+	if c.TLSServerName != "" {
+		out = append(out, WithALNTLSServerName(c.TLSServerName))
+	}
+
+	if c.SANVerifier != nil {
+		out = append(out, WithALNSANVerifier(c.SANVerifier))
+	}
+
+	if len(c.CertificatePins) != 0 {
+		out = append(out, WithALNCertificatePins(c.CertificatePins...))
+	}
+
+	if c.VerifyPeerCertificate != nil {
+		out = append(out, WithALNVerifyPeerCertificate(c.VerifyPeerCertificate))
+	}
+
+	if policy := c.GetTLSPolicy(); !policy.IsZero() {
+		out = append(out, WithALNTLSPolicy(policy))
+	}
+
 	if c.KeyLogWriter != nil {
 		out = append(out, WithALNKeyLogWriter(c.KeyLogWriter))
 	}
@@ -121,6 +202,14 @@ func (c *Config) ToALNOptions() []ALNOption {
 	if c.HTTPTransport != nil {
 		out = append(out, WithALNHTTPTransport(c.HTTPTransport))
 	}
+
+	if provider := c.GetCredentialsProvider(); provider != nil {
+		out = append(out, WithALNCredentialsProvider(provider))
+	}
+
+	if c.AWSRegion != "" {
+		out = append(out, WithALNAWSRegion(c.AWSRegion))
+	}
 	return out
 }
 
@@ -196,6 +285,32 @@ func WithCredentials(accessKeyID, secretAccessKey string) Option {
 	}
 }
 
+// WithCredentialsProvider provides DynamoDB client with credentials retrieved from the provider,
+// they are cached until they expire, so that rotated credentials take effect without restart
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(config *Config) {
+		config.CredentialsProvider = provider
+	}
+}
+
+// GetCredentialsProvider returns provider set by `WithCredentialsProvider`, or provider of the static
+// credentials set by `WithCredentials`, or nil if there are no credentials
+func (c *Config) GetCredentialsProvider() CredentialsProvider {
+	if c.CredentialsProvider != nil {
+		return c.CredentialsProvider
+	}
+	if c.AccessKeyID != "" && c.SecretAccessKey != "" {
+		return NewStaticCredentialsProvider(c.AccessKeyID, c.SecretAccessKey)
+	}
+	return nil
+}
+
+// GetTLSPolicy returns policy set by `WithTLSPolicy` with overrides set by `WithTLSMinVersion`,
+// `WithTLSMaxVersion`, `WithTLSCipherSuites` and `WithTLSCurvePreferences` applied
+func (c *Config) GetTLSPolicy() TLSPolicy {
+	return c.TLSPolicy.Override(c.TLSPolicyOverrides)
+}
+
 // WithClientCertificateFile provides client certificates http clients for both DynamoDB and Alternator requests
 // from files
 func WithClientCertificateFile(certFile, keyFile string) Option {
@@ -212,6 +327,33 @@ func WithClientCertificate(certificate tls.Certificate) Option {
 	}
 }
 
+// WithClientCertificatePEM provides client certificates http clients for both DynamoDB and Alternator requests
+// in PEM format from a callback, it is called again when certificate is older than `refreshInterval`
+func WithClientCertificatePEM(
+	fn func(ctx context.Context) (certPEM, keyPEM []byte, err error),
+	refreshInterval time.Duration,
+) Option {
+	return func(config *Config) {
+		config.ClientCertificateSource = NewPEMCertificate(fn, refreshInterval)
+	}
+}
+
+// WithClientCertificatePKCS12File provides client certificates http clients for both DynamoDB and Alternator
+// requests from a password protected PKCS#12 file
+func WithClientCertificatePKCS12File(path, password string) Option {
+	return func(config *Config) {
+		config.ClientCertificateSource = NewPKCS12FileCertificate(path, password)
+	}
+}
+
+// WithClientCertificateDir provides client certificates http clients for both DynamoDB and Alternator requests
+// from tls.crt and tls.key files in a directory, e.g. a mounted Kubernetes secret
+func WithClientCertificateDir(dir string) Option {
+	return func(config *Config) {
+		config.ClientCertificateSource = NewDirCertificate(dir, "", "")
+	}
+}
+
 // WithClientCertificateSource provides client certificates http clients for both DynamoDB and Alternator requests
 // in a form of custom implementation of `CertSource` interface
 func WithClientCertificateSource(source CertSource) Option {
@@ -227,14 +369,121 @@ func WithIgnoreServerCertificateError(value bool) Option {
 	}
 }
 
+// WithRootCAFile makes http clients verify server certificates against root CAs from a PEM file,
+// file is read again when it changes
+func WithRootCAFile(path string) Option {
+	return func(config *Config) {
+		config.RootCASource = NewRootCAFile(path)
+	}
+}
+
+// WithRootCAs makes http clients verify server certificates against provided root CAs
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(config *Config) {
+		config.RootCASource = NewRootCAs(pool)
+	}
+}
+
+// WithRootCASource makes http clients verify server certificates against root CAs
+// from a custom implementation of `RootCASource` interface
+func WithRootCASource(source RootCASource) Option {
+	return func(config *Config) {
+		config.RootCASource = source
+	}
+}
+
+// WithTLSServerName makes http clients verify server certificates against provided name instead of node address,
+// it is also sent as SNI
+func WithTLSServerName(name string) Option {
+	return func(config *Config) {
+		config.TLSServerName = name
+	}
+}
+
+// WithSANVerifier overrides a strategy to check that server certificate belongs to the node,
+// see `VerifyServerName`, `VerifyNodeAddress`, `VerifyChainOnly` and `VerifyAnyName`
+func WithSANVerifier(verifier SANVerifier) Option {
+	return func(config *Config) {
+		config.SANVerifier = verifier
+	}
+}
+
+// WithCertificatePins pins server certificates by base64 encoded SHA-256 hashes of their SubjectPublicKeyInfo,
+// connection is accepted when any certificate of any verified chain matches any of the pins,
+// provide several pins to rotate certificates without downtime, see `SPKIPin`
+func WithCertificatePins(pins ...string) Option {
+	return func(config *Config) {
+		config.CertificatePins = pins
+	}
+}
+
+// WithVerifyPeerCertificate adds custom server certificate verification, it runs after chain, SAN and pin checks,
+// unlike `WithIgnoreServerCertificateError` it does not replace them
+func WithVerifyPeerCertificate(verify VerifyPeerCertificateFunc) Option {
+	return func(config *Config) {
+		config.VerifyPeerCertificate = verify
+	}
+}
+
+// WithTLSPolicy restricts TLS versions, cipher suites and curves for both http clients to a preset,
+// see `TLSPolicyModern`, `TLSPolicyCompatible` and `TLSPolicyFIPS`
+func WithTLSPolicy(policy TLSPolicy) Option {
+	return func(config *Config) {
+		config.TLSPolicy = policy
+	}
+}
+
+// WithTLSMinVersion overrides minimal TLS version of `TLSPolicy`, e.g. `tls.VersionTLS13`
+func WithTLSMinVersion(version uint16) Option {
+	return func(config *Config) {
+		config.TLSPolicyOverrides.MinVersion = version
+	}
+}
+
+// WithTLSMaxVersion overrides maximal TLS version of `TLSPolicy`
+func WithTLSMaxVersion(version uint16) Option {
+	return func(config *Config) {
+		config.TLSPolicyOverrides.MaxVersion = version
+	}
+}
+
+// WithTLSCipherSuites overrides TLS 1.2 cipher suites of `TLSPolicy`
+func WithTLSCipherSuites(suites ...uint16) Option {
+	return func(config *Config) {
+		config.TLSPolicyOverrides.CipherSuites = suites
+	}
+}
+
+// WithTLSCurvePreferences overrides key exchange curves of `TLSPolicy`
+func WithTLSCurvePreferences(curves ...tls.CurveID) Option {
+	return func(config *Config) {
+		config.TLSPolicyOverrides.CurvePreferences = curves
+	}
+}
+
+// WithCertificateExpiryMonitor overrides monitor of client and server certificates expiration time,
+// use it to change thresholds or to receive `CertificateExpiryEvent`, nil disables monitoring
+func WithCertificateExpiryMonitor(monitor *CertificateExpiryMonitor) Option {
+	return func(config *Config) {
+		config.CertificateExpiryMonitor = monitor
+	}
+}
+
+// WithDrainRequestsOnClose makes helper's Close wait for requests in flight to finish before closing connections
+func WithDrainRequestsOnClose(enabled bool) Option {
+	return func(config *Config) {
+		config.DrainRequestsOnClose = enabled
+	}
+}
+
 // WithOptimizeHeaders makes DynamoDB client remove headers not used by Alternator reducing outgoing traffic
 func WithOptimizeHeaders(enabled bool) Option {
 	var OptimizeHeaders func(config Config) []string
 	if enabled {
 		OptimizeHeaders = func(config Config) []string {
 			allowedHeaders := []string{"Host", "X-Amz-Target", "Content-Length", "Accept-Encoding"}
-			if config.AccessKeyID != "" {
-				allowedHeaders = append(allowedHeaders, "Authorization", "X-Amz-Date")
+			if config.GetCredentialsProvider() != nil {
+				allowedHeaders = append(allowedHeaders, "Authorization", "X-Amz-Date", "X-Amz-Security-Token")
 			}
 			return allowedHeaders
 		}
@@ -244,6 +493,15 @@ func WithOptimizeHeaders(enabled bool) Option {
 	}
 }
 
+// AllowedHeaders returns headers `OptimizeHeaders` keeps, Content-Encoding is kept when requests are compressed
+func (c *Config) AllowedHeaders() []string {
+	allowedHeaders := c.OptimizeHeaders(*c)
+	if c.RequestCompression {
+		allowedHeaders = append(allowedHeaders, "Content-Encoding")
+	}
+	return allowedHeaders
+}
+
 // WithCustomOptimizeHeaders makes DynamoDB client remove headers not used by Alternator reducing outgoing traffic
 func WithCustomOptimizeHeaders(fn func(config Config) []string) Option {
 	return func(config *Config) {
@@ -251,6 +509,31 @@ func WithCustomOptimizeHeaders(fn func(config Config) []string) Option {
 	}
 }
 
+// WithRequestCompression makes DynamoDB client gzip compress request bodies
+// that are not shorter than `RequestCompressionMinSize`.
+// Bodies are compressed by clients of the helper before requests are signed, `PatchHTTPClient` does not compress them.
+// Make sure your Alternator version accepts gzip encoded requests before enabling it
+func WithRequestCompression(enabled bool) Option {
+	return func(config *Config) {
+		config.RequestCompression = enabled
+	}
+}
+
+// WithRequestCompressionMinSize sets minimal size of request body to be compressed
+func WithRequestCompressionMinSize(size int) Option {
+	return func(config *Config) {
+		config.RequestCompressionMinSize = size
+	}
+}
+
+// WithResponseDecompression makes DynamoDB client request gzip encoded responses and decompress them.
+// When disabled, response encoding is left up to AWS SDK and http.Transport
+func WithResponseDecompression(enabled bool) Option {
+	return func(config *Config) {
+		config.ResponseDecompression = enabled
+	}
+}
+
 // WithIdleNodesListUpdatePeriod configures how often update list of nodes, while no requests are running
 func WithIdleNodesListUpdatePeriod(period time.Duration) Option {
 	return func(config *Config) {
@@ -298,7 +581,71 @@ func WithIdleHTTPConnectionTimeout(value time.Duration) Option {
 	}
 }
 
-// WithHTTPTransport sets custom transport for http client
+// WithMaxIdleHTTPConnectionsPerHost controls maximum number of idle http connections held by http.Transport
+// to every node. By default http.Transport keeps only 2 idle connections per node, which defeats connection
+// reuse under load
+func WithMaxIdleHTTPConnectionsPerHost(value int) Option {
+	return func(config *Config) {
+		config.MaxIdleHTTPConnectionsPerHost = value
+	}
+}
+
+// WithMaxHTTPConnectionsPerHost limits total number of http connections, including active and idle ones,
+// to every node, 0 means no limit
+func WithMaxHTTPConnectionsPerHost(value int) Option {
+	return func(config *Config) {
+		config.MaxHTTPConnectionsPerHost = value
+	}
+}
+
+// WithDialTimeout controls how long http clients wait for a connect to a node to complete
+func WithDialTimeout(value time.Duration) Option {
+	return func(config *Config) {
+		config.DialTimeout = value
+	}
+}
+
+// WithDialKeepAlive controls interval between TCP keep-alive probes, negative value disables keep-alive probes
+func WithDialKeepAlive(value time.Duration) Option {
+	return func(config *Config) {
+		config.DialKeepAlive = value
+	}
+}
+
+// WithTLSHandshakeTimeout controls how long http clients wait for a TLS handshake to complete
+func WithTLSHandshakeTimeout(value time.Duration) Option {
+	return func(config *Config) {
+		config.TLSHandshakeTimeout = value
+	}
+}
+
+// WithResponseHeaderTimeout controls how long http clients wait for response headers
+// after request is fully written
+func WithResponseHeaderTimeout(value time.Duration) Option {
+	return func(config *Config) {
+		config.ResponseHeaderTimeout = value
+	}
+}
+
+// WithHTTP2 controls whether both (DynamoDB and Alternator) http clients use HTTP/2:
+//   - HTTP2Off - HTTP/1.1 only
+//   - HTTP2Attempt - HTTP/2 over TLS when node supports it, HTTP/1.1 otherwise
+//   - HTTP2Required - HTTP/2 only, including h2c for plain HTTP
+func WithHTTP2(mode HTTP2Mode) Option {
+	switch mode {
+	case HTTP2Default, HTTP2Off, HTTP2Attempt, HTTP2Required:
+		return func(config *Config) {
+			config.HTTP2Mode = mode
+		}
+	default:
+		panic(fmt.Sprintf("invalid http2 mode: %s", mode))
+	}
+}
+
+// WithHTTPTransport sets custom transport for both (DynamoDB and Alternator) http clients,
+// `http.Transport` is cloned before it is patched according to `Config`, if it is a wrapper exposing
+// `Unwrap() http.RoundTripper`, underlying `http.Transport` is patched in place, only once until helpers
+// sharing it are closed, settings of other helpers are not applied to it and a warning is logged.
 // For testing purposes only, don't use it on production
 func WithHTTPTransport(transport http.RoundTripper) Option {
 	return func(config *Config) {
@@ -306,8 +653,30 @@ func WithHTTPTransport(transport http.RoundTripper) Option {
 	}
 }
 
-// PatchHTTPClient takes `http.Client` instance and patches it according to `Config`
+// WithWarmUpConnections makes DynamoDB client open given number of keep-alive connections to every node
+// on startup and whenever new node is discovered, so that first requests to the node do not pay for TCP and TLS
+// handshakes. Total number of warmed up connections is capped by `MaxIdleHTTPConnections`
+func WithWarmUpConnections(connections int) Option {
+	return func(config *Config) {
+		config.WarmUpConnections = connections
+	}
+}
+
+// PatchHTTPClient takes `http.Client` instance and patches it according to `Config`,
+// headers not used by Alternator are removed by the transport, after request is signed,
+// so `OptimizeHeaders` has to keep every header the signer adds
 func PatchHTTPClient(config Config, client interface{}) error {
+	return patchHTTPClient(config, client, PatchBasicHTTPTransport, true)
+}
+
+// patchHTTPClient patches `http.Client` according to `Config`, underlying `http.Transport` is patched by `patch`,
+// headers are removed by the transport only if `stripHeaders` is set, helpers remove them before request is signed
+func patchHTTPClient(
+	config Config,
+	client interface{},
+	patch func(ALNConfig, *http.Transport),
+	stripHeaders bool,
+) error {
 	httpClient, ok := client.(*http.Client)
 	if !ok {
 		return errors.New("config is not a http client")
@@ -318,7 +687,7 @@ func PatchHTTPClient(config Config, client interface{}) error {
 		httpClient.Transport = DefaultHTTPTransport()
 	}
 
-	httpTransport, ok := httpClient.Transport.(*http.Transport)
+	httpTransport, ok := UnwrapHTTPTransport(httpClient.Transport)
 	if !ok {
 		alnConfig.Logger.Error(
 			"configuration requires a http transport to be patched, but it is impossible since it is not an instance of http.Transport",
@@ -326,10 +695,17 @@ func PatchHTTPClient(config Config, client interface{}) error {
 		return nil
 	}
 
-	PatchBasicHTTPTransport(alnConfig, httpTransport)
+	patch(alnConfig, httpTransport)
+
+	if config.ResponseDecompression {
+		httpClient.Transport = NewCompressionTransport(httpClient.Transport)
+	}
 
-	if config.OptimizeHeaders != nil {
-		httpClient.Transport = NewHeaderWhiteListingTransport(httpTransport, config.OptimizeHeaders(config)...)
+	if config.OptimizeHeaders != nil && stripHeaders {
+		httpClient.Transport = NewHeaderWhiteListingTransport(
+			httpClient.Transport,
+			config.AllowedHeaders()...,
+		).WithLogger(config.Logger)
 	}
 	return nil
 }
@@ -0,0 +1,59 @@
+package shared
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+type (
+	nodeContextKey      struct{}
+	scopeContextKey     struct{}
+	balancingContextKey struct{}
+)
+
+// WithNode returns a context that makes requests performed with it go to the given node,
+//
+//	regardless of load balancing and routing scope of the helper
+func WithNode(ctx context.Context, node url.URL) context.Context {
+	return context.WithValue(ctx, nodeContextKey{}, node)
+}
+
+// NodeFromContext returns node set by `WithNode`
+func NodeFromContext(ctx context.Context) (url.URL, bool) {
+	node, ok := ctx.Value(nodeContextKey{}).(url.URL)
+	return node, ok
+}
+
+// WithScope returns a context that makes requests performed with it go to nodes of the given scope,
+//
+//	regardless of routing scope of the helper
+func WithScope(ctx context.Context, scope rt.Scope) context.Context {
+	if scope == nil {
+		panic("scope can't be nil")
+	}
+	return context.WithValue(ctx, scopeContextKey{}, scope)
+}
+
+// ScopeFromContext returns routing scope set by `WithScope`
+func ScopeFromContext(ctx context.Context) (rt.Scope, bool) {
+	scope, ok := ctx.Value(scopeContextKey{}).(rt.Scope)
+	return scope, ok
+}
+
+// WithBalancingPolicy returns a context that makes requests performed with it go to the node picked by the policy
+//
+//	out of nodes of the routing scope
+func WithBalancingPolicy(ctx context.Context, policy BalancingPolicy) context.Context {
+	if policy == nil {
+		panic("policy can't be nil")
+	}
+	return context.WithValue(ctx, balancingContextKey{}, policy)
+}
+
+// BalancingPolicyFromContext returns balancing policy set by `WithBalancingPolicy`
+func BalancingPolicyFromContext(ctx context.Context) (BalancingPolicy, bool) {
+	policy, ok := ctx.Value(balancingContextKey{}).(BalancingPolicy)
+	return policy, ok
+}
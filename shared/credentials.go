@@ -0,0 +1,182 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+const defaultCredentialsFileReloadInterval = time.Minute
+
+// Credentials a key pair requests are signed with
+type Credentials struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	// SessionToken is only used for temporary credentials, Alternator ignores it
+	SessionToken string `json:"session_token,omitempty"`
+	// Expires when credentials have to be retrieved again, zero value means never
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// CredentialsProvider an interface that provides DynamoDB clients with credentials,
+//
+//	AWS SDKs cache retrieved credentials until they expire
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context, log logx.Logger) (Credentials, error)
+}
+
+// StaticCredentialsProvider serves provided credentials, they never expire
+type StaticCredentialsProvider struct {
+	creds Credentials
+}
+
+// NewStaticCredentialsProvider returns a new instance of `StaticCredentialsProvider` that serves provided key pair
+func NewStaticCredentialsProvider(accessKeyID, secretAccessKey string) *StaticCredentialsProvider {
+	return &StaticCredentialsProvider{
+		creds: Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+		},
+	}
+}
+
+// Retrieve implementation of `CredentialsProvider` that serves provided credentials
+func (p *StaticCredentialsProvider) Retrieve(_ context.Context, _ logx.Logger) (Credentials, error) {
+	return p.creds, nil
+}
+
+// CallbackCredentialsProvider serves credentials returned by a callback, e.g. one that reads them from Vault
+type CallbackCredentialsProvider struct {
+	fn  func(ctx context.Context) (Credentials, error)
+	ttl time.Duration
+}
+
+// NewCallbackCredentialsProvider returns a new instance of `CallbackCredentialsProvider`,
+//
+//	credentials returned by the callback without expiration time expire after `ttl`, 0 means never
+func NewCallbackCredentialsProvider(
+	fn func(ctx context.Context) (Credentials, error),
+	ttl time.Duration,
+) *CallbackCredentialsProvider {
+	return &CallbackCredentialsProvider{
+		fn:  fn,
+		ttl: ttl,
+	}
+}
+
+// Retrieve implementation of `CredentialsProvider` that serves credentials returned by the callback
+func (p *CallbackCredentialsProvider) Retrieve(ctx context.Context, _ logx.Logger) (Credentials, error) {
+	creds, err := p.fn(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if creds.Expires.IsZero() && p.ttl > 0 {
+		creds.Expires = time.Now().Add(p.ttl)
+	}
+	return creds, nil
+}
+
+// FileCredentialsProvider serves credentials from a JSON file, the file is read again when it changes:
+//
+//	{"access_key_id": "...", "secret_access_key": "..."}
+type FileCredentialsProvider struct {
+	path           string
+	reloadInterval time.Duration
+	creds          *Credentials
+	mutex          sync.Mutex
+	modTime        time.Time
+}
+
+// NewFileCredentialsProvider returns a new instance of `FileCredentialsProvider`,
+//
+//	credentials expire every `reloadInterval` so that changes of the file are picked up, 0 means 1 minute
+func NewFileCredentialsProvider(path string, reloadInterval time.Duration) *FileCredentialsProvider {
+	if reloadInterval <= 0 {
+		reloadInterval = defaultCredentialsFileReloadInterval
+	}
+	return &FileCredentialsProvider{
+		path:           path,
+		reloadInterval: reloadInterval,
+	}
+}
+
+// Retrieve implementation of `CredentialsProvider` that serves credentials from the file
+func (p *FileCredentialsProvider) Retrieve(_ context.Context, log logx.Logger) (Credentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	creds, err := p.load()
+	if err != nil {
+		if p.creds == nil {
+			return Credentials{}, err
+		}
+		log.Error(err.Error())
+		creds = p.creds
+	}
+
+	out := *creds
+	if expires := time.Now().Add(p.reloadInterval); out.Expires.IsZero() || out.Expires.After(expires) {
+		out.Expires = expires
+	}
+	return out, nil
+}
+
+func (p *FileCredentialsProvider) load() (*Credentials, error) {
+	stat, err := os.Stat(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat credentials file %s: %w", p.path, err)
+	}
+	if p.creds != nil && stat.ModTime().Equal(p.modTime) {
+		return p.creds, nil // Return cached credentials if unchanged
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file %s: %w", p.path, err)
+	}
+	var creds Credentials
+	if err = json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to decode credentials file %s: %w", p.path, err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("credentials file %s has empty access key id or secret access key", p.path)
+	}
+
+	p.creds = &creds
+	p.modTime = stat.ModTime()
+	return p.creds, nil
+}
+
+// CachedCredentialsProvider serves credentials retrieved from another provider until they expire,
+//
+//	it lets node discovery and clients of a helper share credentials instead of retrieving them separately
+type CachedCredentialsProvider struct {
+	provider CredentialsProvider
+	mutex    sync.Mutex
+	creds    *Credentials
+}
+
+// NewCachedCredentialsProvider returns a new instance of `CachedCredentialsProvider` on top of the provider
+func NewCachedCredentialsProvider(provider CredentialsProvider) *CachedCredentialsProvider {
+	return &CachedCredentialsProvider{provider: provider}
+}
+
+// Retrieve implementation of `CredentialsProvider` that serves cached credentials until they expire
+func (p *CachedCredentialsProvider) Retrieve(ctx context.Context, log logx.Logger) (Credentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.creds != nil && (p.creds.Expires.IsZero() || time.Now().Before(p.creds.Expires)) {
+		return *p.creds, nil
+	}
+	creds, err := p.provider.Retrieve(ctx, log)
+	if err != nil {
+		return Credentials{}, err
+	}
+	p.creds = &creds
+	return creds, nil
+}
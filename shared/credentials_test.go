@@ -0,0 +1,111 @@
+package shared_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+func TestFileCredentialsProvider(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write credentials file: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("failed to change credentials file time: %v", err)
+		}
+	}
+	retrieve := func(p shared.CredentialsProvider) shared.Credentials {
+		t.Helper()
+		creds, err := p.Retrieve(context.Background(), logx.Noop{})
+		if err != nil {
+			t.Fatalf("failed to retrieve credentials: %v", err)
+		}
+		return creds
+	}
+
+	provider := shared.NewFileCredentialsProvider(path, time.Second)
+	if _, err := provider.Retrieve(context.Background(), logx.Noop{}); err == nil {
+		t.Fatalf("expected error for missing credentials file")
+	}
+
+	now := time.Now()
+	write(`{"access_key_id": "key1", "secret_access_key": "secret1"}`, now.Add(-time.Minute))
+	creds := retrieve(provider)
+	if creds.AccessKeyID != "key1" || creds.SecretAccessKey != "secret1" {
+		t.Fatalf("unexpected credentials: %+v", creds)
+	}
+	if creds.Expires.IsZero() || creds.Expires.After(time.Now().Add(time.Second)) {
+		t.Fatalf("expected credentials to expire within reload interval, got %v", creds.Expires)
+	}
+
+	write(`{"access_key_id": "key2", "secret_access_key": "secret2"}`, now)
+	if creds = retrieve(provider); creds.AccessKeyID != "key2" {
+		t.Fatalf("expected credentials to be reloaded, got %+v", creds)
+	}
+
+	write(`not a json`, now.Add(time.Minute))
+	if creds = retrieve(provider); creds.AccessKeyID != "key2" {
+		t.Fatalf("expected last valid credentials to be served when file is broken, got %+v", creds)
+	}
+}
+
+func TestCallbackCredentialsProvider(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	provider := shared.NewCallbackCredentialsProvider(func(context.Context) (shared.Credentials, error) {
+		calls++
+		return shared.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+	}, time.Minute)
+
+	creds, err := provider.Retrieve(context.Background(), logx.Noop{})
+	if err != nil {
+		t.Fatalf("failed to retrieve credentials: %v", err)
+	}
+	if calls != 1 || creds.AccessKeyID != "key" {
+		t.Fatalf("unexpected credentials: %+v", creds)
+	}
+	if until := time.Until(creds.Expires); until <= 0 || until > time.Minute {
+		t.Fatalf("expected credentials to expire after ttl, got %v", creds.Expires)
+	}
+}
+
+func TestCachedCredentialsProvider(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	provider := shared.NewCachedCredentialsProvider(shared.NewCallbackCredentialsProvider(
+		func(context.Context) (shared.Credentials, error) {
+			calls++
+			return shared.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+		},
+		50*time.Millisecond,
+	))
+	retrieve := func() {
+		t.Helper()
+		if _, err := provider.Retrieve(context.Background(), logx.Noop{}); err != nil {
+			t.Fatalf("failed to retrieve credentials: %v", err)
+		}
+	}
+
+	retrieve()
+	retrieve()
+	if calls != 1 {
+		t.Fatalf("expected credentials to be retrieved once until they expire, got %d calls", calls)
+	}
+	time.Sleep(60 * time.Millisecond)
+	retrieve()
+	if calls != 2 {
+		t.Fatalf("expected expired credentials to be retrieved again, got %d calls", calls)
+	}
+}
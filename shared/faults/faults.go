@@ -0,0 +1,286 @@
+// Package faults provides an http.RoundTripper that injects failures into requests
+// going to Alternator nodes, so that client behavior can be verified when nodes misbehave.
+//
+// Failures are described by a declarative rule set, that can be replaced at runtime.
+// Every rule matches requests by node and/or by DynamoDB operation (taken from X-Amz-Target)
+// and describes a fault to inject:
+//   - Latency: delays the request and lets it proceed.
+//   - Reset: fails the request with a connection reset error.
+//   - ErrorResponse: responds with 5xx or any other DynamoDB error without reaching the node.
+//   - Throttling: responds with ThrottlingException without reaching the node.
+//   - Truncate: passes the request to the node and cuts the response body.
+//   - BlackHole: never responds, request hangs until its context is done.
+//
+// Example:
+//
+//	transport := faults.NewTransport(shared.DefaultHTTPTransport(), faults.Rule{
+//	    Node:      "10.0.0.1",
+//	    Operation: "PutItem",
+//	    Fault:     faults.Fault{Kind: faults.Throttling},
+//	    Times:     3,
+//	})
+//	h, err := sdkv2.NewHelper(nodes, sdkv2.WithHTTPTransport(transport))
+package faults
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Kind is a kind of injected fault
+type Kind string
+
+const (
+	// Latency delays the request by `Fault.Latency` and lets it proceed
+	Latency Kind = "latency"
+	// Reset fails the request with a connection reset error
+	Reset Kind = "reset"
+	// ErrorResponse responds with `Fault.StatusCode` and DynamoDB error of `Fault.ErrorType`
+	ErrorResponse Kind = "error"
+	// Throttling responds with ThrottlingException
+	Throttling Kind = "throttling"
+	// Truncate cuts response body after `Fault.TruncateAfter` bytes
+	Truncate Kind = "truncate"
+	// BlackHole never responds, request hangs until its context is done
+	BlackHole Kind = "blackhole"
+)
+
+const errorTypePrefix = "com.amazonaws.dynamodb.v20120810#"
+
+// Fault describes a failure to inject
+type Fault struct {
+	Kind Kind `json:"kind"`
+	// Latency how long to delay the request, used by `Latency`, in nanoseconds when encoded in JSON
+	Latency time.Duration `json:"latency,omitempty"`
+	// StatusCode of the response, used by `ErrorResponse`, 500 by default
+	StatusCode int `json:"statusCode,omitempty"`
+	// ErrorType DynamoDB error type, used by `ErrorResponse`, "InternalServerError" by default
+	ErrorType string `json:"errorType,omitempty"`
+	// Message of the error, used by `ErrorResponse` and `Throttling`
+	Message string `json:"message,omitempty"`
+	// TruncateAfter number of response body bytes to pass through, used by `Truncate`
+	TruncateAfter int `json:"truncateAfter,omitempty"`
+}
+
+// Rule describes which requests a fault is injected into
+type Rule struct {
+	// Node matches host or host:port of the node request goes to, empty matches any node
+	Node string `json:"node,omitempty"`
+	// Operation matches DynamoDB operation, e.g. "PutItem", empty matches any request,
+	// including ones to "/localnodes"
+	Operation string `json:"operation,omitempty"`
+	// Path matches request URL path, e.g. "/localnodes", empty matches any path
+	Path string `json:"path,omitempty"`
+	// Probability of injecting the fault into matching request, values outside of (0, 1) mean always
+	Probability float64 `json:"probability,omitempty"`
+	// Times limits number of requests the fault is injected into, 0 means no limit
+	Times int `json:"times,omitempty"`
+	// Fault to inject
+	Fault Fault `json:"fault"`
+}
+
+func (r *Rule) matches(req *http.Request) bool {
+	if r.Node != "" && r.Node != req.URL.Host && r.Node != req.URL.Hostname() {
+		return false
+	}
+	if r.Path != "" && r.Path != req.URL.Path {
+		return false
+	}
+	if r.Operation != "" && r.Operation != operationName(req) {
+		return false
+	}
+	if r.Probability > 0 && r.Probability < 1 && rand.Float64() >= r.Probability {
+		return false
+	}
+	return true
+}
+
+type ruleSet struct {
+	rules []Rule
+	hits  []atomic.Int64
+}
+
+// Transport takes original `http.RoundTripper` and injects faults into requests matching its rules
+type Transport struct {
+	original http.RoundTripper
+	rules    atomic.Pointer[ruleSet]
+}
+
+// NewTransport wraps provided `http.RoundTripper` and returns new instance of `Transport`
+func NewTransport(original http.RoundTripper, rules ...Rule) *Transport {
+	t := &Transport{
+		original: original,
+	}
+	t.SetRules(rules...)
+	return t
+}
+
+// SetRules replaces current rule set, `Rule.Times` counters are reset
+func (t *Transport) SetRules(rules ...Rule) {
+	t.rules.Store(&ruleSet{
+		rules: append([]Rule(nil), rules...),
+		hits:  make([]atomic.Int64, len(rules)),
+	})
+}
+
+// Rules returns a copy of current rule set
+func (t *Transport) Rules() []Rule {
+	return append([]Rule(nil), t.rules.Load().rules...)
+}
+
+// Clear removes all rules
+func (t *Transport) Clear() {
+	t.SetRules()
+}
+
+// LoadRules replaces current rule set with one decoded from JSON array of rules
+func (t *Transport) LoadRules(data []byte) error {
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("failed to decode fault rules: %w", err)
+	}
+	t.SetRules(rules...)
+	return nil
+}
+
+// RoundTrip an implementation of `http.RoundTripper.RoundTrip`
+//
+//	latency faults of all matching rules are applied, then the first matching rule with other fault kind is applied,
+//	request body is closed when request does not reach original `http.RoundTripper`
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delegated := false
+	defer func() {
+		if !delegated && req.Body != nil {
+			_ = req.Body.Close()
+		}
+	}()
+
+	set := t.rules.Load()
+	for i := range set.rules {
+		rule := &set.rules[i]
+		if !rule.matches(req) {
+			continue
+		}
+		if rule.Times > 0 && set.hits[i].Add(1) > int64(rule.Times) {
+			continue
+		}
+
+		switch rule.Fault.Kind {
+		case Latency:
+			select {
+			case <-time.After(rule.Fault.Latency):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		case Reset:
+			return nil, &net.OpError{
+				Op:   "read",
+				Net:  "tcp",
+				Addr: fakeAddr(req.URL.Host),
+				Err:  os.NewSyscallError("read", syscall.ECONNRESET),
+			}
+		case ErrorResponse:
+			status := rule.Fault.StatusCode
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			errorType := rule.Fault.ErrorType
+			if errorType == "" {
+				errorType = "InternalServerError"
+			}
+			return errorResponse(req, status, errorType, rule.Fault.Message), nil
+		case Throttling:
+			message := rule.Fault.Message
+			if message == "" {
+				message = "Rate of requests exceeds the allowed throughput"
+			}
+			return errorResponse(req, http.StatusBadRequest, "ThrottlingException", message), nil
+		case Truncate:
+			delegated = true
+			resp, err := t.original.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = &truncatedBody{body: resp.Body, remaining: rule.Fault.TruncateAfter}
+			return resp, nil
+		case BlackHole:
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		default:
+			return nil, fmt.Errorf("unknown fault kind: %q", rule.Fault.Kind)
+		}
+	}
+	delegated = true
+	return t.original.RoundTrip(req)
+}
+
+// Unwrap returns original `http.RoundTripper`
+func (t *Transport) Unwrap() http.RoundTripper {
+	return t.original
+}
+
+var _ http.RoundTripper = &Transport{}
+
+func operationName(req *http.Request) string {
+	_, op, _ := strings.Cut(req.Header.Get("X-Amz-Target"), ".")
+	return op
+}
+
+func errorResponse(req *http.Request, status int, errorType, message string) *http.Response {
+	body, _ := json.Marshal(map[string]string{
+		"__type":  errorTypePrefix + errorType,
+		"message": message,
+	})
+	return &http.Response{
+		Status:     strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type":   []string{"application/x-amz-json-1.0"},
+			"Content-Length": []string{strconv.Itoa(len(body))},
+		},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+type truncatedBody struct {
+	body      io.ReadCloser
+	remaining int
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(p) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= n
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return b.body.Close()
+}
+
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "tcp" }
+
+func (a fakeAddr) String() string { return string(a) }
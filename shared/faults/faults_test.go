@@ -0,0 +1,158 @@
+package faults_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/faults"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"TableNames":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server url: %v", err)
+	}
+
+	transport := faults.NewTransport(http.DefaultTransport)
+	client := &http.Client{Transport: transport}
+	do := func(ctx context.Context, operation string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL, strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+operation)
+		return client.Do(req)
+	}
+
+	t.Run("Throttling", func(t *testing.T) {
+		transport.SetRules(faults.Rule{
+			Operation: "PutItem",
+			Times:     1,
+			Fault:     faults.Fault{Kind: faults.Throttling},
+		})
+		resp, err := do(context.Background(), "PutItem")
+		if err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "ThrottlingException") {
+			t.Fatalf("expected ThrottlingException, got %d: %s", resp.StatusCode, body)
+		}
+
+		resp, err = do(context.Background(), "PutItem")
+		if err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("fault should have been injected only once, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		transport.SetRules(faults.Rule{
+			Node:  srvURL.Hostname(),
+			Fault: faults.Fault{Kind: faults.Reset},
+		})
+		_, err := do(context.Background(), "GetItem")
+		if !errors.Is(err, syscall.ECONNRESET) {
+			t.Fatalf("expected connection reset, got %v", err)
+		}
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		transport.SetRules(faults.Rule{
+			Fault: faults.Fault{Kind: faults.Truncate, TruncateAfter: 5},
+		})
+		resp, err := do(context.Background(), "ListTables")
+		if err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+		defer resp.Body.Close() //nolint: errcheck // no need to check
+		if _, err = io.ReadAll(resp.Body); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected unexpected EOF, got %v", err)
+		}
+	})
+
+	t.Run("LatencyAndBlackHole", func(t *testing.T) {
+		err := transport.LoadRules([]byte(`[
+			{"operation": "Scan", "fault": {"kind": "latency", "latency": 1000000}},
+			{"operation": "Scan", "fault": {"kind": "blackhole"}}
+		]`))
+		if err != nil {
+			t.Fatalf("failed to load rules: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err = do(ctx, "Scan"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	})
+
+	t.Run("CloseBody", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+		tcases := []struct {
+			fault faults.Fault
+			ctx   context.Context
+		}{
+			{fault: faults.Fault{Kind: faults.ErrorResponse}, ctx: context.Background()},
+			{fault: faults.Fault{Kind: faults.Throttling}, ctx: context.Background()},
+			{fault: faults.Fault{Kind: faults.Reset}, ctx: context.Background()},
+			{fault: faults.Fault{Kind: faults.Latency, Latency: time.Minute}, ctx: canceled},
+			{fault: faults.Fault{Kind: faults.BlackHole}, ctx: canceled},
+			{fault: faults.Fault{Kind: "unknown"}, ctx: context.Background()},
+		}
+		for _, tc := range tcases {
+			transport.SetRules(faults.Rule{Fault: tc.fault})
+			body := &closeRecorder{Reader: strings.NewReader("{}")}
+			req, err := http.NewRequestWithContext(tc.ctx, http.MethodPost, srv.URL, body)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			resp, _ := transport.RoundTrip(req)
+			if resp != nil {
+				_ = resp.Body.Close()
+			}
+			if !body.closed {
+				t.Errorf("expected request body to be closed on %q fault", tc.fault.Kind)
+			}
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		transport.Clear()
+		resp, err := do(context.Background(), "Scan")
+		if err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+	})
+}
+
+// closeRecorder is a request body that records whether it is closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeRecorder) Close() error {
+	b.closed = true
+	return nil
+}
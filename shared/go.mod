@@ -2,6 +2,12 @@ module github.com/scylladb/alternator-client-golang/shared
 
 go 1.24.0
 
-require go.uber.org/zap v1.27.0
+require (
+	go.uber.org/zap v1.27.0
+	software.sslmate.com/src/go-pkcs12 v0.5.0
+)
 
-require go.uber.org/multierr v1.11.0 // indirect
+require (
+	go.uber.org/multierr v1.11.0 // indirect
+	golang.org/x/crypto v0.48.0 // indirect
+)
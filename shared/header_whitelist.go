@@ -4,6 +4,9 @@ import (
 	"net/http"
 	"slices"
 	"strings"
+	"sync"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
 )
 
 // HeaderWhiteListing takes original `http.RoundTripper` before calling it, removes http headers that does not
@@ -12,6 +15,7 @@ import (
 type HeaderWhiteListing struct {
 	allowedHeaders []string
 	original       http.RoundTripper
+	removedHeaders *RemovedHeadersLogger
 }
 
 // NewHeaderWhiteListingTransport wraps provided `http.RoundTripper` and returns new instance of `HeaderWhiteListing`
@@ -22,21 +26,81 @@ func NewHeaderWhiteListingTransport(original http.RoundTripper, allowedHeaders .
 	return &HeaderWhiteListing{
 		allowedHeaders: allowedHeaders,
 		original:       original,
+		removedHeaders: NewRemovedHeadersLogger(nil),
 	}
 }
 
+// WithLogger makes `HeaderWhiteListing` log names of removed headers at debug level, see `RemovedHeadersLogger`
+func (h *HeaderWhiteListing) WithLogger(logger logx.Logger) *HeaderWhiteListing {
+	h.removedHeaders = NewRemovedHeadersLogger(logger)
+	return h
+}
+
 // RoundTrip an implementation of `http.RoundTripper.RoundTrip`
 //
 //	remove all headers that does not match expected list and call `original.RoundTrip` on the original
 func (h HeaderWhiteListing) RoundTrip(r *http.Request) (*http.Response, error) {
-	newHeaders := http.Header{}
-	for headerName := range r.Header {
-		if slices.Contains(h.allowedHeaders, strings.ToLower(headerName)) {
-			newHeaders.Set(headerName, r.Header.Get(headerName))
-		}
-	}
-	r.Header = newHeaders
+	r.Header = r.Header.Clone()
+	StripAndLogHeaders(r.Header, h.removedHeaders, h.allowedHeaders...)
 	return h.original.RoundTrip(r)
 }
 
+// Unwrap returns original `http.RoundTripper`
+func (h HeaderWhiteListing) Unwrap() http.RoundTripper {
+	return h.original
+}
+
 var _ http.RoundTripper = HeaderWhiteListing{}
+
+// StripHeaders removes http headers that does not match expected list from the header in place,
+//
+//	all values of multi-value headers are kept intact, returns names of removed headers
+func StripHeaders(header http.Header, allowedHeaders ...string) []string {
+	var removed []string
+	for headerName := range header {
+		if !slices.ContainsFunc(allowedHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, headerName)
+		}) {
+			removed = append(removed, headerName)
+		}
+	}
+	for _, headerName := range removed {
+		delete(header, headerName)
+	}
+	return removed
+}
+
+// RemovedHeadersLogger logs names of headers removed from requests at debug level,
+//
+//	every distinct set of names is logged only once, so that requests with the same headers don't flood the log
+type RemovedHeadersLogger struct {
+	logger logx.Logger
+	logged sync.Map
+}
+
+// NewRemovedHeadersLogger creates new `RemovedHeadersLogger`, nil logger disables logging
+func NewRemovedHeadersLogger(logger logx.Logger) *RemovedHeadersLogger {
+	if logger == nil {
+		logger = logx.Noop{}
+	}
+	return &RemovedHeadersLogger{logger: logger}
+}
+
+// Log logs names of removed headers unless the same set of names has been logged already
+func (l *RemovedHeadersLogger) Log(removed []string) {
+	if len(removed) == 0 || !l.logger.Enabled(logx.Debug) {
+		return
+	}
+	slices.Sort(removed)
+	if _, loaded := l.logged.LoadOrStore(strings.Join(removed, ","), struct{}{}); loaded {
+		return
+	}
+	l.logger.Debug("removed http headers", logx.A("headers", removed))
+}
+
+// StripAndLogHeaders removes http headers that does not match expected list from the header in place
+//
+//	and logs names of removed headers with provided `RemovedHeadersLogger`
+func StripAndLogHeaders(header http.Header, log *RemovedHeadersLogger, allowedHeaders ...string) {
+	log.Log(StripHeaders(header, allowedHeaders...))
+}
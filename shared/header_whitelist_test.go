@@ -0,0 +1,72 @@
+package shared_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+type debugRecorder struct {
+	logx.Noop
+	lock    sync.Mutex
+	removed [][]string
+}
+
+func (r *debugRecorder) Debug(_ string, attrs ...logx.Attr) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, attr := range attrs {
+		if headers, ok := attr.Value.([]string); ok {
+			r.removed = append(r.removed, headers)
+		}
+	}
+}
+
+func (r *debugRecorder) Enabled(logx.Level) bool {
+	return true
+}
+
+func TestHeaderWhiteListing(t *testing.T) {
+	t.Parallel()
+
+	var received []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header)
+	}))
+	t.Cleanup(srv.Close)
+
+	logger := &debugRecorder{}
+	transport := shared.NewHeaderWhiteListingTransport(shared.DefaultHTTPTransport(), "X-Amz-Target").
+		WithLogger(logger)
+	client := &http.Client{Transport: transport}
+
+	for _, extra := range []string{"", "", "", "X-Extra"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, http.NoBody)
+		req.Header.Set("X-Amz-Target", "DynamoDB_20120810.GetItem")
+		req.Header.Set("X-Custom", "value")
+		if extra != "" {
+			req.Header.Set(extra, "value")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	for _, header := range received {
+		if header.Get("X-Custom") != "" || header.Get("X-Extra") != "" || header.Get("X-Amz-Target") == "" {
+			t.Errorf("expected only allowed headers to be sent, got %v", header)
+		}
+	}
+	// Every distinct set of removed headers is logged once
+	expected := [][]string{{"X-Custom"}, {"X-Custom", "X-Extra"}}
+	if !slices.EqualFunc(logger.removed, expected, slices.Equal) {
+		t.Errorf("expected removed headers to be logged as %v, got %v", expected, logger.removed)
+	}
+}
@@ -0,0 +1,75 @@
+package shared
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// HTTP2Mode controls whether http clients use HTTP/2 to talk to Alternator nodes
+type HTTP2Mode int
+
+const (
+	// HTTP2Default leaves protocol negotiation up to `http.Transport` defaults
+	HTTP2Default HTTP2Mode = iota
+	// HTTP2Off makes http clients use HTTP/1.1 only
+	HTTP2Off
+	// HTTP2Attempt makes http clients negotiate HTTP/2 over TLS and fall back to HTTP/1.1
+	//	when node does not support it, plain HTTP connections use HTTP/1.1
+	HTTP2Attempt
+	// HTTP2Required makes http clients use HTTP/2 only, over TLS it has to be negotiated via ALPN,
+	//	over plain HTTP it is used with prior knowledge (h2c)
+	HTTP2Required
+)
+
+// String returns name of the mode
+func (m HTTP2Mode) String() string {
+	switch m {
+	case HTTP2Default:
+		return "default"
+	case HTTP2Off:
+		return "off"
+	case HTTP2Attempt:
+		return "attempt"
+	case HTTP2Required:
+		return "required"
+	default:
+		return fmt.Sprintf("HTTP2Mode(%d)", int(m))
+	}
+}
+
+// PatchHTTP2Mode configures protocols of `http.Transport` according to provided `HTTP2Mode`
+func PatchHTTP2Mode(mode HTTP2Mode, transport *http.Transport) {
+	protocols := &http.Protocols{}
+	switch mode {
+	case HTTP2Off:
+		protocols.SetHTTP1(true)
+		transport.ForceAttemptHTTP2 = false
+		// Transport cloned from http.DefaultTransport can already have HTTP/2 installed,
+		//	empty map is a documented way to disable it
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		if transport.TLSClientConfig != nil {
+			transport.TLSClientConfig.NextProtos = slices.DeleteFunc(
+				slices.Clone(transport.TLSClientConfig.NextProtos),
+				func(proto string) bool { return proto == "h2" },
+			)
+		}
+		transport.Protocols = protocols
+		return
+	case HTTP2Attempt:
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		// Custom TLSClientConfig disables HTTP/2 unless it is forced
+		transport.ForceAttemptHTTP2 = true
+	case HTTP2Required:
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.ForceAttemptHTTP2 = true
+	default:
+		return
+	}
+	// Let `Protocols` drive HTTP/2 configuration instead of one inherited from http.DefaultTransport
+	transport.TLSNextProto = nil
+	transport.Protocols = protocols
+}
@@ -0,0 +1,81 @@
+package shared_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+func newHTTP2TestServer(t *testing.T, tlsEnabled, http2Enabled bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	if tlsEnabled {
+		protocols.SetHTTP2(http2Enabled)
+		srv.EnableHTTP2 = http2Enabled
+		srv.Config.Protocols = protocols
+		srv.StartTLS()
+	} else {
+		protocols.SetUnencryptedHTTP2(http2Enabled)
+		srv.Config.Protocols = protocols
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTP2Mode(t *testing.T) {
+	t.Parallel()
+
+	tcases := []struct {
+		name          string
+		mode          shared.HTTP2Mode
+		tls           bool
+		serverHTTP2   bool
+		expectedProto int
+		expectedError bool
+	}{
+		{name: "Off/TLS", mode: shared.HTTP2Off, tls: true, serverHTTP2: true, expectedProto: 1},
+		{name: "Attempt/TLS", mode: shared.HTTP2Attempt, tls: true, serverHTTP2: true, expectedProto: 2},
+		{name: "Attempt/TLSWithoutHTTP2", mode: shared.HTTP2Attempt, tls: true, expectedProto: 1},
+		{name: "Required/TLS", mode: shared.HTTP2Required, tls: true, serverHTTP2: true, expectedProto: 2},
+		{name: "Required/TLSWithoutHTTP2", mode: shared.HTTP2Required, tls: true, expectedError: true},
+		{name: "Off/Plain", mode: shared.HTTP2Off, serverHTTP2: true, expectedProto: 1},
+		{name: "Attempt/Plain", mode: shared.HTTP2Attempt, serverHTTP2: true, expectedProto: 1},
+		{name: "Required/H2C", mode: shared.HTTP2Required, serverHTTP2: true, expectedProto: 2},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := newHTTP2TestServer(t, tc.tls, tc.serverHTTP2)
+
+			cfg := shared.NewDefaultALNConfig()
+			shared.WithALNHTTP2(tc.mode)(&cfg)
+			shared.WithALNIgnoreServerCertificateError(true)(&cfg)
+			client := &http.Client{Transport: shared.NewHTTPTransport(cfg)}
+			defer client.CloseIdleConnections()
+
+			resp, err := client.Get(srv.URL)
+			if tc.expectedError {
+				if err == nil {
+					_ = resp.Body.Close()
+					t.Fatalf("request should have failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request unexpectedly failed: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.ProtoMajor != tc.expectedProto {
+				t.Fatalf("expected HTTP/%d, got %s", tc.expectedProto, resp.Proto)
+			}
+		})
+	}
+}
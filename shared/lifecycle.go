@@ -0,0 +1,171 @@
+package shared
+
+import (
+	"context"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+// LifecycleState a state of `AlternatorLiveNodes` background node discovery
+type LifecycleState int32
+
+const (
+	// StateNew background discovery is not started yet, it is started by `Start` or on first `NextNode`
+	StateNew LifecycleState = iota
+	// StateRunning background discovery is running
+	StateRunning
+	// StateStopped background discovery is stopped by `Stop`, it can be started again by `Start`
+	StateStopped
+	// StateClosed instance is closed by `Close`, it can't be started again and node discovery fails with `ErrClosed`
+	StateClosed
+)
+
+func (s LifecycleState) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateRunning:
+		return "running"
+	case StateStopped:
+		return "stopped"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns current lifecycle state
+func (aln *AlternatorLiveNodes) State() LifecycleState {
+	return LifecycleState(aln.state.Load())
+}
+
+// Start begins background routines used for periodic node discovery and updates.
+// It is not required to start it, it is started automatically on first API call, but it is required to restart
+// it after `Stop`
+func (aln *AlternatorLiveNodes) Start() {
+	aln.lifecycleLock.Lock()
+	defer aln.lifecycleLock.Unlock()
+	switch aln.State() {
+	case StateRunning:
+	case StateClosed:
+		aln.cfg.Logger.Warn("Start is called on closed AlternatorLiveNodes, node discovery is not started")
+	default:
+		aln.startLocked()
+	}
+}
+
+// ensureRunning lazily starts background routines of a new instance, or warns once if they are stopped
+func (aln *AlternatorLiveNodes) ensureRunning() {
+	switch state := aln.State(); state {
+	case StateRunning:
+	case StateNew:
+		aln.lifecycleLock.Lock()
+		defer aln.lifecycleLock.Unlock()
+		if aln.State() == StateNew {
+			aln.startLocked()
+		}
+	default:
+		if aln.stoppedWarned.CompareAndSwap(false, true) {
+			aln.cfg.Logger.Warn(
+				"NextNode is called on AlternatorLiveNodes that is not running, list of nodes is not refreshed",
+				logx.A("state", state.String()),
+			)
+		}
+	}
+}
+
+func (aln *AlternatorLiveNodes) startLocked() {
+	ctx, cancel := context.WithCancel(context.Background())
+	aln.stopFn = cancel
+	aln.stoppedWarned.Store(false)
+	aln.state.Store(int32(StateRunning))
+	aln.goBackgroundLocked(func() { aln.runUpdater(ctx) })
+}
+
+// runUpdater refreshes lists of nodes when asked to and, if idle update period is set, periodically,
+//
+//	it also loads lists of scopes once they are used, so that requests don't wait for discovery
+func (aln *AlternatorLiveNodes) runUpdater(ctx context.Context) {
+	var tick <-chan time.Time
+	if aln.cfg.IdleUpdatePeriod > 0 {
+		t := time.NewTicker(aln.cfg.IdleUpdatePeriod)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			_ = aln.refresh()
+		case <-aln.updateSignal:
+			_ = aln.refresh()
+		case <-aln.loadSignal:
+			aln.loadScopedNodes()
+		}
+	}
+}
+
+// Stop stops background routines used for periodic node discovery and updates, they can be started again by `Start`
+func (aln *AlternatorLiveNodes) Stop() {
+	aln.lifecycleLock.Lock()
+	defer aln.lifecycleLock.Unlock()
+	if aln.State() == StateClosed {
+		return
+	}
+	if aln.stopFn != nil {
+		aln.stopFn()
+		aln.stopFn = nil
+	}
+	aln.state.Store(int32(StateStopped))
+}
+
+// Close stops background routines, waits for them to finish, closes idle connections of discovery http client
+// and releases http transport of the caller patched in place.
+// It is safe to call it multiple times and concurrently, it returns `ctx.Err()` if ctx is done before routines finish
+func (aln *AlternatorLiveNodes) Close(ctx context.Context) error {
+	aln.lifecycleLock.Lock()
+	if aln.stopFn != nil {
+		aln.stopFn()
+		aln.stopFn = nil
+	}
+	aln.state.Store(int32(StateClosed))
+	aln.lifecycleLock.Unlock()
+	aln.closeFn()
+
+	aln.closeOnce.Do(func() {
+		go func() {
+			aln.background.Wait()
+			aln.httpClient.CloseIdleConnections()
+			aln.releaseTransport()
+			close(aln.closeDone)
+		}()
+	})
+
+	select {
+	case <-aln.closeDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// goBackground runs fn in a goroutine Close waits for, fn is not run if instance is closed
+func (aln *AlternatorLiveNodes) goBackground(fn func()) {
+	aln.lifecycleLock.Lock()
+	defer aln.lifecycleLock.Unlock()
+	aln.goBackgroundLocked(fn)
+}
+
+func (aln *AlternatorLiveNodes) goBackgroundLocked(fn func()) {
+	if aln.State() == StateClosed {
+		return
+	}
+	aln.background.Add(1)
+	go func() {
+		defer aln.background.Done()
+		fn()
+	}()
+}
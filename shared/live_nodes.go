@@ -3,12 +3,15 @@ package shared
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -24,16 +27,40 @@ const (
 
 // AlternatorLiveNodes holds logic that allows to read and remember alternator nodes
 type AlternatorLiveNodes struct {
-	liveNodes          atomic.Pointer[[]url.URL]
-	initialNodes       []url.URL
-	nextLiveNodeIdx    atomic.Uint64
-	cfg                ALNConfig
-	nextUpdate         atomic.Int64
-	idleUpdaterStarted atomic.Bool
-	ctx                context.Context
-	stopFn             context.CancelFunc
-	httpClient         *http.Client
-	updateSignal       chan struct{}
+	liveNodes       atomic.Pointer[[]url.URL]
+	initialNodes    []url.URL
+	nextLiveNodeIdx atomic.Uint64
+	cfg             ALNConfig
+	nextUpdate      atomic.Int64
+	httpClient      *http.Client
+	// releaseTransport releases transport of the caller patched in place on Close, see `inPlacePatches`
+	releaseTransport func()
+	updateSignal     chan struct{}
+	// loadSignal makes background refresher load lists of scopes that are not loaded yet
+	loadSignal        chan struct{}
+	listenersLock     sync.Mutex
+	newNodesListeners []func([]url.URL)
+	scopedNodes       sync.Map
+	liveScope         atomic.Pointer[nodeList]
+	// locations maps host to `nodeLocation` read from system tables or learned from scoped "/localnodes" queries
+	locations sync.Map
+	// locationsWanted is set once locations are needed, see `wantLocations`
+	locationsWanted atomic.Bool
+	// locationsStale is set until wanted locations are read from system tables and whenever nodes of unknown location
+	// show up
+	locationsStale atomic.Bool
+	// lifecycleLock guards state transitions and starting of background goroutines
+	lifecycleLock sync.Mutex
+	state         atomic.Int32
+	stopFn        context.CancelFunc
+	stoppedWarned atomic.Bool
+	// background tracks goroutines, so that Close could wait for them, goroutines are not started once closed
+	background sync.WaitGroup
+	closeOnce  sync.Once
+	closeDone  chan struct{}
+	// closeCtx aborts discovery requests in flight on Close
+	closeCtx context.Context
+	closeFn  context.CancelFunc
 }
 
 // ALNConfig a config for `AlternatorLiveNodes`
@@ -48,16 +75,48 @@ type ALNConfig struct {
 	IgnoreServerCertificateError bool
 	// ClientCertificateSource a certificate store to supplies client certificate to the http client
 	ClientCertificateSource CertSource
-	Logger                  logx.Logger
+	// RootCASource supplies root CAs to verify server certificates against, nil means system roots
+	RootCASource RootCASource
+	// TLSServerName a name to verify server certificates against instead of node address
+	TLSServerName string
+	// SANVerifier a strategy to check that server certificate belongs to the node
+	SANVerifier SANVerifier
+	// CertificatePins base64 encoded SHA-256 hashes of SubjectPublicKeyInfo, one of server certificates has to match
+	CertificatePins []string
+	// VerifyPeerCertificate a custom server certificate verification
+	VerifyPeerCertificate VerifyPeerCertificateFunc
+	// CertificateExpiryMonitor inspects expiration time of client and server certificates
+	CertificateExpiryMonitor *CertificateExpiryMonitor
+	Logger                   logx.Logger
 	// A key writer for pre master key: https://wiki.wireshark.org/TLS#using-the-pre-master-secret
 	KeyLogWriter io.Writer
 	// TLS session cache
-	TLSSessionCache        tls.ClientSessionCache
+	TLSSessionCache tls.ClientSessionCache
+	// TLSPolicy restricts TLS versions, cipher suites and curves
+	TLSPolicy              TLSPolicy
 	MaxIdleHTTPConnections int
 	// Time to keep idle http connection alive
 	IdleHTTPConnectionTimeout time.Duration
+	// Maximum number of idle HTTP connections per node, 0 means http.DefaultMaxIdleConnsPerHost
+	MaxIdleHTTPConnectionsPerHost int
+	// Maximum number of HTTP connections per node, 0 means no limit
+	MaxHTTPConnectionsPerHost int
+	// Maximum amount of time a dial will wait for a connect to complete
+	DialTimeout time.Duration
+	// Interval between keep-alive probes for an active network connection
+	DialKeepAlive time.Duration
+	// Maximum amount of time to wait for a TLS handshake
+	TLSHandshakeTimeout time.Duration
+	// Amount of time to wait for a server's response headers after fully writing the request
+	ResponseHeaderTimeout time.Duration
+	// Controls whether http client uses HTTP/2
+	HTTP2Mode HTTP2Mode
 	// A custom http transport
 	HTTPTransport http.RoundTripper
+	// CredentialsProvider provides credentials to sign requests that read node locations from system tables
+	CredentialsProvider CredentialsProvider
+	// AWSRegion a region requests that read node locations are signed for
+	AWSRegion string
 }
 
 // NewDefaultALNConfig creates new default ALNConfig
@@ -71,7 +130,9 @@ func NewDefaultALNConfig() ALNConfig {
 		TLSSessionCache:           defaultTLSSessionCache,
 		MaxIdleHTTPConnections:    100,
 		IdleHTTPConnectionTimeout: defaultIdleConnectionTimeout,
+		CertificateExpiryMonitor:  NewCertificateExpiryMonitor(nil),
 		Logger:                    logxzap.DefaultLogger(),
+		AWSRegion:                 defaultAWSRegion,
 	}
 }
 
@@ -151,6 +212,33 @@ func WithALNClientCertificate(certificate tls.Certificate) ALNOption {
 	}
 }
 
+// WithALNClientCertificatePEM provides client certificates http clients for both DynamoDB and Alternator requests
+// in PEM format from a callback, it is called again when certificate is older than `refreshInterval`
+func WithALNClientCertificatePEM(
+	fn func(ctx context.Context) (certPEM, keyPEM []byte, err error),
+	refreshInterval time.Duration,
+) ALNOption {
+	return func(config *ALNConfig) {
+		config.ClientCertificateSource = NewPEMCertificate(fn, refreshInterval)
+	}
+}
+
+// WithALNClientCertificatePKCS12File provides client certificates http clients for both DynamoDB and Alternator
+// requests from a password protected PKCS#12 file
+func WithALNClientCertificatePKCS12File(path, password string) ALNOption {
+	return func(config *ALNConfig) {
+		config.ClientCertificateSource = NewPKCS12FileCertificate(path, password)
+	}
+}
+
+// WithALNClientCertificateDir provides client certificates http clients for both DynamoDB and Alternator requests
+// from tls.crt and tls.key files in a directory, e.g. a mounted Kubernetes secret
+func WithALNClientCertificateDir(dir string) ALNOption {
+	return func(config *ALNConfig) {
+		config.ClientCertificateSource = NewDirCertificate(dir, "", "")
+	}
+}
+
 // WithALNClientCertificateSource provides client certificates http clients for both DynamoDB and Alternator requests
 // in a form of custom implementation of `CertSource` interface
 func WithALNClientCertificateSource(source CertSource) ALNOption {
@@ -159,6 +247,72 @@ func WithALNClientCertificateSource(source CertSource) ALNOption {
 	}
 }
 
+// WithALNRootCAFile makes http clients verify server certificates against root CAs from a PEM file,
+// file is read again when it changes
+func WithALNRootCAFile(path string) ALNOption {
+	return func(config *ALNConfig) {
+		config.RootCASource = NewRootCAFile(path)
+	}
+}
+
+// WithALNRootCAs makes http clients verify server certificates against provided root CAs
+func WithALNRootCAs(pool *x509.CertPool) ALNOption {
+	return func(config *ALNConfig) {
+		config.RootCASource = NewRootCAs(pool)
+	}
+}
+
+// WithALNRootCASource makes http clients verify server certificates against root CAs
+// from a custom implementation of `RootCASource` interface
+func WithALNRootCASource(source RootCASource) ALNOption {
+	return func(config *ALNConfig) {
+		config.RootCASource = source
+	}
+}
+
+// WithALNTLSServerName makes http clients verify server certificates against provided name instead of node address
+func WithALNTLSServerName(name string) ALNOption {
+	return func(config *ALNConfig) {
+		config.TLSServerName = name
+	}
+}
+
+// WithALNSANVerifier overrides a strategy to check that server certificate belongs to the node
+func WithALNSANVerifier(verifier SANVerifier) ALNOption {
+	return func(config *ALNConfig) {
+		config.SANVerifier = verifier
+	}
+}
+
+// WithALNCertificatePins pins server certificates by base64 encoded SHA-256 hashes of their SubjectPublicKeyInfo,
+// connection is accepted when any certificate of any verified chain matches any of the pins
+func WithALNCertificatePins(pins ...string) ALNOption {
+	return func(config *ALNConfig) {
+		config.CertificatePins = pins
+	}
+}
+
+// WithALNVerifyPeerCertificate adds custom server certificate verification
+func WithALNVerifyPeerCertificate(verify VerifyPeerCertificateFunc) ALNOption {
+	return func(config *ALNConfig) {
+		config.VerifyPeerCertificate = verify
+	}
+}
+
+// WithALNTLSPolicy restricts TLS versions, cipher suites and curves http clients are allowed to use
+func WithALNTLSPolicy(policy TLSPolicy) ALNOption {
+	return func(config *ALNConfig) {
+		config.TLSPolicy = policy
+	}
+}
+
+// WithALNCertificateExpiryMonitor overrides monitor of client and server certificates expiration time
+func WithALNCertificateExpiryMonitor(monitor *CertificateExpiryMonitor) ALNOption {
+	return func(config *ALNConfig) {
+		config.CertificateExpiryMonitor = monitor
+	}
+}
+
 // WithALNKeyLogWriter makes http clients to write TLS master key into a file
 // It helps to debug issues by looking at decoded HTTPS traffic between Alternator and client
 func WithALNKeyLogWriter(writer io.Writer) ALNOption {
@@ -190,6 +344,61 @@ func WithALNIdleHTTPConnectionTimeout(value time.Duration) ALNOption {
 	}
 }
 
+// WithALNMaxIdleHTTPConnectionsPerHost controls maximum number of idle http connections held by http.Transport
+// to every node
+func WithALNMaxIdleHTTPConnectionsPerHost(value int) ALNOption {
+	return func(config *ALNConfig) {
+		config.MaxIdleHTTPConnectionsPerHost = value
+	}
+}
+
+// WithALNMaxHTTPConnectionsPerHost limits total number of http connections to every node, 0 means no limit
+func WithALNMaxHTTPConnectionsPerHost(value int) ALNOption {
+	return func(config *ALNConfig) {
+		config.MaxHTTPConnectionsPerHost = value
+	}
+}
+
+// WithALNDialTimeout controls how long http client waits for a connect to a node to complete
+func WithALNDialTimeout(value time.Duration) ALNOption {
+	return func(config *ALNConfig) {
+		config.DialTimeout = value
+	}
+}
+
+// WithALNDialKeepAlive controls interval between TCP keep-alive probes
+func WithALNDialKeepAlive(value time.Duration) ALNOption {
+	return func(config *ALNConfig) {
+		config.DialKeepAlive = value
+	}
+}
+
+// WithALNTLSHandshakeTimeout controls how long http client waits for a TLS handshake to complete
+func WithALNTLSHandshakeTimeout(value time.Duration) ALNOption {
+	return func(config *ALNConfig) {
+		config.TLSHandshakeTimeout = value
+	}
+}
+
+// WithALNResponseHeaderTimeout controls how long http client waits for response headers
+func WithALNResponseHeaderTimeout(value time.Duration) ALNOption {
+	return func(config *ALNConfig) {
+		config.ResponseHeaderTimeout = value
+	}
+}
+
+// WithALNHTTP2 controls whether http client uses HTTP/2
+func WithALNHTTP2(mode HTTP2Mode) ALNOption {
+	switch mode {
+	case HTTP2Default, HTTP2Off, HTTP2Attempt, HTTP2Required:
+		return func(config *ALNConfig) {
+			config.HTTP2Mode = mode
+		}
+	default:
+		panic(fmt.Sprintf("invalid http2 mode: %s", mode))
+	}
+}
+
 // WithALNHTTPTransport sets custom transport for http client
 // For testing purposes only, don't use it on production
 func WithALNHTTPTransport(transport http.RoundTripper) ALNOption {
@@ -198,6 +407,21 @@ func WithALNHTTPTransport(transport http.RoundTripper) ALNOption {
 	}
 }
 
+// WithALNCredentialsProvider provides credentials to sign requests that read node locations from system tables,
+// without credentials requests are not signed
+func WithALNCredentialsProvider(provider CredentialsProvider) ALNOption {
+	return func(config *ALNConfig) {
+		config.CredentialsProvider = provider
+	}
+}
+
+// WithALNAWSRegion sets region requests that read node locations are signed for
+func WithALNAWSRegion(region string) ALNOption {
+	return func(config *ALNConfig) {
+		config.AWSRegion = region
+	}
+}
+
 // NewAlternatorLiveNodes creates a new `AlternatorLiveNodes` instance configured with the provided initial Alternator nodes,
 //
 //	in a form of ip or dns name (without port) and optional functional configuration options (e.g., AWS region, credentials, TLS).
@@ -214,6 +438,20 @@ func NewAlternatorLiveNodes(initialNodes []string, options ...ALNOption) (*Alter
 	httpClient := &http.Client{
 		Transport: NewHTTPTransport(cfg),
 	}
+	releaseTransport := func() {}
+	switch t := cfg.HTTPTransport.(type) {
+	case nil:
+	case *http.Transport:
+		// Transport of the caller is cloned, so that it is left intact
+		httpClient.Transport = t.Clone()
+		PatchBasicHTTPTransport(cfg, httpClient.Transport.(*http.Transport))
+	default:
+		// Wrapper can't be cloned, its transport is patched in place, once for node discovery and helpers
+		if transport, ok := UnwrapHTTPTransport(t); ok {
+			releaseTransport = acquireInPlacePatch(cfg, transport)
+		}
+		httpClient.Transport = t
+	}
 
 	nodes := make([]url.URL, len(initialNodes))
 	for i, node := range initialNodes {
@@ -224,17 +462,24 @@ func NewAlternatorLiveNodes(initialNodes []string, options ...ALNOption) (*Alter
 		nodes[i] = *parsed
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	closeCtx, closeFn := context.WithCancel(context.Background())
 	out := &AlternatorLiveNodes{
-		initialNodes: nodes,
-		cfg:          cfg,
-		ctx:          ctx,
-		stopFn:       cancel,
-		httpClient:   httpClient,
-		updateSignal: make(chan struct{}, 1),
+		initialNodes:     nodes,
+		cfg:              cfg,
+		httpClient:       httpClient,
+		releaseTransport: releaseTransport,
+		updateSignal:     make(chan struct{}, 1),
+		loadSignal:       make(chan struct{}, 1),
+		closeDone:        make(chan struct{}),
+		closeCtx:         closeCtx,
+		closeFn:          closeFn,
 	}
 
 	out.liveNodes.Store(&nodes)
+	if cfg.RoutingScope != nil && cfg.RoutingScope.GetLocalNodesQuery() != "" {
+		// Main list is scoped to a datacenter or a rack, so locations of its nodes are read as well
+		out.wantLocations()
+	}
 	return out, nil
 }
 
@@ -254,46 +499,9 @@ func (aln *AlternatorLiveNodes) triggerUpdate() {
 	}
 }
 
-func (aln *AlternatorLiveNodes) startIdleUpdater() {
-	if aln.cfg.IdleUpdatePeriod <= 0 {
-		return
-	}
-	if aln.idleUpdaterStarted.CompareAndSwap(false, true) {
-		go func() {
-			t := time.NewTicker(aln.cfg.IdleUpdatePeriod)
-			defer t.Stop()
-			for {
-				select {
-				case <-aln.ctx.Done():
-					return
-				case <-t.C:
-					aln.nextUpdate.Store(time.Now().UTC().Unix() + int64(aln.cfg.UpdatePeriod.Seconds()))
-					_ = aln.UpdateLiveNodes()
-				case <-aln.updateSignal:
-					aln.nextUpdate.Store(time.Now().UTC().Unix() + int64(aln.cfg.UpdatePeriod.Seconds()))
-					_ = aln.UpdateLiveNodes()
-				}
-			}
-		}()
-	}
-}
-
-// Start begins background routines used for periodic node discovery and updates.
-// It is not required to start if automatically on first API call
-func (aln *AlternatorLiveNodes) Start() {
-	aln.startIdleUpdater()
-}
-
-// Stop stops background routines used for periodic node discovery and updates.
-func (aln *AlternatorLiveNodes) Stop() {
-	if aln.stopFn != nil {
-		aln.stopFn()
-	}
-}
-
 // NextNode gets next node, check if node list needs to be updated and run updating routine if needed
 func (aln *AlternatorLiveNodes) NextNode() url.URL {
-	aln.startIdleUpdater()
+	aln.ensureRunning()
 	aln.triggerUpdate()
 	return aln.nextNode()
 }
@@ -329,25 +537,125 @@ func (aln *AlternatorLiveNodes) nextAsURLWithPath(path, query string) *url.URL {
 	return &newURL
 }
 
-// UpdateLiveNodes forces an immediate refresh of the live Alternator nodes list.
+// UpdateLiveNodes forces an immediate refresh of the live Alternator nodes list,
+// along with lists of nodes of all scopes in use, node locations are read again if nodes of unknown location show up.
 func (aln *AlternatorLiveNodes) UpdateLiveNodes() error {
-	scope := aln.cfg.RoutingScope
+	return aln.refresh()
+}
+
+func (aln *AlternatorLiveNodes) setLiveNodes(nodes []url.URL, tier rt.Scope) {
+	aln.liveScope.Store(&nodeList{scope: tier})
+	oldNodes := aln.liveNodes.Swap(&nodes)
+	aln.checkLocations(addedNodes(*oldNodes, nodes))
+	aln.notifyNewNodes(*oldNodes, nodes)
+}
+
+// nodesQuery reads list of nodes for "/localnodes" query
+type nodesQuery func(query string) ([]url.URL, error)
+
+// discoverNodes reads list of nodes for the scope, falling back to broader scopes when it has no nodes,
+//
+//	returns the nodes and the scope they belong to
+func (aln *AlternatorLiveNodes) discoverNodes(scope rt.Scope) ([]url.URL, rt.Scope, error) {
+	return discoverScope(scope, aln.queryNodes)
+}
+
+// discoverNodesFrom is the same as discoverNodes, but reads list of nodes from the given node only
+func (aln *AlternatorLiveNodes) discoverNodesFrom(
+	ctx context.Context,
+	node url.URL,
+	scope rt.Scope,
+) ([]url.URL, rt.Scope, error) {
+	return discoverScope(scope, func(query string) ([]url.URL, error) {
+		endpoint := node
+		endpoint.Path = "/localnodes"
+		endpoint.RawQuery = query
+		return aln.getNodesWithContext(ctx, &endpoint)
+	})
+}
+
+// queryNodes reads list of nodes for the query from the next node
+func (aln *AlternatorLiveNodes) queryNodes(query string) ([]url.URL, error) {
+	return aln.getNodes(aln.nextAsURLWithPath("/localnodes", query))
+}
+
+func discoverScope(scope rt.Scope, query nodesQuery) ([]url.URL, rt.Scope, error) {
 	for scope != nil {
-		newNodes, err := aln.getNodes(aln.nextAsURLWithPath("/localnodes", scope.GetLocalNodesQuery()))
+		newNodes, err := query(scope.GetLocalNodesQuery())
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		if len(newNodes) != 0 {
-			aln.liveNodes.Store(&newNodes)
-			break
+			return newNodes, scope, nil
 		}
 		scope = scope.Fallback()
 	}
-	return nil
+	return nil, nil, nil
+}
+
+// OnNewNodes registers a callback that is called with nodes that appear in the list of live nodes
+// after it is updated
+func (aln *AlternatorLiveNodes) OnNewNodes(fn func([]url.URL)) {
+	aln.listenersLock.Lock()
+	defer aln.listenersLock.Unlock()
+	aln.newNodesListeners = append(aln.newNodesListeners, fn)
+}
+
+func (aln *AlternatorLiveNodes) notifyNewNodes(oldNodes, newNodes []url.URL) {
+	aln.listenersLock.Lock()
+	listeners := slices.Clone(aln.newNodesListeners)
+	aln.listenersLock.Unlock()
+	notifyAddedNodes(listeners, oldNodes, newNodes)
+}
+
+func notifyAddedNodes(listeners []func([]url.URL), oldNodes, newNodes []url.URL) {
+	if len(listeners) == 0 {
+		return
+	}
+
+	added := addedNodes(oldNodes, newNodes)
+	if len(added) == 0 {
+		return
+	}
+	for _, fn := range listeners {
+		fn(added)
+	}
+}
+
+// addedNodes returns nodes of newNodes that are not in oldNodes
+func addedNodes(oldNodes, newNodes []url.URL) []url.URL {
+	var added []url.URL
+	for _, node := range newNodes {
+		if !slices.Contains(oldNodes, node) {
+			added = append(added, node)
+		}
+	}
+	return added
 }
 
 func (aln *AlternatorLiveNodes) getNodes(endpoint *url.URL) ([]url.URL, error) {
-	resp, err := aln.httpClient.Get(endpoint.String())
+	return aln.getNodesWithContext(aln.closeCtx, endpoint)
+}
+
+func (aln *AlternatorLiveNodes) getNodesWithContext(ctx context.Context, endpoint *url.URL) ([]url.URL, error) {
+	nodes, err := aln.fetchNodes(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	aln.recordLocations(endpoint, nodes)
+	return nodes, nil
+}
+
+// fetchNodes reads list of nodes from the endpoint without recording their locations
+func (aln *AlternatorLiveNodes) fetchNodes(ctx context.Context, endpoint *url.URL) ([]url.URL, error) {
+	if aln.State() == StateClosed {
+		return nil, ErrClosed
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := aln.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -379,7 +687,11 @@ func (aln *AlternatorLiveNodes) getNodes(endpoint *url.URL) ([]url.URL, error) {
 
 // CheckIfRackAndDatacenterSetCorrectly verifies that the rack and datacenter
 // settings are correctly configured and recognized by the Alternator cluster.
-func (aln *AlternatorLiveNodes) CheckIfRackAndDatacenterSetCorrectly() (err error) {
+func (aln *AlternatorLiveNodes) CheckIfRackAndDatacenterSetCorrectly() error {
+	return aln.checkRackAndDatacenter(aln.cfg.RoutingScope)
+}
+
+func (aln *AlternatorLiveNodes) checkRackAndDatacenter(scope rt.Scope) (err error) {
 	var errs []error
 	defer func() {
 		if err == nil && len(errs) > 0 {
@@ -388,7 +700,6 @@ func (aln *AlternatorLiveNodes) CheckIfRackAndDatacenterSetCorrectly() (err erro
 			}
 		}
 	}()
-	scope := aln.cfg.RoutingScope
 	for scope != nil {
 		if _, ok := scope.(rt.ClusterScope); ok {
 			// Cluster scope does not require validation
@@ -420,7 +731,7 @@ func (aln *AlternatorLiveNodes) CheckIfRackDatacenterFeatureIsSupported() (bool,
 	baseURI := aln.nextAsURLWithPath("/localnodes", "")
 	fakeRackURI := aln.nextAsURLWithPath("/localnodes", "rack=fakeRack")
 
-	hostsWithFakeRack, err := aln.getNodes(fakeRackURI)
+	hostsWithFakeRack, err := aln.fetchNodes(aln.closeCtx, fakeRackURI)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,163 @@
+package shared_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+func TestAlternatorLiveNodesClose(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`["127.0.0.1"]`))
+	}))
+	t.Cleanup(srv.Close)
+	_, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	aln, err := shared.NewAlternatorLiveNodes(
+		[]string{"127.0.0.1"},
+		shared.WithALNPort(port),
+		shared.WithALNIdleUpdatePeriod(5*time.Millisecond),
+		shared.WithALNLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create AlternatorLiveNodes: %v", err)
+	}
+	aln.Start()
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := aln.Close(ctx); err != nil {
+				t.Errorf("Close unexpectedly failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Request aborted by Close can still reach the server right after Close returns
+	time.Sleep(20 * time.Millisecond)
+	seen := requests.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := requests.Load(); got != seen {
+		t.Fatalf("expected node discovery to stop after Close, got %d more requests", got-seen)
+	}
+	if err = aln.UpdateLiveNodes(); err == nil {
+		t.Fatalf("expected UpdateLiveNodes to fail after Close")
+	}
+}
+
+type warnRecorder struct {
+	logx.Noop
+	warnings atomic.Int64
+}
+
+func (r *warnRecorder) Warn(string, ...logx.Attr) {
+	r.warnings.Add(1)
+}
+
+func TestAlternatorLiveNodesLifecycle(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`["127.0.0.1"]`))
+	}))
+	t.Cleanup(srv.Close)
+	_, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	logger := &warnRecorder{}
+	aln, err := shared.NewAlternatorLiveNodes(
+		[]string{"127.0.0.1"},
+		shared.WithALNPort(port),
+		shared.WithALNIdleUpdatePeriod(5*time.Millisecond),
+		shared.WithALNLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("failed to create AlternatorLiveNodes: %v", err)
+	}
+	expectState := func(expected shared.LifecycleState) {
+		t.Helper()
+		if state := aln.State(); state != expected {
+			t.Fatalf("expected state %s, got %s", expected, state)
+		}
+	}
+	waitForRequests := func() {
+		t.Helper()
+		seen := requests.Load()
+		deadline := time.Now().Add(5 * time.Second)
+		for requests.Load() == seen {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected node discovery to be running")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	expectNoRequests := func() {
+		t.Helper()
+		// Let the routine that could be in the middle of an update finish it
+		time.Sleep(20 * time.Millisecond)
+		seen := requests.Load()
+		time.Sleep(50 * time.Millisecond)
+		if got := requests.Load(); got != seen {
+			t.Fatalf("expected node discovery to be stopped, got %d more requests", got-seen)
+		}
+	}
+
+	expectState(shared.StateNew)
+	aln.NextNode()
+	expectState(shared.StateRunning)
+	waitForRequests()
+
+	aln.Stop()
+	expectState(shared.StateStopped)
+	aln.NextNode()
+	aln.NextNode()
+	expectState(shared.StateStopped)
+	if got := logger.warnings.Load(); got != 1 {
+		t.Fatalf("expected one warning about NextNode called on stopped instance, got %d", got)
+	}
+	expectNoRequests()
+
+	aln.Start()
+	expectState(shared.StateRunning)
+	waitForRequests()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = aln.Close(ctx); err != nil {
+		t.Fatalf("Close unexpectedly failed: %v", err)
+	}
+	aln.Start()
+	expectState(shared.StateClosed)
+	expectNoRequests()
+	if err = aln.UpdateLiveNodes(); !errors.Is(err, shared.ErrClosed) {
+		t.Fatalf("expected UpdateLiveNodes to fail with ErrClosed after Close, got %v", err)
+	}
+	if _, err = aln.WaitForReady(ctx, shared.ReadyOptions{}); !errors.Is(err, shared.ErrClosed) {
+		t.Fatalf("expected WaitForReady to fail with ErrClosed after Close, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+const defaultReadyRetryInterval = time.Second
+
+// ReadyOptions configures `WaitForReady`
+type ReadyOptions struct {
+	// CheckRackAndDatacenter runs `CheckIfRackAndDatacenterSetCorrectly` once nodes are discovered
+	CheckRackAndDatacenter bool
+	// MinNodes minimal number of live nodes required in the configured routing scope,
+	//	it fails if nodes are found only in one of fallback scopes, 0 disables the check
+	MinNodes int
+	// RetryInterval a time to wait between attempts, 0 means one second
+	RetryInterval time.Duration
+}
+
+// SeedError describes a seed node that failed to return list of nodes
+type SeedError struct {
+	Seed url.URL
+	Err  error
+}
+
+func (e SeedError) Error() string {
+	return fmt.Sprintf("seed %s: %v", e.Seed.Host, e.Err)
+}
+
+func (e SeedError) Unwrap() error {
+	return e.Err
+}
+
+// ReadyReport is a result of `WaitForReady`, it describes the last attempt
+type ReadyReport struct {
+	// Nodes live nodes discovered
+	Nodes []url.URL
+	// Scope a scope tier nodes were discovered in, nil if none were found
+	Scope rt.Scope
+	// PrimaryScope reports whether Scope is the configured routing scope rather than one of its fallbacks
+	PrimaryScope bool
+	// FailedSeeds seed nodes that could not be reached or returned an error
+	FailedSeeds []SeedError
+	// Attempts number of attempts made
+	Attempts int
+}
+
+// WaitForReady queries every seed node for the list of live nodes, updating list of nodes with the result,
+//
+//	and then runs checks requested by opts, attempts are repeated until they pass or ctx is done
+func (aln *AlternatorLiveNodes) WaitForReady(ctx context.Context, opts ReadyOptions) (ReadyReport, error) {
+	return aln.waitForReady(ctx, aln.cfg.RoutingScope, opts)
+}
+
+func (aln *AlternatorLiveNodes) waitForReady(
+	ctx context.Context,
+	scope rt.Scope,
+	opts ReadyOptions,
+) (ReadyReport, error) {
+	if aln.State() == StateClosed {
+		return ReadyReport{}, ErrClosed
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = defaultReadyRetryInterval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(aln.closeCtx, cancel)
+	defer stop()
+
+	var report ReadyReport
+	for {
+		report.Attempts++
+		err := aln.checkReady(ctx, scope, opts, &report)
+		if err == nil {
+			return report, nil
+		}
+		if ctx.Err() != nil {
+			return report, fmt.Errorf("alternator cluster is not ready: %w", err)
+		}
+		aln.cfg.Logger.Warn(fmt.Sprintf("alternator cluster is not ready, retrying: %v", err))
+
+		t := time.NewTimer(opts.RetryInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return report, fmt.Errorf("alternator cluster is not ready: %w", err)
+		case <-t.C:
+		}
+	}
+}
+
+func (aln *AlternatorLiveNodes) checkReady(
+	ctx context.Context,
+	scope rt.Scope,
+	opts ReadyOptions,
+	report *ReadyReport,
+) error {
+	nodes, tier, failed := aln.probeSeeds(ctx, scope)
+	report.Nodes, report.Scope, report.FailedSeeds = nodes, tier, failed
+	report.PrimaryScope = tier != nil && scopeKey(tier) == scopeKey(scope)
+
+	if len(failed) == len(aln.initialNodes) {
+		errs := make([]error, len(failed))
+		for i, seedErr := range failed {
+			errs[i] = seedErr
+		}
+		return fmt.Errorf("none of the seed nodes returned list of nodes: %w", errors.Join(errs...))
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no live nodes found in scope %s or its fallbacks", scope.String())
+	}
+	aln.storeNodes(scope, nodes, tier)
+
+	if opts.CheckRackAndDatacenter {
+		if err := aln.checkRackAndDatacenter(scope); err != nil {
+			return err
+		}
+	}
+	if opts.MinNodes > 0 {
+		primary := 0
+		if report.PrimaryScope {
+			primary = len(nodes)
+		}
+		if primary < opts.MinNodes {
+			return fmt.Errorf(
+				"scope %s has %d live nodes, at least %d required",
+				scope.String(), primary, opts.MinNodes,
+			)
+		}
+	}
+	return nil
+}
+
+// probeSeeds concurrently reads list of nodes from every seed node, returns result of the first seed,
+//
+//	in order seeds are configured, that responded along with seeds that failed
+func (aln *AlternatorLiveNodes) probeSeeds(ctx context.Context, scope rt.Scope) ([]url.URL, rt.Scope, []SeedError) {
+	type result struct {
+		nodes []url.URL
+		scope rt.Scope
+		err   error
+	}
+	results := make([]result, len(aln.initialNodes))
+	var wg sync.WaitGroup
+	for i, seed := range aln.initialNodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nodes, tier, err := aln.discoverNodesFrom(ctx, seed, scope)
+			results[i] = result{nodes: nodes, scope: tier, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var (
+		nodes  []url.URL
+		tier   rt.Scope
+		found  bool
+		failed []SeedError
+	)
+	for i, res := range results {
+		if res.err != nil {
+			failed = append(failed, SeedError{Seed: aln.initialNodes[i], Err: res.err})
+			continue
+		}
+		if !found {
+			nodes, tier, found = res.nodes, res.scope, true
+		}
+	}
+	return nodes, tier, failed
+}
@@ -0,0 +1,123 @@
+package shared_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/alternatortest"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+func TestWaitForReady(t *testing.T) {
+	t.Parallel()
+
+	tcases := []struct {
+		name          string
+		scope         rt.Scope
+		opts          shared.ReadyOptions
+		downSeeds     []int
+		expectErr     bool
+		expectNodes   int
+		expectScope   string
+		expectPrimary bool
+		expectFailed  int
+	}{
+		{
+			name:          "Rack",
+			scope:         rt.NewRackScope("dc1", "rack1", nil),
+			opts:          shared.ReadyOptions{CheckRackAndDatacenter: true, MinNodes: 2},
+			expectNodes:   2,
+			expectScope:   rt.NewRackScope("dc1", "rack1", nil).String(),
+			expectPrimary: true,
+		},
+		{
+			name:          "DeadSeed",
+			scope:         rt.NewClusterScope(),
+			opts:          shared.ReadyOptions{MinNodes: 2},
+			downSeeds:     []int{0},
+			expectNodes:   2,
+			expectScope:   rt.NewClusterScope().String(),
+			expectPrimary: true,
+			expectFailed:  1,
+		},
+		{
+			name:        "FallbackScope",
+			scope:       rt.NewRackScope("dc1", "wrongRack", rt.NewDCScope("dc1", nil)),
+			opts:        shared.ReadyOptions{MinNodes: 1},
+			expectErr:   true,
+			expectNodes: 3,
+			expectScope: rt.NewDCScope("dc1", nil).String(),
+		},
+		{
+			name:      "WrongDatacenter",
+			scope:     rt.NewDCScope("wrongDC", nil),
+			opts:      shared.ReadyOptions{CheckRackAndDatacenter: true},
+			expectErr: true,
+		},
+		{
+			name:         "AllSeedsDown",
+			scope:        rt.NewClusterScope(),
+			downSeeds:    []int{0, 1, 2},
+			expectErr:    true,
+			expectFailed: 3,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+				{Datacenter: "dc1", Rack: "rack1"},
+				{Datacenter: "dc1", Rack: "rack1"},
+				{Datacenter: "dc1", Rack: "rack2"},
+			})
+			for _, idx := range tc.downSeeds {
+				cluster.Node(idx).SetDown(true)
+			}
+
+			aln, err := shared.NewAlternatorLiveNodes(
+				cluster.Hosts(),
+				shared.WithALNPort(cluster.Port()),
+				shared.WithALNRoutingScope(tc.scope),
+				shared.WithALNLogger(logx.Noop{}),
+			)
+			if err != nil {
+				t.Fatalf("failed to create AlternatorLiveNodes: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			tc.opts.RetryInterval = 50 * time.Millisecond
+			report, err := aln.WaitForReady(ctx, tc.opts)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error: %t, got %v", tc.expectErr, err)
+			}
+			if tc.expectErr && report.Attempts < 2 {
+				t.Errorf("expected WaitForReady to retry until context is done, got %d attempts", report.Attempts)
+			}
+			if len(report.Nodes) != tc.expectNodes {
+				t.Errorf("expected %d nodes, got %v", tc.expectNodes, report.Nodes)
+			}
+			if tc.expectScope != "" && (report.Scope == nil || report.Scope.String() != tc.expectScope) {
+				t.Errorf("expected scope %s, got %v", tc.expectScope, report.Scope)
+			}
+			if report.PrimaryScope != tc.expectPrimary {
+				t.Errorf("expected primary scope: %t, got %t", tc.expectPrimary, report.PrimaryScope)
+			}
+			if len(report.FailedSeeds) != tc.expectFailed {
+				t.Errorf("expected %d failed seeds, got %v", tc.expectFailed, report.FailedSeeds)
+			}
+			for _, seedErr := range report.FailedSeeds {
+				if seedErr.Seed.Hostname() != cluster.Node(0).Host() && len(tc.downSeeds) == 1 {
+					t.Errorf("expected only seed %s to fail, got %v", cluster.Node(0).Host(), seedErr)
+				}
+			}
+			if err == nil && len(aln.GetNodes()) != tc.expectNodes {
+				t.Errorf("expected list of nodes to be updated, got %v", aln.GetNodes())
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrClosed is returned by requests sent through http clients of a closed helper
+var ErrClosed error = closedError{}
+
+type closedError struct{}
+
+func (closedError) Error() string {
+	return "alternator helper is closed"
+}
+
+// RetryableError tells AWS SDK v2 that request should not be retried
+func (closedError) RetryableError() bool {
+	return false
+}
+
+// Temporary tells AWS SDK v1 that request should not be retried
+func (closedError) Temporary() bool {
+	return false
+}
+
+// RequestTracker keeps track of http transports created by a helper and of requests in flight through them,
+//
+//	so that on shutdown requests could be drained and idle connections closed
+type RequestTracker struct {
+	lock       sync.Mutex
+	inFlight   int
+	closed     bool
+	drained    chan struct{}
+	transports map[*http.Transport]struct{}
+	closers    []func(ctx context.Context) error
+}
+
+// NewRequestTracker creates new `RequestTracker`
+func NewRequestTracker() *RequestTracker {
+	return &RequestTracker{
+		drained:    make(chan struct{}),
+		transports: map[*http.Transport]struct{}{},
+	}
+}
+
+// Track returns transport that counts requests in flight, once tracker is closed it fails requests with `ErrClosed`,
+//
+//	underlying `http.Transport` is remembered once, no matter how many clients share it, to close its idle connections
+func (t *RequestTracker) Track(transport http.RoundTripper) http.RoundTripper {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if tr, ok := UnwrapHTTPTransport(transport); ok && !t.closed {
+		t.transports[tr] = struct{}{}
+	}
+	return &trackingTransport{
+		transport: transport,
+		tracker:   t,
+	}
+}
+
+// OnClose registers a function to be called when tracker is closed, after requests are drained
+func (t *RequestTracker) OnClose(fn func(ctx context.Context) error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.closers = append(t.closers, fn)
+}
+
+// Close makes tracked transports reject new requests, waits for requests in flight if `drain` is true,
+//
+//	calls functions registered with `OnClose` and closes idle connections of tracked transports.
+//	It is safe to call it multiple times and concurrently, it returns `ctx.Err()` if ctx is done before draining ends
+func (t *RequestTracker) Close(ctx context.Context, drain bool) error {
+	t.lock.Lock()
+	if !t.closed {
+		t.closed = true
+		if t.inFlight == 0 {
+			close(t.drained)
+		}
+	}
+	closers := t.closers
+	t.lock.Unlock()
+
+	if drain {
+		select {
+		case <-t.drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var errs []error
+	for _, fn := range closers {
+		errs = append(errs, fn(ctx))
+	}
+	// Transports are dropped once their idle connections are closed, requests through them are rejected anyway
+	t.lock.Lock()
+	transports := t.transports
+	t.transports = map[*http.Transport]struct{}{}
+	t.lock.Unlock()
+	for transport := range transports {
+		transport.CloseIdleConnections()
+	}
+	return errors.Join(errs...)
+}
+
+func (t *RequestTracker) acquire() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return false
+	}
+	t.inFlight++
+	return true
+}
+
+func (t *RequestTracker) release() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.inFlight--
+	if t.closed && t.inFlight == 0 {
+		close(t.drained)
+	}
+}
+
+type trackingTransport struct {
+	transport http.RoundTripper
+	tracker   *RequestTracker
+}
+
+func (t *trackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.tracker.acquire() {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, ErrClosed
+	}
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		t.tracker.release()
+		return resp, err
+	}
+	// Request is in flight until its body is closed
+	resp.Body = &trackedBody{ReadCloser: resp.Body, release: sync.OnceFunc(t.tracker.release)}
+	return resp, nil
+}
+
+// Unwrap returns underlying transport
+func (t *trackingTransport) Unwrap() http.RoundTripper {
+	return t.transport
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
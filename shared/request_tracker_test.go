@@ -0,0 +1,78 @@
+package shared_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+func TestRequestTracker(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Run("Drain", func(t *testing.T) {
+		t.Parallel()
+		tracker := shared.NewRequestTracker()
+		client := &http.Client{Transport: tracker.Track(shared.DefaultHTTPTransport())}
+
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+
+		closed := make(chan error, 1)
+		go func() {
+			closed <- tracker.Close(context.Background(), true)
+		}()
+		select {
+		case err = <-closed:
+			t.Fatalf("Close returned while request is in flight: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		if _, err = client.Get(srv.URL); !errors.Is(err, shared.ErrClosed) {
+			t.Fatalf("expected ErrClosed for request sent after Close, got %v", err)
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if err = <-closed; err != nil {
+			t.Fatalf("Close unexpectedly failed: %v", err)
+		}
+		if err = tracker.Close(context.Background(), true); err != nil {
+			t.Fatalf("repeated Close unexpectedly failed: %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		t.Parallel()
+		tracker := shared.NewRequestTracker()
+		client := &http.Client{Transport: tracker.Track(shared.DefaultHTTPTransport())}
+
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+		defer resp.Body.Close() //nolint: errcheck // no need to check
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err = tracker.Close(ctx, true); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected Close to time out, got %v", err)
+		}
+		// Requests in flight are not waited for without draining
+		if err = tracker.Close(context.Background(), false); err != nil {
+			t.Fatalf("Close unexpectedly failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package shared
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+// RootCASource an interface that provides http clients with root CAs to verify server certificates against,
+//
+//	nil pool means system roots
+type RootCASource interface {
+	GetRootCAs(logx.Logger) (*x509.CertPool, error)
+}
+
+// RootCAFileSource serves root CAs from a PEM file, the file is read again when it changes
+type RootCAFileSource struct {
+	path    string
+	pool    *x509.CertPool
+	mutex   sync.Mutex
+	modTime time.Time
+}
+
+// NewRootCAFile creates new instance of `RootCAFileSource` to serve root CAs from a PEM file
+func NewRootCAFile(path string) *RootCAFileSource {
+	return &RootCAFileSource{
+		path: path,
+	}
+}
+
+// GetRootCAs implementation of `RootCASource` that serves root CAs from a file
+func (s *RootCAFileSource) GetRootCAs(log logx.Logger) (*x509.CertPool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stat, err := os.Stat(s.path)
+	if err != nil {
+		err = fmt.Errorf("failed to stat root CA file %s: %w", s.path, err)
+		if s.pool != nil {
+			log.Error(err.Error())
+			return s.pool, nil
+		}
+		return nil, err
+	}
+
+	if s.pool != nil && stat.ModTime().Equal(s.modTime) {
+		return s.pool, nil // Return cached pool if unchanged
+	}
+
+	pool, err := loadRootCAFile(s.path)
+	if err != nil {
+		if s.pool != nil {
+			log.Error(err.Error())
+			return s.pool, nil
+		}
+		return nil, err
+	}
+
+	s.pool = pool
+	s.modTime = stat.ModTime()
+	return s.pool, nil
+}
+
+func loadRootCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root CA file %s: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("failed to load root CA file %s: no certificates found", path)
+	}
+	return pool, nil
+}
+
+// RootCAPoolSource serves provided root CAs to a http client
+type RootCAPoolSource struct {
+	pool *x509.CertPool
+}
+
+// NewRootCAs returns a new instance of `RootCAPoolSource` that serves provided pool
+func NewRootCAs(pool *x509.CertPool) *RootCAPoolSource {
+	return &RootCAPoolSource{
+		pool: pool,
+	}
+}
+
+// GetRootCAs implementation of `RootCASource` that serves provided pool
+func (s *RootCAPoolSource) GetRootCAs(_ logx.Logger) (*x509.CertPool, error) {
+	return s.pool, nil
+}
@@ -0,0 +1,76 @@
+package shared
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+// RoutingInfo describes how a request was routed
+type RoutingInfo struct {
+	// Node that served last attempt of the request
+	Node url.URL
+	// Attempts number of attempts made, every attempt picks a node
+	Attempts int
+	// Scope routing scope tier node was picked from, e.g. fallback scope when preferred one had no nodes,
+	//  nil when node was pinned via `WithNode` or taken from initial list of nodes
+	Scope rt.Scope
+}
+
+// RoutingRecorder collects `RoutingInfo` of a request
+type RoutingRecorder struct {
+	lock sync.Mutex
+	info RoutingInfo
+}
+
+type routingRecorderContextKey struct{}
+
+// WithRoutingRecorder returns a context that collects routing info of requests performed with it
+func WithRoutingRecorder(ctx context.Context) (context.Context, *RoutingRecorder) {
+	rec := &RoutingRecorder{}
+	return context.WithValue(ctx, routingRecorderContextKey{}, rec), rec
+}
+
+// RoutingRecorderFromContext returns recorder set by `WithRoutingRecorder`, nil if there is none
+func RoutingRecorderFromContext(ctx context.Context) *RoutingRecorder {
+	rec, _ := ctx.Value(routingRecorderContextKey{}).(*RoutingRecorder)
+	return rec
+}
+
+// Record registers an attempt to send request to the node, no-op on nil recorder
+func (r *RoutingRecorder) Record(node url.URL, scope rt.Scope) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.info.Node = node
+	r.info.Scope = scope
+	r.info.Attempts++
+}
+
+// Info returns routing info collected so far, second value is false if no attempt was recorded
+func (r *RoutingRecorder) Info() (RoutingInfo, bool) {
+	if r == nil {
+		return RoutingInfo{}, false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.info, r.info.Attempts != 0
+}
+
+// RoutingError wraps an error of a request along with its routing info
+type RoutingError struct {
+	Err  error
+	Info RoutingInfo
+}
+
+func (e *RoutingError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RoutingError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,296 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+// scopedNodes holds list of nodes for a routing scope that differs from the one `AlternatorLiveNodes` is configured with
+//
+//	lists are loaded by background refresher once they are used and then kept up to date by the same refresh
+//	as main list of nodes
+type scopedNodes struct {
+	scope   rt.Scope
+	nodes   atomic.Pointer[nodeList]
+	nextIdx atomic.Uint64
+	// loadLock serializes loads of the list, so that a list loaded by one of them is not loaded again by the other
+	loadLock sync.Mutex
+	loaded   atomic.Bool
+	// listeners are called with nodes that appear in the list after it is updated
+	listenersLock sync.Mutex
+	listeners     []func([]url.URL)
+}
+
+// nodeList is a list of nodes along with scope tier they were discovered in
+type nodeList struct {
+	nodes []url.URL
+	scope rt.Scope
+}
+
+// scopeKey identifies scope along with its fallback chain
+func scopeKey(scope rt.Scope) string {
+	var parts []string
+	for ; scope != nil; scope = scope.Fallback() {
+		parts = append(parts, scope.String())
+	}
+	return strings.Join(parts, "->")
+}
+
+// NextNodeForContext returns node set by `WithNode`, or next node of the scope set by `WithScope`,
+//
+//	or, if neither is set, the same node as `NextNode`, chosen node is recorded by `RoutingRecorder` of the context
+func (aln *AlternatorLiveNodes) NextNodeForContext(ctx context.Context) url.URL {
+	node, scope := aln.nextNodeForContext(ctx)
+	RoutingRecorderFromContext(ctx).Record(node, scope)
+	return node
+}
+
+func (aln *AlternatorLiveNodes) nextNodeForContext(ctx context.Context) (url.URL, rt.Scope) {
+	if node, ok := NodeFromContext(ctx); ok {
+		return node, nil
+	}
+	scope, ok := ScopeFromContext(ctx)
+	if policy, set := BalancingPolicyFromContext(ctx); set {
+		if !ok {
+			scope = aln.cfg.RoutingScope
+		}
+		nodes, tier := aln.nodesForScope(scope)
+		return policy(nodes), tier
+	}
+	if ok {
+		return aln.nextNodeForScope(scope)
+	}
+	return aln.NextNode(), aln.liveNodesScope()
+}
+
+// NextNodeForScope returns next node of the given routing scope, list of nodes for the scope is discovered
+//
+//	in background after first call and then kept up to date the same way as main list of nodes,
+//	until it is discovered nodes of the main list are returned
+func (aln *AlternatorLiveNodes) NextNodeForScope(scope rt.Scope) url.URL {
+	node, _ := aln.nextNodeForScope(scope)
+	return node
+}
+
+func (aln *AlternatorLiveNodes) nextNodeForScope(scope rt.Scope) (url.URL, rt.Scope) {
+	key := scopeKey(scope)
+	if key == scopeKey(aln.cfg.RoutingScope) {
+		return aln.NextNode(), aln.liveNodesScope()
+	}
+
+	sn := aln.scopedNodesEntry(scope)
+	aln.ensureRunning()
+	aln.triggerUpdate()
+
+	list := sn.nodes.Load()
+	if list == nil || len(list.nodes) == 0 {
+		aln.warnNoScopedNodes(sn)
+		return aln.NextNode(), aln.liveNodesScope()
+	}
+	return list.nodes[sn.nextIdx.Add(1)%uint64(len(list.nodes))], list.scope
+}
+
+// nodesForScope returns list of nodes of the scope, along with scope tier they belong to, the same way
+//
+//	nextNodeForScope does, list must not be modified
+func (aln *AlternatorLiveNodes) nodesForScope(scope rt.Scope) ([]url.URL, rt.Scope) {
+	key := scopeKey(scope)
+	if key != scopeKey(aln.cfg.RoutingScope) {
+		sn := aln.scopedNodesEntry(scope)
+		if list := sn.nodes.Load(); list != nil && len(list.nodes) != 0 {
+			return list.nodes, list.scope
+		}
+		aln.warnNoScopedNodes(sn)
+	}
+	aln.ensureRunning()
+	aln.triggerUpdate()
+	nodes := *aln.liveNodes.Load()
+	if len(nodes) == 0 {
+		nodes = aln.initialNodes
+	}
+	return nodes, aln.liveNodesScope()
+}
+
+// warnNoScopedNodes warns that nodes of the main list are used instead of nodes of the scope,
+//
+//	list that is not loaded yet is expected to be empty, so it is not warned about
+func (aln *AlternatorLiveNodes) warnNoScopedNodes(sn *scopedNodes) {
+	if sn.loaded.Load() {
+		aln.cfg.Logger.Warn(
+			"no nodes known for routing scope, falling back to default one",
+			logx.A("scope", scopeKey(sn.scope)),
+		)
+	}
+}
+
+// scopedNodesEntry returns list of nodes of the scope, shared by all users of the scope,
+//
+//	new list is loaded by background refresher, along with locations of nodes
+func (aln *AlternatorLiveNodes) scopedNodesEntry(scope rt.Scope) *scopedNodes {
+	key := scopeKey(scope)
+	if v, ok := aln.scopedNodes.Load(key); ok {
+		return v.(*scopedNodes)
+	}
+	v, loaded := aln.scopedNodes.LoadOrStore(key, &scopedNodes{scope: scope})
+	if !loaded {
+		aln.wantLocations()
+		select {
+		case aln.loadSignal <- struct{}{}:
+		default:
+		}
+		aln.ensureRunning()
+	}
+	return v.(*scopedNodes)
+}
+
+// readStaleLocations reads node locations from system tables if they are needed and not read yet
+func (aln *AlternatorLiveNodes) readStaleLocations() {
+	if !aln.locationsStale.Swap(false) {
+		return
+	}
+	if err := aln.updateLocations(aln.closeCtx); err != nil {
+		// Locations learned from scoped queries are used instead,
+		// tables are read again once nodes of unknown location show up
+		aln.cfg.Logger.Debug(fmt.Errorf("failed to read node locations from system tables: %w", err).Error())
+	}
+}
+
+// loadScopedNodes loads lists of scopes that are not loaded yet, lists share responses of "/localnodes" queries,
+//
+//	node locations the lists asked for are read as well
+func (aln *AlternatorLiveNodes) loadScopedNodes() {
+	aln.readStaleLocations()
+	pass := &refreshPass{aln: aln, responses: map[string][]url.URL{}}
+	aln.scopedNodes.Range(func(_, v any) bool {
+		sn := v.(*scopedNodes)
+		if sn.loaded.Load() {
+			return true
+		}
+		if err := aln.loadScopedNodesWith(sn, pass.query, false); err != nil {
+			aln.cfg.Logger.Error(
+				fmt.Errorf("failed to read list of nodes for routing scope: %w", err).Error(),
+				logx.A("scope", scopeKey(sn.scope)),
+			)
+		}
+		return true
+	})
+}
+
+// refreshScopedNodes reads list of nodes of the scope right away, even if it is loaded already
+func (aln *AlternatorLiveNodes) refreshScopedNodes(sn *scopedNodes) error {
+	return aln.loadScopedNodesWith(sn, aln.queryNodes, true)
+}
+
+// loadScopedNodesWith reads list of nodes of the scope, list that is loaded already is read again only if `reload` is set,
+//
+//	list is marked loaded even if it can't be read, so that it is not loaded again until next refresh
+func (aln *AlternatorLiveNodes) loadScopedNodesWith(sn *scopedNodes, query nodesQuery, reload bool) error {
+	sn.loadLock.Lock()
+	defer sn.loadLock.Unlock()
+	if !reload && sn.loaded.Load() {
+		return nil
+	}
+	defer sn.loaded.Store(true)
+	return aln.refreshScopedNodesWith(sn, query)
+}
+
+func (aln *AlternatorLiveNodes) refreshScopedNodesWith(sn *scopedNodes, query nodesQuery) error {
+	nodes, tier, err := discoverScope(sn.scope, query)
+	if err != nil {
+		return err
+	}
+	if len(nodes) != 0 {
+		aln.setScopedNodes(sn, nodes, tier)
+	}
+	return nil
+}
+
+func (aln *AlternatorLiveNodes) setScopedNodes(sn *scopedNodes, nodes []url.URL, tier rt.Scope) {
+	var oldNodes []url.URL
+	if old := sn.nodes.Swap(&nodeList{nodes: nodes, scope: tier}); old != nil {
+		oldNodes = old.nodes
+	}
+	aln.checkLocations(addedNodes(oldNodes, nodes))
+	sn.listenersLock.Lock()
+	listeners := slices.Clone(sn.listeners)
+	sn.listenersLock.Unlock()
+	notifyAddedNodes(listeners, oldNodes, nodes)
+}
+
+// storeNodes stores nodes discovered for the scope into the main list of nodes, if scope is the configured one,
+//
+//	or into the list of the scope otherwise
+func (aln *AlternatorLiveNodes) storeNodes(scope rt.Scope, nodes []url.URL, tier rt.Scope) {
+	if scopeKey(scope) == scopeKey(aln.cfg.RoutingScope) {
+		aln.setLiveNodes(nodes, tier)
+		return
+	}
+	sn := aln.scopedNodesEntry(scope)
+	sn.loadLock.Lock()
+	defer sn.loadLock.Unlock()
+	aln.setScopedNodes(sn, nodes, tier)
+	sn.loaded.Store(true)
+}
+
+// liveNodesScope returns scope tier main list of nodes was discovered in, nil until first discovery
+func (aln *AlternatorLiveNodes) liveNodesScope() rt.Scope {
+	if list := aln.liveScope.Load(); list != nil {
+		return list.scope
+	}
+	return nil
+}
+
+// refresh updates main list of nodes and lists of all scopes in use in a single pass, lists share responses
+//
+//	of "/localnodes" queries made during the pass, node locations are read from system tables once they are needed,
+//	see `wantLocations`, and again once nodes of unknown location show up, returns error of main list update
+func (aln *AlternatorLiveNodes) refresh() error {
+	aln.nextUpdate.Store(time.Now().UTC().Unix() + int64(aln.cfg.UpdatePeriod.Seconds()))
+	aln.readStaleLocations()
+
+	pass := &refreshPass{aln: aln, responses: map[string][]url.URL{}}
+	nodes, tier, err := discoverScope(aln.cfg.RoutingScope, pass.query)
+	if err == nil && len(nodes) != 0 {
+		aln.setLiveNodes(nodes, tier)
+	}
+	aln.scopedNodes.Range(func(_, v any) bool {
+		sn := v.(*scopedNodes)
+		if scopeErr := aln.loadScopedNodesWith(sn, pass.query, true); scopeErr != nil {
+			aln.cfg.Logger.Error(
+				fmt.Errorf("failed to read list of nodes for routing scope: %w", scopeErr).Error(),
+				logx.A("scope", scopeKey(sn.scope)),
+			)
+		}
+		return true
+	})
+	return err
+}
+
+// refreshPass is a single refresh of all lists of nodes, it remembers responses of "/localnodes" queries,
+//
+//	so that every query is made once per pass
+type refreshPass struct {
+	aln       *AlternatorLiveNodes
+	responses map[string][]url.URL
+}
+
+func (p *refreshPass) query(query string) ([]url.URL, error) {
+	if nodes, ok := p.responses[query]; ok {
+		return nodes, nil
+	}
+	nodes, err := p.aln.queryNodes(query)
+	if err != nil {
+		return nil, err
+	}
+	p.responses[query] = nodes
+	return nodes, nil
+}
@@ -0,0 +1,91 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"path"
+	"sync/atomic"
+
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+// BalancingPolicy picks a node to send request to out of non-empty list of nodes of a routing scope,
+//
+//	it is called concurrently
+type BalancingPolicy func(nodes []url.URL) url.URL
+
+// RoundRobin returns a policy that picks nodes in turn
+func RoundRobin() BalancingPolicy {
+	var idx atomic.Uint64
+	return func(nodes []url.URL) url.URL {
+		return nodes[idx.Add(1)%uint64(len(nodes))]
+	}
+}
+
+// Random returns a policy that picks a random node
+func Random() BalancingPolicy {
+	return func(nodes []url.URL) url.URL {
+		return nodes[rand.IntN(len(nodes))]
+	}
+}
+
+// TableRoute describes how requests to tables matching the pattern are routed
+type TableRoute struct {
+	// Pattern a table name or a `path.Match` pattern, e.g. "events_*"
+	Pattern string
+	// Scope a routing scope for the tables, nil means routing scope of the helper
+	Scope rt.Scope
+	// Balancing a policy to pick a node out of nodes of the scope, nil means round-robin over nodes of the scope
+	Balancing BalancingPolicy
+}
+
+// TableRoutes a routing table, the first route that matches table name is used
+type TableRoutes []TableRoute
+
+// Match returns the first route that matches table name
+func (r TableRoutes) Match(table string) (TableRoute, bool) {
+	if table == "" {
+		return TableRoute{}, false
+	}
+	for _, route := range r {
+		if ok, _ := path.Match(route.Pattern, table); ok {
+			return route, true
+		}
+	}
+	return TableRoute{}, false
+}
+
+// Apply returns a context that routes requests to the table according to the matching route,
+//
+//	routing set for the request by `WithScope` or `WithBalancingPolicy` takes precedence
+func (r TableRoutes) Apply(ctx context.Context, table string) context.Context {
+	route, ok := r.Match(table)
+	if !ok {
+		return ctx
+	}
+	if _, set := ScopeFromContext(ctx); !set && route.Scope != nil {
+		ctx = WithScope(ctx, route.Scope)
+	}
+	if _, set := BalancingPolicyFromContext(ctx); !set && route.Balancing != nil {
+		ctx = WithBalancingPolicy(ctx, route.Balancing)
+	}
+	return ctx
+}
+
+// WithTableRoute routes requests to tables matching the pattern to nodes of the scope picked by the policy,
+//
+//	pattern is a table name or a `path.Match` pattern, nil scope or policy mean ones of the helper,
+//	routes are matched in order they are added
+func WithTableRoute(pattern string, scope rt.Scope, policy BalancingPolicy) Option {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Sprintf("invalid table pattern %q: %v", pattern, err))
+	}
+	return func(config *Config) {
+		config.TableRoutes = append(
+			config.TableRoutes[:len(config.TableRoutes):len(config.TableRoutes)],
+			TableRoute{Pattern: pattern, Scope: scope, Balancing: policy},
+		)
+	}
+}
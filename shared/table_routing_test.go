@@ -0,0 +1,65 @@
+package shared_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+func TestTableRoutes(t *testing.T) {
+	t.Parallel()
+
+	rack := rt.NewRackScope("dc1", "rack1", nil)
+	dc := rt.NewDCScope("dc1", nil)
+	cfg := shared.NewDefaultConfig()
+	for _, opt := range []shared.Option{
+		shared.WithTableRoute("users", rack, nil),
+		shared.WithTableRoute("user*", dc, shared.Random()),
+		shared.WithTableRoute("events_?", nil, shared.RoundRobin()),
+	} {
+		opt(cfg)
+	}
+
+	tcases := []struct {
+		table     string
+		expected  rt.Scope
+		balancing bool
+	}{
+		{table: "users", expected: rack},
+		{table: "user_sessions", expected: dc, balancing: true},
+		{table: "events_1", balancing: true},
+		{table: "events_10"},
+		{table: ""},
+	}
+	for _, tc := range tcases {
+		ctx := cfg.TableRoutes.Apply(context.Background(), tc.table)
+		scope, _ := shared.ScopeFromContext(ctx)
+		if scope != tc.expected {
+			t.Errorf("expected table %q to be routed to scope %v, got %v", tc.table, tc.expected, scope)
+		}
+		if _, ok := shared.BalancingPolicyFromContext(ctx); ok != tc.balancing {
+			t.Errorf("expected balancing policy of table %q to be set: %t, got %t", tc.table, tc.balancing, ok)
+		}
+	}
+
+	// Routing set for the request takes precedence over the routing table
+	ctx := shared.WithScope(context.Background(), dc)
+	if scope, _ := shared.ScopeFromContext(cfg.TableRoutes.Apply(ctx, "users")); scope != dc {
+		t.Errorf("expected scope of the request to take precedence, got %v", scope)
+	}
+
+	nodes := []url.URL{{Host: "a"}, {Host: "b"}, {Host: "c"}}
+	policy := shared.RoundRobin()
+	seen := map[string]int{}
+	for range 6 {
+		seen[policy(nodes).Host]++
+	}
+	for _, node := range nodes {
+		if seen[node.Host] != 2 {
+			t.Errorf("expected round-robin to pick every node twice, got %v", seen)
+		}
+	}
+}
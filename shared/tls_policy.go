@@ -0,0 +1,112 @@
+package shared
+
+import (
+	"crypto/tls"
+	"fmt"
+	"slices"
+)
+
+// TLSPolicy restricts TLS protocol versions, cipher suites and key exchange curves http clients are allowed to use,
+//
+//	zero fields are left up to Go defaults.
+//	Note that Go does not allow to configure TLS 1.3 cipher suites, `CipherSuites` affects TLS 1.2 and older only
+type TLSPolicy struct {
+	// Name of the policy, empty for custom policies
+	Name string
+	// MinVersion minimal TLS version, e.g. `tls.VersionTLS13`
+	MinVersion uint16
+	// MaxVersion maximal TLS version
+	MaxVersion uint16
+	// CipherSuites TLS 1.2 cipher suites in order of preference
+	CipherSuites []uint16
+	// CurvePreferences key exchange curves in order of preference
+	CurvePreferences []tls.CurveID
+}
+
+var (
+	// TLSPolicyModern allows TLS 1.3 only
+	TLSPolicyModern = TLSPolicy{
+		Name:       "modern",
+		MinVersion: tls.VersionTLS13,
+	}
+
+	// TLSPolicyCompatible allows TLS 1.2 and 1.3 with forward secret AEAD cipher suites only
+	TLSPolicyCompatible = TLSPolicy{
+		Name:       "compatible",
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+
+	// TLSPolicyFIPS allows TLS 1.2 and 1.3 with FIPS 140 approved cipher suites and curves only,
+	// it does not make Go crypto FIPS validated, see GOFIPS140 for that
+	TLSPolicyFIPS = TLSPolicy{
+		Name:       "fips",
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		},
+		CurvePreferences: []tls.CurveID{tls.CurveP256, tls.CurveP384},
+	}
+)
+
+// TLSPolicyByName returns a preset by its name: modern, compatible or fips
+func TLSPolicyByName(name string) (TLSPolicy, error) {
+	for _, policy := range []TLSPolicy{TLSPolicyModern, TLSPolicyCompatible, TLSPolicyFIPS} {
+		if policy.Name == name {
+			return policy, nil
+		}
+	}
+	return TLSPolicy{}, fmt.Errorf("unknown TLS policy %q", name)
+}
+
+// Override returns a copy of the policy with non-zero fields of `overrides` taking precedence
+func (p TLSPolicy) Override(overrides TLSPolicy) TLSPolicy {
+	out := p
+	if overrides.Name != "" {
+		out.Name = overrides.Name
+	}
+	if overrides.MinVersion != 0 {
+		out.MinVersion = overrides.MinVersion
+	}
+	if overrides.MaxVersion != 0 {
+		out.MaxVersion = overrides.MaxVersion
+	}
+	if overrides.CipherSuites != nil {
+		out.CipherSuites = overrides.CipherSuites
+	}
+	if overrides.CurvePreferences != nil {
+		out.CurvePreferences = overrides.CurvePreferences
+	}
+	return out
+}
+
+// IsZero reports whether the policy leaves everything up to Go defaults
+func (p TLSPolicy) IsZero() bool {
+	return p.MinVersion == 0 && p.MaxVersion == 0 && p.CipherSuites == nil && p.CurvePreferences == nil
+}
+
+// Apply sets policy restrictions to `tls.Config`
+func (p TLSPolicy) Apply(cfg *tls.Config) {
+	if p.MinVersion != 0 {
+		cfg.MinVersion = p.MinVersion
+	}
+	if p.MaxVersion != 0 {
+		cfg.MaxVersion = p.MaxVersion
+	}
+	if p.CipherSuites != nil {
+		cfg.CipherSuites = slices.Clone(p.CipherSuites)
+	}
+	if p.CurvePreferences != nil {
+		cfg.CurvePreferences = slices.Clone(p.CurvePreferences)
+	}
+}
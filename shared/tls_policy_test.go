@@ -0,0 +1,118 @@
+package shared_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+func newTLS12TestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTLSPolicy(t *testing.T) {
+	t.Parallel()
+
+	srv := newTLS12TestServer(t)
+
+	tcases := []struct {
+		name            string
+		opts            []shared.Option
+		expectedVersion uint16
+		expectedError   bool
+	}{
+		{name: "Default", expectedVersion: tls.VersionTLS12},
+		{name: "Modern", opts: []shared.Option{shared.WithTLSPolicy(shared.TLSPolicyModern)}, expectedError: true},
+		{
+			name:            "Compatible",
+			opts:            []shared.Option{shared.WithTLSPolicy(shared.TLSPolicyCompatible)},
+			expectedVersion: tls.VersionTLS12,
+		},
+		{name: "FIPS", opts: []shared.Option{shared.WithTLSPolicy(shared.TLSPolicyFIPS)}, expectedVersion: tls.VersionTLS12},
+		{
+			name: "Compatible/MinVersionOverride",
+			opts: []shared.Option{
+				shared.WithTLSMinVersion(tls.VersionTLS13),
+				shared.WithTLSPolicy(shared.TLSPolicyCompatible),
+			},
+			expectedError: true,
+		},
+		{
+			name: "FIPS/CipherSuitesOverride",
+			opts: []shared.Option{
+				shared.WithTLSPolicy(shared.TLSPolicyFIPS),
+				shared.WithTLSCipherSuites(tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256),
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := shared.NewDefaultConfig()
+			for _, opt := range append(tc.opts, shared.WithIgnoreServerCertificateError(true)) {
+				opt(cfg)
+			}
+
+			// Same policy has to be applied to both Alternator and DynamoDB clients
+			alternatorClient := &http.Client{Transport: shared.NewHTTPTransport(cfg.ToALNConfig())}
+			dynamoClient := &http.Client{}
+			if err := shared.PatchHTTPClient(*cfg, dynamoClient); err != nil {
+				t.Fatalf("failed to patch http client: %v", err)
+			}
+
+			for _, client := range []*http.Client{alternatorClient, dynamoClient} {
+				resp, err := client.Get(srv.URL)
+				client.CloseIdleConnections()
+				if tc.expectedError {
+					if err == nil {
+						_ = resp.Body.Close()
+						t.Fatalf("request should have failed")
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("request unexpectedly failed: %v", err)
+				}
+				_ = resp.Body.Close()
+				if resp.TLS.Version != tc.expectedVersion {
+					t.Fatalf("expected %s, got %s", tls.VersionName(tc.expectedVersion), tls.VersionName(resp.TLS.Version))
+				}
+			}
+		})
+	}
+}
+
+func TestTLSPolicyByName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"modern", "compatible", "fips"} {
+		policy, err := shared.TLSPolicyByName(name)
+		if err != nil {
+			t.Fatalf("failed to get policy %s: %v", name, err)
+		}
+		if policy.Name != name {
+			t.Fatalf("expected policy %s, got %s", name, policy.Name)
+		}
+	}
+	if _, err := shared.TLSPolicyByName("legacy"); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+
+	policy := shared.TLSPolicyFIPS.Override(shared.TLSPolicy{MinVersion: tls.VersionTLS13})
+	if policy.MinVersion != tls.VersionTLS13 || !slices.Equal(policy.CipherSuites, shared.TLSPolicyFIPS.CipherSuites) {
+		t.Fatalf("unexpected policy after override: %+v", policy)
+	}
+}
@@ -0,0 +1,197 @@
+package shared
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+// SANVerifier verifies that certificate chain of which is already verified belongs to the node,
+//
+//	`node` is an address of the node, `serverName` is either the same address or name set by `WithTLSServerName`
+type SANVerifier func(node, serverName string, leaf *x509.Certificate) error
+
+var (
+	// VerifyServerName requires certificate to be valid for the server name, default strategy
+	VerifyServerName SANVerifier = func(_, serverName string, leaf *x509.Certificate) error {
+		return leaf.VerifyHostname(serverName)
+	}
+
+	// VerifyNodeAddress requires certificate to be valid for the address of the node, even if server name is set
+	VerifyNodeAddress SANVerifier = func(node, _ string, leaf *x509.Certificate) error {
+		return leaf.VerifyHostname(node)
+	}
+
+	// VerifyChainOnly only verifies certificate chain, any SANs are accepted
+	VerifyChainOnly SANVerifier = func(_, _ string, _ *x509.Certificate) error {
+		return nil
+	}
+)
+
+// VerifyAnyName requires certificate to be valid for at least one of the names, e.g. a cluster-wide wildcard name
+func VerifyAnyName(names ...string) SANVerifier {
+	return func(_, _ string, leaf *x509.Certificate) error {
+		var errs []error
+		for _, name := range names {
+			err := leaf.VerifyHostname(name)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return fmt.Errorf("certificate is not valid for any of %v: %w", names, errors.Join(errs...))
+	}
+}
+
+// patchTLSVerification makes transport verify server certificates against root CAs from `RootCASource`,
+//
+//	check their SANs with `SANVerifier`, pinned SPKI hashes and custom `VerifyPeerCertificateFunc`,
+//	it is done per connection, so that rotated root CAs take effect and node address is known to the verifiers.
+//	`IgnoreServerCertificateError` skips chain and SAN verification only
+func patchTLSVerification(config ALNConfig, transport *http.Transport) {
+	customized := config.RootCASource != nil || config.TLSServerName != "" || config.SANVerifier != nil
+	if config.IgnoreServerCertificateError {
+		customized = false
+	}
+	if !customized && len(config.CertificatePins) == 0 && config.VerifyPeerCertificate == nil {
+		return
+	}
+
+	verifier := config.SANVerifier
+	if verifier == nil {
+		verifier = VerifyServerName
+	}
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{
+			Timeout:   defaultDialTimeout,
+			KeepAlive: defaultDialKeepAlive,
+		}).DialContext
+	}
+
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		node, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		serverName := node
+		if config.TLSServerName != "" {
+			serverName = config.TLSServerName
+		}
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		// transport.TLSClientConfig carries settings applied by other options and HTTP/2 negotiation
+		cfg := transport.TLSClientConfig.Clone()
+		cfg.ServerName = serverName
+		// Verification is done by VerifyConnection
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = nil
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyConnection(config, verifier, node, serverName, cs)
+		}
+
+		if transport.TLSHandshakeTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, transport.TLSHandshakeTimeout)
+			defer cancel()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+func verifyConnection(
+	config ALNConfig,
+	verifier SANVerifier,
+	node, serverName string,
+	cs tls.ConnectionState,
+) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: server has not provided a certificate")
+	}
+	config.CertificateExpiryMonitor.Observe(ServerCertificate, node, cs.PeerCertificates[0], config.Logger)
+
+	var verifiedChains [][]*x509.Certificate
+	if !config.IgnoreServerCertificateError {
+		var err error
+		if verifiedChains, err = verifyChain(config, verifier, node, serverName, cs.PeerCertificates); err != nil {
+			return &tls.CertificateVerificationError{UnverifiedCertificates: cs.PeerCertificates, Err: err}
+		}
+	}
+
+	if len(config.CertificatePins) != 0 {
+		// Root CA is not sent by the server, so it could be pinned only when chain is verified
+		chains := [][]*x509.Certificate{cs.PeerCertificates}
+		if len(verifiedChains) != 0 {
+			chains = verifiedChains
+		}
+		if err := verifyPins(node, config.CertificatePins, chains); err != nil {
+			var mismatch *PinMismatchError
+			if errors.As(err, &mismatch) {
+				config.Logger.Error(
+					"certificate pin mismatch",
+					logx.A("node", mismatch.Node),
+					logx.A("expected", mismatch.Expected),
+					logx.A("got", mismatch.Got),
+				)
+			}
+			return err
+		}
+	}
+
+	if config.VerifyPeerCertificate != nil {
+		rawCerts := make([][]byte, 0, len(cs.PeerCertificates))
+		for _, cert := range cs.PeerCertificates {
+			rawCerts = append(rawCerts, cert.Raw)
+		}
+		if err := config.VerifyPeerCertificate(rawCerts, verifiedChains); err != nil {
+			return fmt.Errorf("tls: custom certificate verification of node %s failed: %w", node, err)
+		}
+	}
+	return nil
+}
+
+func verifyChain(
+	config ALNConfig,
+	verifier SANVerifier,
+	node, serverName string,
+	certs []*x509.Certificate,
+) ([][]*x509.Certificate, error) {
+	var roots *x509.CertPool
+	if config.RootCASource != nil {
+		var err error
+		if roots, err = config.RootCASource.GetRootCAs(config.Logger); err != nil {
+			return nil, fmt.Errorf("failed to get root CAs: %w", err)
+		}
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	chains, err := certs[0].Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifier(node, serverName, certs[0]); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
@@ -0,0 +1,381 @@
+package shared_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+func newTLSTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func serverCAPEM(srv *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+}
+
+func otherCAPEM(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// newCrossSignedServer starts a server that presents its leaf certificate along with an intermediate CA
+// cross-signed by two root CAs, so that the chain can be verified up to either of them
+func newCrossSignedServer(t *testing.T) (srv *httptest.Server, rootA, rootB *x509.Certificate) {
+	t.Helper()
+	newCert := func(tmpl, parent *x509.Certificate, pub, signer any) *x509.Certificate {
+		t.Helper()
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, signer)
+		if err != nil {
+			t.Fatalf("failed to create certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %v", err)
+		}
+		return cert
+	}
+	newKey := func() *ecdsa.PrivateKey {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		return key
+	}
+	caTemplate := func(name string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+	}
+
+	rootAKey, rootBKey, interKey, leafKey := newKey(), newKey(), newKey(), newKey()
+	rootA = newCert(caTemplate("root A"), caTemplate("root A"), &rootAKey.PublicKey, rootAKey)
+	rootB = newCert(caTemplate("root B"), caTemplate("root B"), &rootBKey.PublicKey, rootBKey)
+	interByA := newCert(caTemplate("intermediate"), rootA, &interKey.PublicKey, rootAKey)
+	interByB := newCert(caTemplate("intermediate"), rootB, &interKey.PublicKey, rootBKey)
+	leaf := newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}, interByA, &leafKey.PublicKey, interKey)
+
+	srv = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{leaf.Raw, interByA.Raw, interByB.Raw},
+			PrivateKey:  leafKey,
+		}},
+		MinVersion: tls.VersionTLS12,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, rootA, rootB
+}
+
+func doTLSRequest(srv *httptest.Server, opts ...shared.ALNOption) error {
+	cfg := shared.NewDefaultALNConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	client := &http.Client{Transport: shared.NewHTTPTransport(cfg)}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestTLSVerification(t *testing.T) {
+	t.Parallel()
+
+	srv := newTLSTestServer(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	tcases := []struct {
+		name          string
+		opts          []shared.ALNOption
+		expectedError bool
+	}{
+		{name: "RootCAs", opts: []shared.ALNOption{shared.WithALNRootCAs(pool)}},
+		{
+			name:          "SystemRoots",
+			opts:          []shared.ALNOption{shared.WithALNSANVerifier(shared.VerifyServerName)},
+			expectedError: true,
+		},
+		{
+			name: "ServerName",
+			opts: []shared.ALNOption{shared.WithALNRootCAs(pool), shared.WithALNTLSServerName("example.com")},
+		},
+		{
+			name:          "WrongServerName",
+			opts:          []shared.ALNOption{shared.WithALNRootCAs(pool), shared.WithALNTLSServerName("alternator.local")},
+			expectedError: true,
+		},
+		{
+			name: "WrongServerName/VerifyNodeAddress",
+			opts: []shared.ALNOption{
+				shared.WithALNRootCAs(pool),
+				shared.WithALNTLSServerName("alternator.local"),
+				shared.WithALNSANVerifier(shared.VerifyNodeAddress),
+			},
+		},
+		{
+			name: "WrongServerName/VerifyChainOnly",
+			opts: []shared.ALNOption{
+				shared.WithALNRootCAs(pool),
+				shared.WithALNTLSServerName("alternator.local"),
+				shared.WithALNSANVerifier(shared.VerifyChainOnly),
+			},
+		},
+		{
+			name: "VerifyAnyName",
+			opts: []shared.ALNOption{
+				shared.WithALNRootCAs(pool),
+				shared.WithALNSANVerifier(shared.VerifyAnyName("alternator.local", "example.com")),
+			},
+		},
+		{
+			name: "VerifyAnyName/NoMatch",
+			opts: []shared.ALNOption{
+				shared.WithALNRootCAs(pool),
+				shared.WithALNSANVerifier(shared.VerifyAnyName("alternator.local")),
+			},
+			expectedError: true,
+		},
+		{
+			name: "IgnoreServerCertificateError",
+			opts: []shared.ALNOption{
+				shared.WithALNTLSServerName("alternator.local"),
+				shared.WithALNIgnoreServerCertificateError(true),
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := doTLSRequest(srv, tc.opts...)
+			if tc.expectedError && err == nil {
+				t.Fatalf("request should have failed")
+			}
+			if !tc.expectedError && err != nil {
+				t.Fatalf("request unexpectedly failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestRootCAFileSource(t *testing.T) {
+	t.Parallel()
+
+	srv := newTLSTestServer(t)
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, otherCAPEM(t), 0o600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+
+	source := shared.NewRootCAFile(path)
+	if err := doTLSRequest(srv, shared.WithALNRootCASource(source)); err == nil {
+		t.Fatalf("request should have failed, server certificate is not signed by CA from the file")
+	}
+
+	if err := os.WriteFile(path, serverCAPEM(srv), 0o600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("failed to update CA file modification time: %v", err)
+	}
+	if err := doTLSRequest(srv, shared.WithALNRootCASource(source)); err != nil {
+		t.Fatalf("request unexpectedly failed after CA file update: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+	modTime = modTime.Add(time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("failed to update CA file modification time: %v", err)
+	}
+	if err := doTLSRequest(srv, shared.WithALNRootCASource(source)); err != nil {
+		t.Fatalf("expected last valid root CAs to be kept, request failed: %v", err)
+	}
+
+	if _, err := shared.NewRootCAFile(path).GetRootCAs(shared.NewDefaultALNConfig().Logger); err == nil {
+		t.Fatalf("expected error for CA file without certificates")
+	}
+}
+
+func TestCertificatePinning(t *testing.T) {
+	t.Parallel()
+
+	srv := newTLSTestServer(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	pin := shared.SPKIPin(srv.Certificate())
+	otherPin := "sha256/" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	t.Run("Match", func(t *testing.T) {
+		t.Parallel()
+		err := doTLSRequest(srv, shared.WithALNRootCAs(pool), shared.WithALNCertificatePins(otherPin, pin))
+		if err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+	})
+
+	t.Run("MatchWithoutChainVerification", func(t *testing.T) {
+		t.Parallel()
+		err := doTLSRequest(
+			srv,
+			shared.WithALNIgnoreServerCertificateError(true),
+			shared.WithALNCertificatePins("sha256/"+pin),
+		)
+		if err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		t.Parallel()
+		err := doTLSRequest(
+			srv,
+			shared.WithALNIgnoreServerCertificateError(true),
+			shared.WithALNCertificatePins(otherPin),
+		)
+		var mismatch *shared.PinMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("expected PinMismatchError, got %v", err)
+		}
+		if !slices.Equal(mismatch.Got, []string{pin}) || mismatch.Node != "127.0.0.1" {
+			t.Fatalf("unexpected mismatch details: %v", mismatch)
+		}
+	})
+
+	t.Run("CrossSignedCA", func(t *testing.T) {
+		t.Parallel()
+		crossSigned, rootA, rootB := newCrossSignedServer(t)
+		roots := x509.NewCertPool()
+		roots.AddCert(rootA)
+		roots.AddCert(rootB)
+
+		// Any of verified chains can be first, pinned root has to be accepted in either of them
+		for _, root := range []*x509.Certificate{rootA, rootB} {
+			var chains atomic.Int32
+			verify := func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+				chains.Store(int32(len(verifiedChains)))
+				return nil
+			}
+			err := doTLSRequest(
+				crossSigned,
+				shared.WithALNRootCAs(roots),
+				shared.WithALNCertificatePins(shared.SPKIPin(root)),
+				shared.WithALNVerifyPeerCertificate(verify),
+			)
+			if err != nil {
+				t.Fatalf("expected pin of %s to be accepted, got %v", root.Subject.CommonName, err)
+			}
+			if chains.Load() != 2 {
+				t.Fatalf("expected server certificate to be verified by 2 chains, got %d", chains.Load())
+			}
+		}
+
+		err := doTLSRequest(crossSigned, shared.WithALNRootCAs(roots), shared.WithALNCertificatePins(otherPin))
+		var mismatch *shared.PinMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("expected PinMismatchError, got %v", err)
+		}
+		if len(mismatch.Got) != 4 {
+			t.Fatalf("expected leaf, intermediate and both roots to be reported once, got %v", mismatch.Got)
+		}
+	})
+
+	t.Run("VerifyPeerCertificate", func(t *testing.T) {
+		t.Parallel()
+		var chains atomic.Int32
+		verify := func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			chains.Store(int32(len(verifiedChains)))
+			if len(rawCerts) == 0 {
+				return errors.New("no certificates")
+			}
+			return nil
+		}
+		if err := doTLSRequest(srv, shared.WithALNRootCAs(pool), shared.WithALNVerifyPeerCertificate(verify)); err != nil {
+			t.Fatalf("request unexpectedly failed: %v", err)
+		}
+		if chains.Load() == 0 {
+			t.Fatalf("expected custom verification to receive verified chains")
+		}
+	})
+
+	t.Run("VerifyPeerCertificateError", func(t *testing.T) {
+		t.Parallel()
+		verifyErr := errors.New("rejected by policy")
+		verify := func([][]byte, [][]*x509.Certificate) error {
+			return verifyErr
+		}
+		err := doTLSRequest(
+			srv,
+			shared.WithALNIgnoreServerCertificateError(true),
+			shared.WithALNVerifyPeerCertificate(verify),
+		)
+		if !errors.Is(err, verifyErr) {
+			t.Fatalf("expected custom verification error, got %v", err)
+		}
+	})
+}
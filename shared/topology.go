@@ -0,0 +1,431 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+// NodeInfo is a node known to `AlternatorLiveNodes` along with its location,
+//
+//	location is read from Alternator system tables, when they can't be read it is learned from scoped queries,
+//	datacenter and rack are empty until either is available
+type NodeInfo struct {
+	URL        url.URL
+	Datacenter string
+	Rack       string
+}
+
+type nodeLocation struct {
+	datacenter string
+	rack       string
+}
+
+const (
+	systemLocalTable = ".scylla.alternator.system.local"
+	systemPeersTable = ".scylla.alternator.system.peers"
+)
+
+// systemTableRow is an item of Alternator system table, columns are read as strings
+type systemTableRow map[string]map[string]json.RawMessage
+
+func (r systemTableRow) get(column string) string {
+	var out string
+	_ = json.Unmarshal(r[column]["S"], &out)
+	return out
+}
+
+func (r systemTableRow) location() nodeLocation {
+	return nodeLocation{datacenter: r.get("data_center"), rack: r.get("rack")}
+}
+
+// NewTopologyRegistry creates `AlternatorLiveNodes` to be shared by several helpers, see `View`
+func NewTopologyRegistry(initialNodes []string, options ...Option) (*AlternatorLiveNodes, error) {
+	cfg := NewDefaultConfig()
+	for _, opt := range options {
+		opt(cfg)
+	}
+	aln, err := NewAlternatorLiveNodes(initialNodes, cfg.ToALNOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	// Registry is shared by helpers of different scopes, so locations of nodes are read right away
+	aln.wantLocations()
+	return aln, nil
+}
+
+// updateLocations reads location of every node of the cluster from "system.local" and "system.peers" tables
+//
+//	exposed by Alternator, tables are read from the next node
+func (aln *AlternatorLiveNodes) updateLocations(ctx context.Context) error {
+	node := aln.nextNode()
+	local, err := aln.scanSystemTable(ctx, node, systemLocalTable)
+	if err != nil {
+		return err
+	}
+	peers, err := aln.scanSystemTable(ctx, node, systemPeersTable)
+	if err != nil {
+		return err
+	}
+	for _, row := range local {
+		// Node can be configured to listen on a wildcard address, so address it is queried on is used instead
+		aln.locations.Store(node.Hostname(), row.location())
+	}
+	for _, row := range peers {
+		host := row.get("rpc_address")
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			host = row.get("peer")
+		}
+		if host != "" {
+			aln.locations.Store(host, row.location())
+		}
+	}
+	return nil
+}
+
+// scanSystemTable reads all rows of Alternator system table from the node,
+//
+//	request is signed when credentials are provided
+func (aln *AlternatorLiveNodes) scanSystemTable(
+	ctx context.Context,
+	node url.URL,
+	table string,
+) ([]systemTableRow, error) {
+	var creds *Credentials
+	if provider := aln.cfg.CredentialsProvider; provider != nil {
+		retrieved, err := provider.Retrieve(ctx, aln.cfg.Logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve credentials: %w", err)
+		}
+		creds = &retrieved
+	}
+	endpoint := node
+	endpoint.Path = "/"
+
+	var (
+		out      []systemTableRow
+		startKey json.RawMessage
+	)
+	for {
+		if aln.State() == StateClosed {
+			return nil, ErrClosed
+		}
+		body, err := json.Marshal(struct {
+			TableName         string
+			ExclusiveStartKey json.RawMessage `json:",omitempty"`
+		}{TableName: table, ExclusiveStartKey: startKey})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+		req.Header.Set("X-Amz-Target", "DynamoDB_20120810.Scan")
+		if creds != nil {
+			signRequest(req, body, *creds, aln.cfg.AWSRegion, time.Now())
+		}
+		resp, err := aln.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			var apiErr struct {
+				Type    string `json:"__type"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(respBody, &apiErr)
+			return nil, fmt.Errorf("failed to read %s: %s %s", table, apiErr.Type, apiErr.Message)
+		}
+		var page struct {
+			Items            []systemTableRow
+			LastEvaluatedKey json.RawMessage
+		}
+		if err = json.Unmarshal(respBody, &page); err != nil {
+			return nil, err
+		}
+		out = append(out, page.Items...)
+		if len(page.LastEvaluatedKey) == 0 || string(page.LastEvaluatedKey) == "null" {
+			return out, nil
+		}
+		startKey = page.LastEvaluatedKey
+	}
+}
+
+// wantLocations makes next refresh read node locations from system tables, they are read only once
+//
+//	a scoped list of nodes, `Topology` or a registry needs them, returns true on first call
+func (aln *AlternatorLiveNodes) wantLocations() bool {
+	if !aln.locationsWanted.CompareAndSwap(false, true) {
+		return false
+	}
+	aln.locationsStale.Store(true)
+	return true
+}
+
+// checkLocations makes next refresh read node locations from system tables, if any of added nodes has unknown rack
+//
+//	and locations are needed
+func (aln *AlternatorLiveNodes) checkLocations(added []url.URL) {
+	if !aln.locationsWanted.Load() {
+		return
+	}
+	for _, node := range added {
+		if v, ok := aln.locations.Load(node.Hostname()); !ok || v.(nodeLocation).rack == "" {
+			aln.locationsStale.Store(true)
+			return
+		}
+	}
+}
+
+// recordLocations remembers location of nodes returned by "/localnodes" for the query of endpoint
+func (aln *AlternatorLiveNodes) recordLocations(endpoint *url.URL, nodes []url.URL) {
+	query := endpoint.Query()
+	dc, rack := query.Get("dc"), query.Get("rack")
+	if dc == "" && rack == "" {
+		return
+	}
+	for _, node := range nodes {
+		v, loaded := aln.locations.LoadOrStore(node.Hostname(), nodeLocation{datacenter: dc, rack: rack})
+		if !loaded {
+			continue
+		}
+		loc := v.(nodeLocation)
+		if dc != "" {
+			loc.datacenter = dc
+		}
+		if rack != "" {
+			loc.rack = rack
+		}
+		aln.locations.Store(node.Hostname(), loc)
+	}
+}
+
+// Topology returns nodes of the main list and of lists of all scopes in use, along with their locations,
+//
+//	locations are read by background refresher after first call, unless they are read already
+func (aln *AlternatorLiveNodes) Topology() []NodeInfo {
+	if aln.wantLocations() {
+		select {
+		case aln.updateSignal <- struct{}{}:
+		default:
+		}
+	}
+	seen := map[string]bool{}
+	var out []NodeInfo
+	add := func(nodes []url.URL) {
+		for _, node := range nodes {
+			if seen[node.Host] {
+				continue
+			}
+			seen[node.Host] = true
+			info := NodeInfo{URL: node}
+			if v, ok := aln.locations.Load(node.Hostname()); ok {
+				loc := v.(nodeLocation)
+				info.Datacenter, info.Rack = loc.datacenter, loc.rack
+			}
+			out = append(out, info)
+		}
+	}
+	add(aln.GetNodes())
+	aln.scopedNodes.Range(func(_, v any) bool {
+		if list := v.(*scopedNodes).nodes.Load(); list != nil {
+			add(list.nodes)
+		}
+		return true
+	})
+	slices.SortFunc(out, func(a, b NodeInfo) int {
+		return strings.Compare(a.URL.Host, b.URL.Host)
+	})
+	return out
+}
+
+// View returns a cheap view of nodes restricted to the routing scope and addressed with the given scheme and port,
+//
+//	views of the same scope share one list of nodes and its updates, empty scheme, zero port and nil scope
+//	mean ones `AlternatorLiveNodes` is configured with
+func (aln *AlternatorLiveNodes) View(scope rt.Scope, scheme string, port int) *NodesView {
+	if scope == nil {
+		scope = aln.cfg.RoutingScope
+	}
+	if scheme == "" {
+		scheme = aln.cfg.Scheme
+	}
+	if port == 0 {
+		port = aln.cfg.Port
+	}
+	return &NodesView{
+		registry: aln,
+		scope:    scope,
+		scheme:   scheme,
+		port:     port,
+	}
+}
+
+// NodesView is a view of nodes discovered by `AlternatorLiveNodes` created by `View`,
+//
+//	lifecycle methods control the `AlternatorLiveNodes` it is created from
+type NodesView struct {
+	registry *AlternatorLiveNodes
+	scope    rt.Scope
+	scheme   string
+	port     int
+}
+
+func (v *NodesView) primary() bool {
+	return scopeKey(v.scope) == scopeKey(v.registry.cfg.RoutingScope)
+}
+
+func (v *NodesView) address(node url.URL) url.URL {
+	port := strconv.Itoa(v.port)
+	if node.Scheme == v.scheme && node.Port() == port {
+		return node
+	}
+	node.Scheme = v.scheme
+	node.Host = net.JoinHostPort(node.Hostname(), port)
+	return node
+}
+
+func (v *NodesView) addresses(nodes []url.URL) []url.URL {
+	out := make([]url.URL, len(nodes))
+	for i, node := range nodes {
+		out[i] = v.address(node)
+	}
+	return out
+}
+
+// View returns a view of the same `AlternatorLiveNodes` with another scope, scheme or port
+func (v *NodesView) View(scope rt.Scope, scheme string, port int) *NodesView {
+	return v.registry.View(scope, scheme, port)
+}
+
+// NextNode returns next node of the scope
+func (v *NodesView) NextNode() url.URL {
+	node, _ := v.registry.nextNodeForScope(v.scope)
+	return v.address(node)
+}
+
+// NextNodeForContext is the same as `AlternatorLiveNodes.NextNodeForContext`, but uses scope of the view by default
+func (v *NodesView) NextNodeForContext(ctx context.Context) url.URL {
+	if node, ok := NodeFromContext(ctx); ok {
+		RoutingRecorderFromContext(ctx).Record(node, nil)
+		return node
+	}
+	scope := v.scope
+	if s, ok := ScopeFromContext(ctx); ok {
+		scope = s
+	}
+	var (
+		node url.URL
+		tier rt.Scope
+	)
+	if policy, ok := BalancingPolicyFromContext(ctx); ok {
+		var nodes []url.URL
+		nodes, tier = v.registry.nodesForScope(scope)
+		node = policy(nodes)
+	} else {
+		node, tier = v.registry.nextNodeForScope(scope)
+	}
+	node = v.address(node)
+	RoutingRecorderFromContext(ctx).Record(node, tier)
+	return node
+}
+
+// GetNodes returns a copy of the list of nodes of the scope
+func (v *NodesView) GetNodes() []url.URL {
+	if v.primary() {
+		return v.addresses(v.registry.GetNodes())
+	}
+	sn := v.registry.scopedNodesEntry(v.scope)
+	list := sn.nodes.Load()
+	if list == nil || len(list.nodes) == 0 {
+		return v.addresses(v.registry.GetNodes())
+	}
+	return v.addresses(list.nodes)
+}
+
+// UpdateLiveNodes forces an immediate refresh of the list of nodes of the scope
+func (v *NodesView) UpdateLiveNodes() error {
+	if v.primary() {
+		return v.registry.UpdateLiveNodes()
+	}
+	return v.registry.refreshScopedNodes(v.registry.scopedNodesEntry(v.scope))
+}
+
+// CheckIfRackAndDatacenterSetCorrectly verifies that the scope of the view is recognized by the cluster
+func (v *NodesView) CheckIfRackAndDatacenterSetCorrectly() error {
+	return v.registry.checkRackAndDatacenter(v.scope)
+}
+
+// CheckIfRackDatacenterFeatureIsSupported checks whether the cluster supports rack/datacenter-aware features
+func (v *NodesView) CheckIfRackDatacenterFeatureIsSupported() (bool, error) {
+	return v.registry.CheckIfRackDatacenterFeatureIsSupported()
+}
+
+// WaitForReady is the same as `AlternatorLiveNodes.WaitForReady`, but checks scope of the view
+func (v *NodesView) WaitForReady(ctx context.Context, opts ReadyOptions) (ReadyReport, error) {
+	report, err := v.registry.waitForReady(ctx, v.scope, opts)
+	report.Nodes = v.addresses(report.Nodes)
+	return report, err
+}
+
+// OnNewNodes registers a callback that is called with nodes that appear in the list of nodes of the scope
+func (v *NodesView) OnNewNodes(fn func([]url.URL)) {
+	wrapped := func(nodes []url.URL) {
+		fn(v.addresses(nodes))
+	}
+	if v.primary() {
+		v.registry.OnNewNodes(wrapped)
+		return
+	}
+	sn := v.registry.scopedNodesEntry(v.scope)
+	sn.listenersLock.Lock()
+	defer sn.listenersLock.Unlock()
+	sn.listeners = append(sn.listeners, wrapped)
+}
+
+// Topology returns nodes known to `AlternatorLiveNodes` along with their locations
+func (v *NodesView) Topology() []NodeInfo {
+	out := v.registry.Topology()
+	for i := range out {
+		out[i].URL = v.address(out[i].URL)
+	}
+	return out
+}
+
+// Start starts background node discovery of `AlternatorLiveNodes`
+func (v *NodesView) Start() {
+	v.registry.Start()
+}
+
+// Stop stops background node discovery of `AlternatorLiveNodes`
+func (v *NodesView) Stop() {
+	v.registry.Stop()
+}
+
+// Close closes `AlternatorLiveNodes`
+func (v *NodesView) Close(ctx context.Context) error {
+	return v.registry.Close(ctx)
+}
+
+// State returns lifecycle state of `AlternatorLiveNodes`
+func (v *NodesView) State() LifecycleState {
+	return v.registry.State()
+}
@@ -0,0 +1,289 @@
+package shared_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/alternatortest"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+	"github.com/scylladb/alternator-client-golang/shared/rt"
+)
+
+func totalRequests(cluster *alternatortest.Cluster) int64 {
+	var total int64
+	for _, node := range cluster.Nodes() {
+		total += node.Requests()
+	}
+	return total
+}
+
+func hostnames(nodes []url.URL) []string {
+	out := make([]string, len(nodes))
+	for i, node := range nodes {
+		out[i] = node.Hostname()
+	}
+	slices.Sort(out)
+	return out
+}
+
+// countLocalNodes makes nodes of the cluster count "/localnodes" requests they receive
+func countLocalNodes(cluster *alternatortest.Cluster) *atomic.Int64 {
+	var counter atomic.Int64
+	for _, node := range cluster.Nodes() {
+		node.InjectFault(alternatortest.OnPath("/localnodes", func(http.ResponseWriter, *http.Request) bool {
+			counter.Add(1)
+			return false
+		}))
+	}
+	return &counter
+}
+
+func TestTopologyRegistry(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+		{Datacenter: "dc1", Rack: "rack1"},
+		{Datacenter: "dc1", Rack: "rack1"},
+		{Datacenter: "dc1", Rack: "rack2"},
+		{Datacenter: "dc2", Rack: "rack1"},
+	})
+	hosts := cluster.Hosts()
+	for _, node := range cluster.Nodes() {
+		node.InjectFault(alternatortest.RequireSigV4("key", "secret"))
+	}
+	localNodes := countLocalNodes(cluster)
+
+	registry, err := shared.NewTopologyRegistry(
+		hosts[:1],
+		shared.WithPort(cluster.Port()),
+		shared.WithRoutingScope(rt.NewDCScope("dc1", nil)),
+		shared.WithCredentials("key", "secret"),
+		shared.WithLogger(logx.Noop{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create topology registry: %v", err)
+	}
+	t.Cleanup(registry.Stop)
+	if err = registry.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+	}
+
+	rack2 := rt.NewRackScope("dc1", "rack2", nil)
+	view := registry.View(rack2, "https", 9443)
+	var added []url.URL
+	var addedLock sync.Mutex
+	view.OnNewNodes(func(nodes []url.URL) {
+		addedLock.Lock()
+		defer addedLock.Unlock()
+		added = append(added, nodes...)
+	})
+
+	// List of the scope is discovered in background, until then nodes of the main list are used
+	if got := hostnames(view.GetNodes()); !slices.Equal(got, hosts[:3]) {
+		t.Fatalf("expected view to use nodes %v of the main list until its own are discovered, got %v", hosts[:3], got)
+	}
+	waitForNodes(t, view, hosts[2:3])
+	// Another view of the same scope shares the list of nodes
+	before := totalRequests(cluster)
+	other := registry.View(rt.NewRackScope("dc1", "rack2", nil), "", 0)
+	other.NextNode()
+	if got := totalRequests(cluster) - before; got != 0 {
+		t.Fatalf("expected views of the same scope to share the list of nodes, got %d discovery requests", got)
+	}
+
+	node := view.NextNode()
+	if node.Scheme != "https" || node.Port() != "9443" || node.Hostname() != hosts[2] {
+		t.Errorf("expected view to address node %s with its scheme and port, got %s", hosts[2], node.String())
+	}
+	if node = other.NextNode(); node.Port() != strconv.Itoa(cluster.Port()) {
+		t.Errorf("expected view to use port of the registry by default, got %s", node.String())
+	}
+	addedLock.Lock()
+	if got := hostnames(added); !slices.Equal(got, hosts[2:3]) {
+		t.Errorf("expected view listeners to be notified about nodes %v, got %v", hosts[2:3], got)
+	}
+	addedLock.Unlock()
+
+	dc2 := registry.View(rt.NewDCScope("dc2", nil), "", 0)
+	if err = dc2.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() of a view unexpectedly returned an error: %v", err)
+	}
+	if got := hostnames(dc2.GetNodes()); !slices.Equal(got, hosts[3:]) {
+		t.Fatalf("expected view to have nodes %v, got %v", hosts[3:], got)
+	}
+	if got := hostnames(registry.GetNodes()); !slices.Equal(got, hosts[:3]) {
+		t.Fatalf("expected views to leave main list of nodes intact, got %v", got)
+	}
+
+	// Lists of all scopes are refreshed together, every "/localnodes" query is made once per refresh
+	before = localNodes.Load()
+	if err = registry.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+	}
+	if got := localNodes.Load() - before; got != 3 {
+		t.Errorf("expected one \"/localnodes\" request per scope to refresh all scopes, got %d", got)
+	}
+	if got := hostnames(view.GetNodes()); !slices.Equal(got, hosts[2:3]) {
+		t.Errorf("expected list of the rack to have nodes %v, got %v", hosts[2:3], got)
+	}
+	expectLocations(t, registry.Topology(), cluster)
+
+	// System tables are not read again until new nodes show up
+	before = totalRequests(cluster)
+	if err = registry.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+	}
+	if got := totalRequests(cluster) - before; got != 3 {
+		t.Errorf("expected refresh to make only \"/localnodes\" requests, got %d requests", got)
+	}
+}
+
+// waitForNodes waits for list of nodes of the view, discovered in background, to have the given hosts
+func waitForNodes(t *testing.T, view *shared.NodesView, want []string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := hostnames(view.GetNodes())
+		if slices.Equal(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected view to have nodes %v, got %v", want, got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// expectLocations checks that topology has every node of the cluster in its datacenter and rack
+func expectLocations(t *testing.T, topology []shared.NodeInfo, cluster *alternatortest.Cluster) {
+	t.Helper()
+	if len(topology) != len(cluster.Nodes()) {
+		t.Fatalf("expected topology to have %d nodes, got %v", len(cluster.Nodes()), topology)
+	}
+	for i, info := range topology {
+		node := cluster.Node(i)
+		if info.URL.Hostname() != node.Host() || info.Datacenter != node.Datacenter || info.Rack != node.Rack {
+			t.Errorf(
+				"expected node %s to be in %s/%s, got %s in %s/%s",
+				node.Host(), node.Datacenter, node.Rack, info.URL.Hostname(), info.Datacenter, info.Rack,
+			)
+		}
+	}
+}
+
+func TestTopologyLocations(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ClusterScope", func(t *testing.T) {
+		t.Parallel()
+
+		cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+			{Datacenter: "dc1", Rack: "rack1"},
+			{Datacenter: "dc1", Rack: "rack2"},
+			{Datacenter: "dc1", Rack: "rack3"},
+		})
+
+		registry, err := shared.NewTopologyRegistry(
+			cluster.Hosts()[:1],
+			shared.WithPort(cluster.Port()),
+			shared.WithLogger(logx.Noop{}),
+		)
+		if err != nil {
+			t.Fatalf("failed to create topology registry: %v", err)
+		}
+		t.Cleanup(registry.Stop)
+		if err = registry.UpdateLiveNodes(); err != nil {
+			t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+		}
+		expectLocations(t, registry.Topology(), cluster)
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		t.Parallel()
+
+		cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+			{Datacenter: "dc1", Rack: "rack1"},
+			{Datacenter: "dc1", Rack: "rack2"},
+		})
+		for _, node := range cluster.Nodes() {
+			node.InjectFault(alternatortest.OnOperation(
+				"Scan",
+				alternatortest.ErrorResponse(http.StatusBadRequest, "AccessDeniedException", "access denied"),
+			))
+		}
+
+		registry, err := shared.NewTopologyRegistry(
+			cluster.Hosts()[:1],
+			shared.WithPort(cluster.Port()),
+			shared.WithLogger(logx.Noop{}),
+		)
+		if err != nil {
+			t.Fatalf("failed to create topology registry: %v", err)
+		}
+		t.Cleanup(registry.Stop)
+		if err = registry.UpdateLiveNodes(); err != nil {
+			t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+		}
+		for _, info := range registry.Topology() {
+			if info.Datacenter != "" || info.Rack != "" {
+				t.Fatalf("expected no locations to be known, got %v", info)
+			}
+		}
+
+		// System tables can't be read, so location is learned from the scoped query
+		waitForNodes(t, registry.View(rt.NewRackScope("dc1", "rack2", nil), "", 0), cluster.Hosts()[1:])
+		topology := registry.Topology()
+		if len(topology) != 2 || topology[0].Datacenter != "" ||
+			topology[1].Datacenter != "dc1" || topology[1].Rack != "rack2" {
+			t.Errorf("expected only node %s to be known in dc1/rack2, got %v", cluster.Node(1).Host(), topology)
+		}
+	})
+}
+
+func TestLocationsReadOnDemand(t *testing.T) {
+	t.Parallel()
+
+	cluster := alternatortest.StartCluster(t, []alternatortest.NodeConfig{
+		{Datacenter: "dc1", Rack: "rack1"},
+		{Datacenter: "dc1", Rack: "rack2"},
+	})
+	var scans atomic.Int64
+	for _, node := range cluster.Nodes() {
+		node.InjectFault(alternatortest.OnOperation("Scan", func(http.ResponseWriter, *http.Request) bool {
+			scans.Add(1)
+			return false
+		}))
+		node.InjectFault(alternatortest.OnPath("/localnodes", alternatortest.Latency(100*time.Millisecond)))
+	}
+
+	aln, err := shared.NewAlternatorLiveNodes(cluster.Hosts()[:1], shared.WithALNPort(cluster.Port()))
+	if err != nil {
+		t.Fatalf("failed to create AlternatorLiveNodes: %v", err)
+	}
+	t.Cleanup(func() { _ = aln.Close(context.Background()) })
+	if err = aln.UpdateLiveNodes(); err != nil {
+		t.Fatalf("UpdateLiveNodes() unexpectedly returned an error: %v", err)
+	}
+	if got := scans.Load(); got != 0 {
+		t.Fatalf("expected locations not to be read while nothing needs them, got %d scans", got)
+	}
+
+	// First request of a scope doesn't wait for discovery of its list
+	started := time.Now()
+	aln.NextNodeForScope(rt.NewRackScope("dc1", "rack2", nil))
+	if elapsed := time.Since(started); elapsed >= 100*time.Millisecond {
+		t.Errorf("expected list of the scope to be discovered in background, request waited for %v", elapsed)
+	}
+	waitForNodes(t, aln.View(rt.NewRackScope("dc1", "rack2", nil), "", 0), cluster.Hosts()[1:])
+	if scans.Load() == 0 {
+		t.Errorf("expected locations to be read once list of a scope is used")
+	}
+}
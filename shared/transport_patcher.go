@@ -0,0 +1,199 @@
+package shared
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// TransportPatcher patches http clients of a helper according to `Config`, the same way `PatchHTTPClient` does,
+//
+//	but every underlying `http.Transport` is patched only once, so that patches don't stack up on a transport
+//	that is shared by several clients
+type TransportPatcher struct {
+	config  Config
+	lock    sync.Mutex
+	base    http.RoundTripper
+	patched map[*http.Transport]struct{}
+	clones  map[any]*http.Transport
+	// owned is a set of transports created by the patcher, other ones belong to the caller and are patched in place
+	owned map[*http.Transport]struct{}
+	// releases release transports of the caller patched in place, see `inPlacePatches`
+	releases []func()
+}
+
+// inPlacePatches is a registry of `http.Transport` of the caller, wrapped by transport set by `WithHTTPTransport`,
+// that are patched in place, since wrapper can't be cloned. Transport is shared by node discovery and helpers,
+// so it is patched only by the first of them, every user holds a reference to it until it is closed,
+// and transport is forgotten once all of them are closed
+var inPlacePatches = struct {
+	lock    sync.Mutex
+	entries map[*http.Transport]*inPlacePatch
+}{entries: map[*http.Transport]*inPlacePatch{}}
+
+type inPlacePatch struct {
+	config ALNConfig
+	refs   int
+}
+
+// acquireInPlacePatch patches `http.Transport` of the caller unless it has been patched already,
+//
+//	settings that differ from ones transport is patched with can't be applied, so a warning is logged.
+//	Returned function releases the reference, it is safe to call it multiple times
+func acquireInPlacePatch(config ALNConfig, transport *http.Transport) func() {
+	inPlacePatches.lock.Lock()
+	defer inPlacePatches.lock.Unlock()
+	entry, ok := inPlacePatches.entries[transport]
+	if !ok {
+		entry = &inPlacePatch{config: config}
+		inPlacePatches.entries[transport] = entry
+		PatchBasicHTTPTransport(config, transport)
+	} else if !sameTransportSettings(entry.config, config) {
+		config.Logger.Warn(
+			"http transport is already patched in place with other settings, they are not applied to it, " +
+				"use separate transports for helpers with different settings",
+		)
+	}
+	entry.refs++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			inPlacePatches.lock.Lock()
+			defer inPlacePatches.lock.Unlock()
+			entry.refs--
+			if entry.refs == 0 {
+				delete(inPlacePatches.entries, transport)
+			}
+		})
+	}
+}
+
+// sameTransportSettings tells whether `PatchBasicHTTPTransport` patches transport the same way for both configs,
+//
+//	loggers and certificate expiry monitors are not compared, since every helper has its own
+func sameTransportSettings(a, b ALNConfig) bool {
+	return a.IgnoreServerCertificateError == b.IgnoreServerCertificateError &&
+		a.TLSServerName == b.TLSServerName &&
+		slices.Equal(a.CertificatePins, b.CertificatePins) &&
+		reflect.DeepEqual(a.TLSPolicy, b.TLSPolicy) &&
+		a.MaxIdleHTTPConnections == b.MaxIdleHTTPConnections &&
+		a.IdleHTTPConnectionTimeout == b.IdleHTTPConnectionTimeout &&
+		a.MaxIdleHTTPConnectionsPerHost == b.MaxIdleHTTPConnectionsPerHost &&
+		a.MaxHTTPConnectionsPerHost == b.MaxHTTPConnectionsPerHost &&
+		a.DialTimeout == b.DialTimeout &&
+		a.DialKeepAlive == b.DialKeepAlive &&
+		a.TLSHandshakeTimeout == b.TLSHandshakeTimeout &&
+		a.ResponseHeaderTimeout == b.ResponseHeaderTimeout &&
+		a.HTTP2Mode == b.HTTP2Mode &&
+		sameReference(a.ClientCertificateSource, b.ClientCertificateSource) &&
+		sameReference(a.RootCASource, b.RootCASource) &&
+		sameReference(a.SANVerifier, b.SANVerifier) &&
+		sameReference(a.VerifyPeerCertificate, b.VerifyPeerCertificate) &&
+		sameReference(a.KeyLogWriter, b.KeyLogWriter) &&
+		sameReference(a.TLSSessionCache, b.TLSSessionCache)
+}
+
+// sameReference compares pointers and functions by address and other values by value
+func sameReference(a, b any) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return va.IsValid() == vb.IsValid()
+	}
+	if va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Pointer, reflect.Func, reflect.Map, reflect.Slice, reflect.Chan, reflect.UnsafePointer:
+		return va.Pointer() == vb.Pointer()
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// NewTransportPatcher creates new `TransportPatcher`
+func NewTransportPatcher(config Config) *TransportPatcher {
+	return &TransportPatcher{
+		config:  config,
+		patched: map[*http.Transport]struct{}{},
+		clones:  map[any]*http.Transport{},
+		owned:   map[*http.Transport]struct{}{},
+	}
+}
+
+// BaseTransport returns transport shared by http clients of the helper, the same one on every call:
+//
+//	a clone of `http.Transport` set by `WithHTTPTransport`, so that transport of the caller is left intact,
+//	a wrapper set by `WithHTTPTransport` as is, or a new default transport
+func (p *TransportPatcher) BaseTransport() http.RoundTripper {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.base == nil {
+		switch t := p.config.HTTPTransport.(type) {
+		case nil:
+			p.base = p.own(DefaultHTTPTransport())
+		case *http.Transport:
+			p.base = p.own(t.Clone())
+		default:
+			p.base = t
+		}
+	}
+	return p.base
+}
+
+// CloneTransport returns transport created by `clone`, the same one for the same key on every call,
+//
+//	so that clients built on top of the same transport of the caller share connections, patches and warm-ups
+func (p *TransportPatcher) CloneTransport(key any, clone func() *http.Transport) *http.Transport {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	t, ok := p.clones[key]
+	if !ok {
+		t = p.own(clone())
+		p.clones[key] = t
+	}
+	return t
+}
+
+func (p *TransportPatcher) own(transport *http.Transport) *http.Transport {
+	p.owned[transport] = struct{}{}
+	return transport
+}
+
+// Patch patches transport of the client and wraps it according to `Config`,
+//
+//	`http.Transport` that has been patched already is only wrapped.
+//	Headers are not removed by the transport, helpers remove them before request is signed
+func (p *TransportPatcher) Patch(client *http.Client) error {
+	return patchHTTPClient(p.config, client, p.patchOnce, false)
+}
+
+func (p *TransportPatcher) patchOnce(config ALNConfig, transport *http.Transport) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.patched[transport]; ok {
+		return
+	}
+	p.patched[transport] = struct{}{}
+	if _, ok := p.owned[transport]; !ok {
+		p.releases = append(p.releases, acquireInPlacePatch(config, transport))
+		return
+	}
+	PatchBasicHTTPTransport(config, transport)
+}
+
+// Close releases transports of the caller patched in place, so that they can be patched again by other helpers,
+//
+//	it has a signature of `RequestTracker.OnClose` callback
+func (p *TransportPatcher) Close(context.Context) error {
+	p.lock.Lock()
+	releases := p.releases
+	p.releases = nil
+	p.lock.Unlock()
+	for _, release := range releases {
+		release()
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package shared_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+type wrappingTransport struct {
+	http.RoundTripper
+}
+
+func (t wrappingTransport) Unwrap() http.RoundTripper {
+	return t.RoundTripper
+}
+
+func TestTransportPatcher(t *testing.T) {
+	t.Parallel()
+
+	for _, wrapped := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Wrapped=%t", wrapped), func(t *testing.T) {
+			t.Parallel()
+
+			var depth int
+			underlying := &http.Transport{
+				TLSClientConfig: &tls.Config{
+					VerifyConnection: func(tls.ConnectionState) error {
+						depth = verifyConnectionDepth()
+						return nil
+					},
+				},
+			}
+			var transport http.RoundTripper = underlying
+			if wrapped {
+				transport = wrappingTransport{underlying}
+			}
+			cfg := shared.NewDefaultConfig()
+			shared.WithHTTPTransport(transport)(cfg)
+			patcher := shared.NewTransportPatcher(*cfg)
+
+			for range 5 {
+				if err := patcher.Patch(&http.Client{Transport: patcher.BaseTransport()}); err != nil {
+					t.Fatalf("failed to patch http client: %v", err)
+				}
+			}
+			// Wrapper can't be cloned, so its underlying transport is patched in place
+			patched, _ := shared.UnwrapHTTPTransport(patcher.BaseTransport())
+			if (patched == underlying) != wrapped {
+				t.Fatalf("expected transport to be cloned: %t", !wrapped)
+			}
+			if err := patched.TLSClientConfig.VerifyConnection(tls.ConnectionState{}); err != nil || depth != 1 {
+				t.Errorf("expected VerifyConnection to be wrapped once, got depth %d, error %v", depth, err)
+			}
+		})
+	}
+}
+
+func TestTransportPatchedOnceWithNodeDiscovery(t *testing.T) {
+	t.Parallel()
+
+	var depth int
+	underlying := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			VerifyConnection: func(tls.ConnectionState) error {
+				depth = verifyConnectionDepth()
+				return nil
+			},
+		},
+	}
+	logger := &warnRecorder{}
+	cfg := shared.NewDefaultConfig()
+	shared.WithHTTPTransport(wrappingTransport{underlying})(cfg)
+	shared.WithLogger(logger)(cfg)
+
+	// Node discovery and helper share wrapped transport of the caller
+	aln, err := shared.NewAlternatorLiveNodes([]string{"127.0.0.1"}, cfg.ToALNOptions()...)
+	if err != nil {
+		t.Fatalf("failed to create AlternatorLiveNodes: %v", err)
+	}
+	defer func() { _ = aln.Close(context.Background()) }()
+	patcher := shared.NewTransportPatcher(*cfg)
+	for range 3 {
+		if err := patcher.Patch(&http.Client{Transport: patcher.BaseTransport()}); err != nil {
+			t.Fatalf("failed to patch http client: %v", err)
+		}
+	}
+	if err := underlying.TLSClientConfig.VerifyConnection(tls.ConnectionState{}); err != nil || depth != 1 {
+		t.Errorf("expected VerifyConnection to be wrapped once, got depth %d, error %v", depth, err)
+	}
+	if logger.warnings.Load() != 0 {
+		t.Errorf("expected node discovery and helper to patch transport with the same settings")
+	}
+}
+
+func TestTransportPatchedInPlaceBySeveralHelpers(t *testing.T) {
+	t.Parallel()
+
+	underlying := &http.Transport{}
+	newPatcher := func(maxIdle int, logger logx.Logger) *shared.TransportPatcher {
+		cfg := shared.NewDefaultConfig()
+		shared.WithHTTPTransport(wrappingTransport{underlying})(cfg)
+		shared.WithMaxIdleHTTPConnections(maxIdle)(cfg)
+		shared.WithLogger(logger)(cfg)
+		patcher := shared.NewTransportPatcher(*cfg)
+		if err := patcher.Patch(&http.Client{Transport: patcher.BaseTransport()}); err != nil {
+			t.Fatalf("failed to patch http client: %v", err)
+		}
+		return patcher
+	}
+
+	first := newPatcher(10, logx.Noop{})
+	same := &warnRecorder{}
+	sameSettings := newPatcher(10, same)
+	if same.warnings.Load() != 0 {
+		t.Errorf("expected helper with the same settings not to warn, got %d warnings", same.warnings.Load())
+	}
+	other := &warnRecorder{}
+	otherSettings := newPatcher(20, other)
+	if other.warnings.Load() != 1 {
+		t.Errorf("expected helper with other settings to warn once, got %d warnings", other.warnings.Load())
+	}
+	if underlying.MaxIdleConns != 10 {
+		t.Errorf("expected transport to keep settings of the first helper, got MaxIdleConns %d", underlying.MaxIdleConns)
+	}
+
+	for _, patcher := range []*shared.TransportPatcher{first, sameSettings, otherSettings} {
+		_ = patcher.Close(context.Background())
+	}
+	// Once all helpers are closed, transport is forgotten and patched by the next one
+	last := &warnRecorder{}
+	_ = newPatcher(20, last)
+	if last.warnings.Load() != 0 || underlying.MaxIdleConns != 20 {
+		t.Errorf("expected transport to be patched again, got MaxIdleConns %d, %d warnings",
+			underlying.MaxIdleConns, last.warnings.Load())
+	}
+}
+
+// verifyConnectionDepth returns number of VerifyConnection wrappers installed by `PatchBasicHTTPTransport`
+// on the call stack
+func verifyConnectionDepth() int {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	depth := 0
+	for {
+		frame, more := frames.Next()
+		if strings.Contains(frame.Function, "shared.PatchBasicHTTPTransport") {
+			depth++
+		}
+		if !more {
+			return depth
+		}
+	}
+}
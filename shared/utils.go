@@ -3,7 +3,14 @@ package shared
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"net"
 	"net/http"
+	"time"
+)
+
+const (
+	defaultDialTimeout   = 30 * time.Second
+	defaultDialKeepAlive = 30 * time.Second
 )
 
 // DefaultHTTPTransport creates default `http.Transport`
@@ -13,6 +20,23 @@ func DefaultHTTPTransport() *http.Transport {
 	return transport
 }
 
+// UnwrapHTTPTransport returns `http.Transport` the round tripper is built on,
+//
+//	wrapping round trippers are followed through their `Unwrap() http.RoundTripper` method
+func UnwrapHTTPTransport(transport http.RoundTripper) (*http.Transport, bool) {
+	for transport != nil {
+		switch t := transport.(type) {
+		case *http.Transport:
+			return t, true
+		case interface{ Unwrap() http.RoundTripper }:
+			transport = t.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
 // NewHTTPTransport creates new http transport based on `ALNConfig`
 func NewHTTPTransport(config ALNConfig) *http.Transport {
 	transport := DefaultHTTPTransport()
@@ -25,10 +49,44 @@ func PatchBasicHTTPTransport(config ALNConfig, transport *http.Transport) {
 	transport.IdleConnTimeout = config.IdleHTTPConnectionTimeout
 	transport.MaxIdleConns = config.MaxIdleHTTPConnections
 
+	if config.MaxIdleHTTPConnectionsPerHost != 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdleHTTPConnectionsPerHost
+	}
+
+	if config.MaxHTTPConnectionsPerHost != 0 {
+		transport.MaxConnsPerHost = config.MaxHTTPConnectionsPerHost
+	}
+
+	if config.DialTimeout != 0 || config.DialKeepAlive != 0 {
+		dialer := &net.Dialer{
+			Timeout:   defaultDialTimeout,
+			KeepAlive: defaultDialKeepAlive,
+		}
+		if config.DialTimeout != 0 {
+			dialer.Timeout = config.DialTimeout
+		}
+		if config.DialKeepAlive != 0 {
+			dialer.KeepAlive = config.DialKeepAlive
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	if config.TLSHandshakeTimeout != 0 {
+		transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	}
+
+	if config.ResponseHeaderTimeout != 0 {
+		transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	}
+
+	PatchHTTP2Mode(config.HTTP2Mode, transport)
+
 	if transport.TLSClientConfig == nil {
 		transport.TLSClientConfig = &tls.Config{}
 	}
 
+	config.TLSPolicy.Apply(transport.TLSClientConfig)
+
 	if config.KeyLogWriter != nil {
 		transport.TLSClientConfig.KeyLogWriter = config.KeyLogWriter
 	}
@@ -46,7 +104,27 @@ func PatchBasicHTTPTransport(config ALNConfig, transport *http.Transport) {
 
 	if config.ClientCertificateSource != nil {
 		transport.TLSClientConfig.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
-			return config.ClientCertificateSource.GetClientCertificate(info, config.Logger)
+			cert, err := config.ClientCertificateSource.GetClientCertificate(info, config.Logger)
+			if err == nil {
+				config.CertificateExpiryMonitor.Observe(ClientCertificate, "", leafCertificate(cert), config.Logger)
+			}
+			return cert, err
+		}
+	}
+
+	if config.CertificateExpiryMonitor != nil {
+		verifyConnection := transport.TLSClientConfig.VerifyConnection
+		transport.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) != 0 {
+				// SNI is not sent to nodes addressed by IP, so node is known only when server name is set
+				config.CertificateExpiryMonitor.Observe(ServerCertificate, cs.ServerName, cs.PeerCertificates[0], config.Logger)
+			}
+			if verifyConnection != nil {
+				return verifyConnection(cs)
+			}
+			return nil
 		}
 	}
+
+	patchTLSVerification(config, transport)
 }
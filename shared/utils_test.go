@@ -0,0 +1,129 @@
+package shared_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+)
+
+var errDefaultDial = errors.New("default dial")
+
+// newDefaultTransport returns a clone of `http.DefaultTransport` that dials with a stub, to tell whether dialer
+// is replaced by `PatchBasicHTTPTransport`
+func newDefaultTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(context.Context, string, string) (net.Conn, error) {
+		return nil, errDefaultDial
+	}
+	return transport
+}
+
+func TestPatchBasicHTTPTransport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Options", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := shared.NewDefaultALNConfig()
+		cfg.MaxHTTPConnectionsPerHost = 7
+		cfg.MaxIdleHTTPConnectionsPerHost = 5
+		cfg.TLSHandshakeTimeout = 3 * time.Second
+		cfg.ResponseHeaderTimeout = 4 * time.Second
+		cfg.DialTimeout = time.Nanosecond
+		cfg.DialKeepAlive = time.Second
+
+		transport := newDefaultTransport()
+		shared.PatchBasicHTTPTransport(cfg, transport)
+
+		if transport.MaxConnsPerHost != 7 {
+			t.Errorf("expected MaxConnsPerHost to be 7, got %d", transport.MaxConnsPerHost)
+		}
+		if transport.MaxIdleConnsPerHost != 5 {
+			t.Errorf("expected MaxIdleConnsPerHost to be 5, got %d", transport.MaxIdleConnsPerHost)
+		}
+		if transport.TLSHandshakeTimeout != 3*time.Second {
+			t.Errorf("expected TLSHandshakeTimeout to be 3s, got %s", transport.TLSHandshakeTimeout)
+		}
+		if transport.ResponseHeaderTimeout != 4*time.Second {
+			t.Errorf("expected ResponseHeaderTimeout to be 4s, got %s", transport.ResponseHeaderTimeout)
+		}
+
+		// Deadline of the dial passes before connection is established
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		defer listener.Close()
+		_, err = transport.DialContext(context.Background(), "tcp", listener.Addr().String())
+		if errors.Is(err, errDefaultDial) {
+			t.Fatalf("expected dialer to be replaced")
+		}
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatalf("expected dial to time out, got %v", err)
+		}
+	})
+
+	t.Run("DialKeepAlive", func(t *testing.T) {
+		t.Parallel()
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		defer listener.Close()
+
+		cfg := shared.NewDefaultALNConfig()
+		cfg.DialKeepAlive = time.Second
+
+		transport := newDefaultTransport()
+		shared.PatchBasicHTTPTransport(cfg, transport)
+
+		conn, err := transport.DialContext(context.Background(), "tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("expected dialer with default timeout to connect, got %v", err)
+		}
+		_ = conn.Close()
+	})
+
+	t.Run("ZeroValues", func(t *testing.T) {
+		t.Parallel()
+
+		defaults := newDefaultTransport()
+		transport := newDefaultTransport()
+		shared.PatchBasicHTTPTransport(shared.NewDefaultALNConfig(), transport)
+
+		if transport.MaxConnsPerHost != defaults.MaxConnsPerHost {
+			t.Errorf("expected MaxConnsPerHost to stay %d, got %d", defaults.MaxConnsPerHost, transport.MaxConnsPerHost)
+		}
+		if transport.MaxIdleConnsPerHost != defaults.MaxIdleConnsPerHost {
+			t.Errorf(
+				"expected MaxIdleConnsPerHost to stay %d, got %d",
+				defaults.MaxIdleConnsPerHost,
+				transport.MaxIdleConnsPerHost,
+			)
+		}
+		if transport.TLSHandshakeTimeout != defaults.TLSHandshakeTimeout {
+			t.Errorf(
+				"expected TLSHandshakeTimeout to stay %s, got %s",
+				defaults.TLSHandshakeTimeout,
+				transport.TLSHandshakeTimeout,
+			)
+		}
+		if transport.ResponseHeaderTimeout != defaults.ResponseHeaderTimeout {
+			t.Errorf(
+				"expected ResponseHeaderTimeout to stay %s, got %s",
+				defaults.ResponseHeaderTimeout,
+				transport.ResponseHeaderTimeout,
+			)
+		}
+		if _, err := transport.DialContext(context.Background(), "tcp", "127.0.0.1:0"); !errors.Is(err, errDefaultDial) {
+			t.Errorf("expected dialer to stay unchanged, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package shared
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+const defaultWarmUpTimeout = 10 * time.Second
+
+// ConnectionWarmer opens keep-alive connections to Alternator nodes ahead of time,
+//
+//	so that first real request to the node does not pay for TCP connect and TLS handshake
+type ConnectionWarmer struct {
+	transport   http.RoundTripper
+	connections int
+	maxIdle     int
+	timeout     time.Duration
+	logger      logx.Logger
+	ctx         context.Context
+	cancel      context.CancelFunc
+	lock        sync.Mutex
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// NewConnectionWarmer creates new `ConnectionWarmer` that opens up to `connections` connections per node
+//
+//	through provided transport, total number of connections opened by a single warm-up is capped by `maxIdle`
+func NewConnectionWarmer(
+	transport http.RoundTripper,
+	connections, maxIdle int,
+	logger logx.Logger,
+) *ConnectionWarmer {
+	if logger == nil {
+		logger = logx.Noop{}
+	}
+	if t, ok := UnwrapHTTPTransport(transport); ok {
+		perHost := t.MaxIdleConnsPerHost
+		if perHost == 0 {
+			perHost = http.DefaultMaxIdleConnsPerHost
+		}
+		if perHost > 0 && connections > perHost {
+			// Connections above per-host idle limit would be closed right after warm-up
+			connections = perHost
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ConnectionWarmer{
+		transport:   transport,
+		connections: connections,
+		maxIdle:     maxIdle,
+		timeout:     defaultWarmUpTimeout,
+		logger:      logger,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// WarmUp opens connections to provided nodes in background
+func (w *ConnectionWarmer) WarmUp(nodes []url.URL) {
+	if w.connections <= 0 || w.maxIdle < 0 || len(nodes) == 0 {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.warmUp(nodes)
+	}()
+}
+
+// Close aborts warm-ups in progress and waits for them to finish, further warm-ups are ignored
+func (w *ConnectionWarmer) Close(ctx context.Context) error {
+	w.lock.Lock()
+	w.closed = true
+	w.lock.Unlock()
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *ConnectionWarmer) warmUp(nodes []url.URL) {
+	budget := w.maxIdle
+	ctx, cancel := context.WithTimeout(w.ctx, w.timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		connections := w.connections
+		if w.maxIdle > 0 {
+			if budget <= 0 {
+				w.logger.Debug("warm-up connection budget exhausted", logx.A("maxIdle", w.maxIdle))
+				break
+			}
+			connections = min(connections, budget)
+			budget -= connections
+		}
+
+		// Requests should run concurrently, otherwise transport would reuse the same connection
+		for range connections {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.warmUpConnection(ctx, node)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func (w *ConnectionWarmer) warmUpConnection(ctx context.Context, node url.URL) {
+	endpoint := node
+	endpoint.Path = "/"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), http.NoBody)
+	if err != nil {
+		w.logger.Error("failed to create warm-up request", logx.A("node", node.String()), logx.A("error", err))
+		return
+	}
+	resp, err := w.transport.RoundTrip(req)
+	if err != nil {
+		w.logger.Debug("failed to warm up connection", logx.A("node", node.String()), logx.A("error", err))
+		return
+	}
+	// Body has to be fully read and closed for connection to be returned to the idle pool
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,163 @@
+package shared_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scylladb/alternator-client-golang/shared"
+	"github.com/scylladb/alternator-client-golang/shared/logx"
+)
+
+// countingTransport counts warm-up requests per host
+type countingTransport struct {
+	http.RoundTripper
+	lock     sync.Mutex
+	requests map[string]int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	t.requests[req.URL.Host]++
+	t.lock.Unlock()
+	return t.RoundTripper.RoundTrip(req)
+}
+
+func (t *countingTransport) Unwrap() http.RoundTripper {
+	return t.RoundTripper
+}
+
+func (t *countingTransport) counts(nodes []url.URL) []int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	out := make([]int, len(nodes))
+	for i, node := range nodes {
+		out[i] = t.requests[node.Host]
+	}
+	return out
+}
+
+func newWarmUpNodes(t *testing.T, count int, handler http.HandlerFunc) []url.URL {
+	t.Helper()
+	nodes := make([]url.URL, count)
+	for i := range nodes {
+		srv := httptest.NewServer(handler)
+		t.Cleanup(srv.Close)
+		parsed, _ := url.Parse(srv.URL)
+		nodes[i] = *parsed
+	}
+	return nodes
+}
+
+func TestConnectionWarmer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("PoolSizeCap", func(t *testing.T) {
+		t.Parallel()
+
+		tcases := []struct {
+			name        string
+			connections int
+			maxIdle     int
+			perHost     int
+			expected    []int
+		}{
+			{name: "MaxIdle", connections: 3, maxIdle: 4, perHost: 10, expected: []int{3, 1, 0}},
+			{name: "MaxIdleConnsPerHost", connections: 5, perHost: 2, expected: []int{2, 2, 2}},
+			{name: "Unlimited", connections: 2, expected: []int{2, 2, 2}},
+		}
+		for _, tc := range tcases {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				nodes := newWarmUpNodes(t, 3, func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				})
+				base := shared.DefaultHTTPTransport()
+				base.MaxIdleConnsPerHost = tc.perHost
+				t.Cleanup(base.CloseIdleConnections)
+				transport := &countingTransport{RoundTripper: base, requests: map[string]int{}}
+
+				warmer := shared.NewConnectionWarmer(transport, tc.connections, tc.maxIdle, logx.Noop{})
+				warmer.WarmUp(nodes)
+
+				total := 0
+				for _, n := range tc.expected {
+					total += n
+				}
+				deadline := time.Now().Add(5 * time.Second)
+				for {
+					sum := 0
+					for _, n := range transport.counts(nodes) {
+						sum += n
+					}
+					if sum >= total || time.Now().After(deadline) {
+						break
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				if err := warmer.Close(context.Background()); err != nil {
+					t.Fatalf("failed to close warmer: %v", err)
+				}
+				got := transport.counts(nodes)
+				for i := range got {
+					if got[i] != tc.expected[i] {
+						t.Fatalf("expected %v warm-up requests per node, got %v", tc.expected, got)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("CloseStopsWarmUp", func(t *testing.T) {
+		t.Parallel()
+
+		var started, aborted atomic.Int64
+		nodes := newWarmUpNodes(t, 1, func(_ http.ResponseWriter, r *http.Request) {
+			started.Add(1)
+			select {
+			case <-r.Context().Done():
+				aborted.Add(1)
+			case <-time.After(5 * time.Second):
+			}
+		})
+		base := shared.DefaultHTTPTransport()
+		t.Cleanup(base.CloseIdleConnections)
+		transport := &countingTransport{RoundTripper: base, requests: map[string]int{}}
+
+		warmer := shared.NewConnectionWarmer(transport, 2, 0, logx.Noop{})
+		warmer.WarmUp(nodes)
+		deadline := time.Now().Add(5 * time.Second)
+		for started.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if started.Load() != 2 {
+			t.Fatalf("expected 2 warm-up requests to be in flight, got %d", started.Load())
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := warmer.Close(ctx); err != nil {
+			t.Fatalf("expected Close to abort warm-ups in flight, got %v", err)
+		}
+		deadline = time.Now().Add(5 * time.Second)
+		for aborted.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if aborted.Load() != 2 {
+			t.Errorf("expected 2 warm-up requests to be aborted, got %d", aborted.Load())
+		}
+
+		// Warm-ups after Close are ignored
+		warmer.WarmUp(nodes)
+		time.Sleep(50 * time.Millisecond)
+		if got := transport.counts(nodes)[0]; got != 2 {
+			t.Errorf("expected no warm-up requests after Close, got %d requests in total", got)
+		}
+	})
+}